@@ -117,6 +117,17 @@ echo ""
 echo -e "${YELLOW}[7/8] 构建并启动服务...${NC}"
 echo "这可能需要几分钟，请耐心等待..."
 
+# 图表签名密钥：后端未配置时拒绝启动。优先使用环境变量，其次沿用 .env 中已生成的密钥，都没有时生成新密钥写入 .env
+if [ -z "$CHART_SIGNING_KEY" ] && ! grep -q '^CHART_SIGNING_KEY=' .env 2>/dev/null; then
+    if ! command -v openssl &> /dev/null; then
+        echo -e "${RED}未找到 openssl，无法生成图表签名密钥，请设置环境变量 CHART_SIGNING_KEY（至少 32 个字符）后重试${NC}"
+        exit 1
+    fi
+    echo "CHART_SIGNING_KEY=$(openssl rand -hex 32)" >> .env
+    chmod 600 .env
+    echo -e "${GREEN}已生成图表签名密钥并写入 .env${NC}"
+fi
+
 # 构建镜像
 docker compose build --no-cache
 
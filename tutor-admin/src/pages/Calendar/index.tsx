@@ -1,5 +1,5 @@
 import { PageContainer } from '@ant-design/pro-components';
-import { Modal, Form, Select, DatePicker, message, Tag } from 'antd';
+import { Modal, Form, Select, DatePicker, Input, message, Tag } from 'antd';
 import { useState, useEffect, useRef } from 'react';
 import FullCalendar from '@fullcalendar/react';
 import dayGridPlugin from '@fullcalendar/daygrid';
@@ -19,25 +19,23 @@ import './index.less';
 
 const STATUS_MAP: Record<string, { text: string; color: string }> = {
   scheduled: { text: '已排课', color: '#1890ff' },
+  in_progress: { text: '上课中', color: '#faad14' },
   completed: { text: '已完成', color: '#52c41a' },
   cancelled: { text: '已取消', color: '#ff4d4f' },
 };
 
-// 根据时间计算课程实际状态颜色
+// 按课程状态显示颜色
 const getScheduleColor = (schedule: API.Schedule) => {
-  // 已取消的课程保持红色
-  if (schedule.status === 'cancelled') return '#ff4d4f';
-  
-  const now = dayjs();
-  const start = dayjs(schedule.start_time);
-  const end = dayjs(schedule.end_time);
-  
-  // 已结束 - 灰色
-  if (now.isAfter(end)) return '#8c8c8c';
-  // 进行中 - 黄色
-  if (now.isAfter(start) && now.isBefore(end)) return '#faad14';
-  // 未开始 - 蓝色
-  return '#1890ff';
+  switch (schedule.status) {
+    case 'cancelled':
+      return '#ff4d4f';
+    case 'completed':
+      return '#8c8c8c';
+    case 'in_progress':
+      return '#faad14';
+    default:
+      return '#1890ff';
+  }
 };
 
 const Calendar: React.FC = () => {
@@ -48,7 +46,9 @@ const Calendar: React.FC = () => {
   const [detailModalOpen, setDetailModalOpen] = useState(false);
   const [selectedRange, setSelectedRange] = useState<{ start: Date; end: Date } | null>(null);
   const [selectedSchedule, setSelectedSchedule] = useState<API.Schedule | null>(null);
+  const [cancelModalOpen, setCancelModalOpen] = useState(false);
   const [form] = Form.useForm();
+  const [cancelForm] = Form.useForm();
   const calendarRef = useRef<FullCalendar>(null);
 
   const loadData = async () => {
@@ -94,15 +94,25 @@ const Calendar: React.FC = () => {
 
   const handleUpdateStatus = async (status: string) => {
     if (!selectedSchedule) return;
-    await updateSchedule(selectedSchedule.id, { status });
+    await updateSchedule(selectedSchedule.id, selectedSchedule.version, { status });
     message.success('状态更新成功');
     setDetailModalOpen(false);
     loadData();
   };
 
+  const handleCancel = async () => {
+    if (!selectedSchedule) return;
+    const values = await cancelForm.validateFields();
+    await updateSchedule(selectedSchedule.id, selectedSchedule.version, { status: 'cancelled', ...values });
+    message.success('课程已取消');
+    setCancelModalOpen(false);
+    setDetailModalOpen(false);
+    loadData();
+  };
+
   const handleDelete = async () => {
     if (!selectedSchedule) return;
-    await deleteSchedule(selectedSchedule.id);
+    await deleteSchedule(selectedSchedule.id, selectedSchedule.version);
     message.success('删除成功');
     setDetailModalOpen(false);
     loadData();
@@ -203,11 +213,11 @@ const Calendar: React.FC = () => {
         open={detailModalOpen}
         onCancel={() => setDetailModalOpen(false)}
         footer={
-          selectedSchedule?.status === 'scheduled' ? [
+          selectedSchedule?.status === 'scheduled' || selectedSchedule?.status === 'in_progress' ? [
             <a key="complete" style={{ marginRight: 16 }} onClick={() => handleUpdateStatus('completed')}>
               标记完成
             </a>,
-            <a key="cancel" style={{ marginRight: 16, color: '#faad14' }} onClick={() => handleUpdateStatus('cancelled')}>
+            <a key="cancel" style={{ marginRight: 16, color: '#faad14' }} onClick={() => { cancelForm.resetFields(); setCancelModalOpen(true); }}>
               取消课程
             </a>,
             <a key="delete" style={{ color: '#ff4d4f' }} onClick={handleDelete}>
@@ -230,10 +240,37 @@ const Calendar: React.FC = () => {
               <Tag color={STATUS_MAP[selectedSchedule.status]?.color}>
                 {STATUS_MAP[selectedSchedule.status]?.text}
               </Tag>
+              {selectedSchedule.late_cancel && <Tag color="warning">迟取消计费</Tag>}
             </p>
+            {selectedSchedule.cancel_reason && (
+              <p><strong>取消原因：</strong>{selectedSchedule.cancel_reason}</p>
+            )}
           </div>
         )}
       </Modal>
+
+      {/* 取消课程弹窗 */}
+      <Modal
+        title="取消课程"
+        open={cancelModalOpen}
+        onOk={handleCancel}
+        onCancel={() => setCancelModalOpen(false)}
+        okText="确认取消"
+        cancelText="返回"
+      >
+        <Form form={cancelForm} layout="vertical">
+          <Form.Item name="cancelled_by" label="取消方" rules={[{ required: true, message: '请选择取消方' }]}>
+            <Select placeholder="请选择取消方">
+              <Select.Option value="student">学生</Select.Option>
+              <Select.Option value="parent">家长</Select.Option>
+              <Select.Option value="tutor">老师</Select.Option>
+            </Select>
+          </Form.Item>
+          <Form.Item name="cancel_reason" label="取消原因">
+            <Input.TextArea rows={2} maxLength={500} />
+          </Form.Item>
+        </Form>
+      </Modal>
     </PageContainer>
   );
 };
@@ -19,8 +19,8 @@ const Courses: React.FC = () => {
   const [modalOpen, setModalOpen] = useState(false);
   const [currentRow, setCurrentRow] = useState<API.Course>();
 
-  const handleDelete = async (id: number) => {
-    await deleteCourse(id);
+  const handleDelete = async (id: number, version: number) => {
+    await deleteCourse(id, version);
     message.success('删除成功');
     actionRef.current?.reload();
   };
@@ -56,7 +56,7 @@ const Courses: React.FC = () => {
         <Popconfirm
           key="delete"
           title="确定删除？"
-          onConfirm={() => handleDelete(record.id)}
+          onConfirm={() => handleDelete(record.id, record.version)}
         >
           <a style={{ color: '#ff4d4f' }}>删除</a>
         </Popconfirm>,
@@ -98,7 +98,7 @@ const Courses: React.FC = () => {
         modalProps={{ destroyOnClose: true }}
         onFinish={async (values) => {
           if (currentRow) {
-            await updateCourse(currentRow.id, values);
+            await updateCourse(currentRow.id, currentRow.version, values);
             message.success('更新成功');
           } else {
             await createCourse(values);
@@ -1,5 +1,6 @@
 import { Modal, Select, Button, Space, message, Spin } from 'antd';
 import { useState } from 'react';
+import { getSignedChartUrl } from '@/services/tutor';
 
 interface AnalysisModalProps {
   open: boolean;
@@ -8,14 +9,25 @@ interface AnalysisModalProps {
   courses: API.Course[];
 }
 
+type ChartType = 'trend' | 'radar' | 'delta';
+
+const CHART_TYPES: { label: string; value: ChartType; title: string }[] = [
+  { label: '成绩曲线', value: 'trend', title: '成绩曲线' },
+  { label: '各科雷达图', value: 'radar', title: '各科成绩' },
+  { label: '成绩变化', value: 'delta', title: '成绩变化' },
+];
+
 const AnalysisModal: React.FC<AnalysisModalProps> = ({ open, onClose, students, courses }) => {
   const [selectedStudent, setSelectedStudent] = useState<number>();
   const [selectedCourse, setSelectedCourse] = useState<number>();
+  const [chartType, setChartType] = useState<ChartType>('trend');
   const [chartUrl, setChartUrl] = useState<string>('');
   const [studentName, setStudentName] = useState('');
   const [courseName, setCourseName] = useState('');
   const [loading, setLoading] = useState(false);
 
+  const chartTitle = CHART_TYPES.find((t) => t.value === chartType)?.title;
+
   const handleClose = () => {
     setSelectedStudent(undefined);
     setSelectedCourse(undefined);
@@ -34,25 +46,22 @@ const AnalysisModal: React.FC<AnalysisModalProps> = ({ open, onClose, students,
     const student = students.find((s) => s.id === selectedStudent);
     const course = selectedCourse ? courses.find((c) => c.id === selectedCourse) : null;
     setStudentName(student?.name || '');
-    setCourseName(course?.name || '全部科目');
-
-    // 构建后端图表 URL
-    let url = `/api/analysis/${selectedStudent}/trend.png`;
-    if (selectedCourse) {
-      // 根据课程名称映射到 subject 参数
-      const courseNameLower = course?.name?.toLowerCase() || '';
-      if (courseNameLower.includes('数学') || courseNameLower.includes('math')) {
-        url += '?subject=math';
-      } else if (courseNameLower.includes('英语') || courseNameLower.includes('english')) {
-        url += '?subject=english';
-      }
+    setCourseName(chartType !== 'radar' && course ? course.name : '全部科目');
+
+    // 构建后端图表 URL，<img> 无法携带 token，需先换取签名地址
+    let url = `/api/analysis/${selectedStudent}/${chartType}.png`;
+    if (selectedCourse && chartType !== 'radar') {
+      url += `?course_id=${selectedCourse}`;
     }
 
-    // 添加时间戳防止缓存
-    url += (url.includes('?') ? '&' : '?') + `t=${Date.now()}`;
-    
     setLoading(true);
-    setChartUrl(url);
+    try {
+      const signed = await getSignedChartUrl(url);
+      setChartUrl(signed.url);
+    } catch (error) {
+      setLoading(false);
+      message.error('加载图表失败');
+    }
   };
 
   const handleSave = () => {
@@ -60,7 +69,7 @@ const AnalysisModal: React.FC<AnalysisModalProps> = ({ open, onClose, students,
     
     // 创建下载链接
     const link = document.createElement('a');
-    link.download = `${studentName}_成绩曲线_${new Date().toLocaleDateString()}.png`;
+    link.download = `${studentName}_${chartTitle}_${new Date().toLocaleDateString()}.png`;
     link.href = chartUrl;
     link.click();
     message.success('保存成功');
@@ -93,12 +102,19 @@ const AnalysisModal: React.FC<AnalysisModalProps> = ({ open, onClose, students,
           onChange={setSelectedStudent}
           allowClear
         />
+        <Select
+          style={{ width: 130 }}
+          value={chartType}
+          options={CHART_TYPES.map(({ label, value }) => ({ label, value }))}
+          onChange={setChartType}
+        />
         <Select
           placeholder="选择科目(可选)"
           style={{ width: 150 }}
           value={selectedCourse}
           options={courses.map((c) => ({ label: c.name, value: c.id }))}
           onChange={setSelectedCourse}
+          disabled={chartType === 'radar'}
           allowClear
         />
         <Button type="primary" onClick={handleAnalyze}>
@@ -110,13 +126,13 @@ const AnalysisModal: React.FC<AnalysisModalProps> = ({ open, onClose, students,
       {(chartUrl || loading) && (
         <div style={{ padding: 20, background: '#fff', textAlign: 'center' }}>
           <h3 style={{ marginBottom: 16 }}>
-            {studentName} - {courseName} 成绩曲线
+            {studentName} - {courseName} {chartTitle}
           </h3>
           <Spin spinning={loading}>
             {chartUrl && (
               <img
                 src={chartUrl}
-                alt="成绩趋势图"
+                alt={chartTitle}
                 style={{ maxWidth: '100%', height: 'auto' }}
                 onLoad={handleImageLoad}
                 onError={handleImageError}
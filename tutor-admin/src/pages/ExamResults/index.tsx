@@ -41,8 +41,8 @@ const ExamResults: React.FC = () => {
     getCourses().then(setCourses);
   }, []);
 
-  const handleDelete = async (id: number) => {
-    await deleteExamResult(id);
+  const handleDelete = async (id: number, version: number) => {
+    await deleteExamResult(id, version);
     message.success('删除成功');
     actionRef.current?.reload();
   };
@@ -117,7 +117,7 @@ const ExamResults: React.FC = () => {
         >
           编辑
         </a>,
-        <Popconfirm key="delete" title="确定删除？" onConfirm={() => handleDelete(record.id)}>
+        <Popconfirm key="delete" title="确定删除？" onConfirm={() => handleDelete(record.id, record.version)}>
           <a style={{ color: '#ff4d4f' }}>删除</a>
         </Popconfirm>,
       ],
@@ -178,7 +178,7 @@ const ExamResults: React.FC = () => {
             exam_date: values.exam_date + 'T00:00:00Z',
           };
           if (currentRow) {
-            await updateExamResult(currentRow.id, data);
+            await updateExamResult(currentRow.id, currentRow.version, data);
             message.success('更新成功');
           } else {
             await createExamResult(data);
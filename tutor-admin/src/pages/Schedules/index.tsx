@@ -6,6 +6,8 @@ import {
   ModalForm,
   ProFormSelect,
   ProFormDateTimePicker,
+  ProFormDependency,
+  ProFormText,
 } from '@ant-design/pro-components';
 import { Button, message, Popconfirm, Tag } from 'antd';
 import { useRef, useState, useEffect } from 'react';
@@ -15,16 +17,32 @@ import {
   createSchedule,
   updateSchedule,
   deleteSchedule,
+  checkInSchedule,
+  checkOutSchedule,
   getStudents,
   getCourses,
 } from '@/services/tutor';
 
 const STATUS_MAP = {
   scheduled: { text: '已排课', color: 'processing' },
+  in_progress: { text: '上课中', color: 'warning' },
   completed: { text: '已完成', color: 'success' },
   cancelled: { text: '已取消', color: 'error' },
 };
 
+const ATTENDANCE_MAP = {
+  present: { text: '出勤', color: 'green' },
+  late: { text: '迟到', color: 'orange' },
+  absent: { text: '缺勤', color: 'red' },
+  excused: { text: '请假', color: 'default' },
+};
+
+const CANCELLED_BY_OPTIONS = [
+  { label: '学生', value: 'student' },
+  { label: '家长', value: 'parent' },
+  { label: '老师', value: 'tutor' },
+];
+
 const Schedules: React.FC = () => {
   const actionRef = useRef<ActionType>();
   const [modalOpen, setModalOpen] = useState(false);
@@ -37,12 +55,24 @@ const Schedules: React.FC = () => {
     getCourses().then(setCourses);
   }, []);
 
-  const handleDelete = async (id: number) => {
-    await deleteSchedule(id);
+  const handleDelete = async (id: number, version: number) => {
+    await deleteSchedule(id, version);
     message.success('删除成功');
     actionRef.current?.reload();
   };
 
+  const handleCheckIn = async (record: API.Schedule) => {
+    await checkInSchedule(record.id, record.version);
+    message.success('签到成功');
+    actionRef.current?.reload();
+  };
+
+  const handleCheckOut = async (record: API.Schedule) => {
+    await checkOutSchedule(record.id, record.version);
+    message.success('签退成功');
+    actionRef.current?.reload();
+  };
+
   const columns: ProColumns<API.Schedule>[] = [
     {
       title: '学生',
@@ -94,19 +124,40 @@ const Schedules: React.FC = () => {
       valueType: 'select',
       valueEnum: {
         scheduled: { text: '已排课', status: 'Processing' },
+        in_progress: { text: '上课中', status: 'Warning' },
         completed: { text: '已完成', status: 'Success' },
         cancelled: { text: '已取消', status: 'Error' },
       },
       render: (_, record) => {
         const status = STATUS_MAP[record.status] || STATUS_MAP.scheduled;
-        return <Tag color={status.color}>{status.text}</Tag>;
+        return (
+          <>
+            <Tag color={status.color}>{status.text}</Tag>
+            {record.attendance && ATTENDANCE_MAP[record.attendance] && (
+              <Tag color={ATTENDANCE_MAP[record.attendance].color}>
+                {ATTENDANCE_MAP[record.attendance].text}
+              </Tag>
+            )}
+            {record.late_cancel && <Tag color="warning">迟取消计费</Tag>}
+          </>
+        );
       },
     },
     {
       title: '操作',
       valueType: 'option',
-      width: 150,
+      width: 200,
       render: (_, record) => [
+        record.status === 'scheduled' && !record.check_in_at && (
+          <a key="check-in" onClick={() => handleCheckIn(record)}>
+            签到
+          </a>
+        ),
+        record.check_in_at && !record.check_out_at && (
+          <a key="check-out" onClick={() => handleCheckOut(record)}>
+            签退
+          </a>
+        ),
         <a
           key="edit"
           onClick={() => {
@@ -119,7 +170,7 @@ const Schedules: React.FC = () => {
         <Popconfirm
           key="delete"
           title="确定删除？"
-          onConfirm={() => handleDelete(record.id)}
+          onConfirm={() => handleDelete(record.id, record.version)}
         >
           <a style={{ color: '#ff4d4f' }}>删除</a>
         </Popconfirm>,
@@ -181,9 +232,11 @@ const Schedules: React.FC = () => {
             start_time: dayjs(values.start_time).toISOString(),
             end_time: dayjs(values.end_time).toISOString(),
             status: values.status,
+            cancelled_by: values.status === 'cancelled' ? values.cancelled_by : undefined,
+            cancel_reason: values.status === 'cancelled' ? values.cancel_reason : undefined,
           };
           if (currentRow) {
-            await updateSchedule(currentRow.id, data);
+            await updateSchedule(currentRow.id, currentRow.version, data);
             message.success('更新成功');
           } else {
             await createSchedule(data);
@@ -223,10 +276,26 @@ const Schedules: React.FC = () => {
           label="状态"
           options={[
             { label: '已排课', value: 'scheduled' },
+            { label: '上课中', value: 'in_progress' },
             { label: '已完成', value: 'completed' },
             { label: '已取消', value: 'cancelled' },
           ]}
         />
+        <ProFormDependency name={['status']}>
+          {({ status }) =>
+            status === 'cancelled' && (
+              <>
+                <ProFormSelect
+                  name="cancelled_by"
+                  label="取消方"
+                  rules={[{ required: true, message: '请选择取消方' }]}
+                  options={CANCELLED_BY_OPTIONS}
+                />
+                <ProFormText name="cancel_reason" label="取消原因" />
+              </>
+            )
+          }
+        </ProFormDependency>
       </ModalForm>
     </PageContainer>
   );
@@ -10,8 +10,8 @@ const Students: React.FC = () => {
   const [modalOpen, setModalOpen] = useState(false);
   const [currentRow, setCurrentRow] = useState<API.Student>();
 
-  const handleDelete = async (id: number) => {
-    await deleteStudent(id);
+  const handleDelete = async (id: number, version: number) => {
+    await deleteStudent(id, version);
     message.success('删除成功');
     actionRef.current?.reload();
   };
@@ -54,7 +54,7 @@ const Students: React.FC = () => {
         <Popconfirm
           key="delete"
           title="确定删除？"
-          onConfirm={() => handleDelete(record.id)}
+          onConfirm={() => handleDelete(record.id, record.version)}
         >
           <a style={{ color: '#ff4d4f' }}>删除</a>
         </Popconfirm>,
@@ -96,7 +96,7 @@ const Students: React.FC = () => {
         modalProps={{ destroyOnClose: true }}
         onFinish={async (values) => {
           if (currentRow) {
-            await updateStudent(currentRow.id, values);
+            await updateStudent(currentRow.id, currentRow.version, values);
             message.success('更新成功');
           } else {
             await createStudent(values);
@@ -1,59 +1,64 @@
 import { PageContainer } from '@ant-design/pro-components';
 import { Card, Col, Row, Statistic, List, Tag, Empty } from 'antd';
-import { UserOutlined, BookOutlined, CalendarOutlined, ClockCircleOutlined } from '@ant-design/icons';
+import { UserOutlined, BookOutlined, CalendarOutlined, ClockCircleOutlined, WarningOutlined } from '@ant-design/icons';
 import { useEffect, useState } from 'react';
 import dayjs from 'dayjs';
-import { getStudents, getCourses, searchSchedules } from '@/services/tutor';
+import { getStudents, getCourses, searchSchedules, getScoreAlerts } from '@/services/tutor';
+
+const ALERT_TYPE_MAP: Record<API.ScoreAlert['type'], { text: string; color: string }> = {
+  significant_drop: { text: '显著下滑', color: 'error' },
+  downtrend: { text: '持续下降', color: 'warning' },
+  below_pass: { text: '预计不及格', color: 'volcano' },
+};
 
 const STATUS_MAP: Record<string, { text: string; color: string }> = {
   scheduled: { text: '待上课', color: 'processing' },
+  in_progress: { text: '上课中', color: 'warning' },
   completed: { text: '已完成', color: 'success' },
   cancelled: { text: '已取消', color: 'error' },
 };
 
-// 根据时间计算课程状态和背景色（浅色版本）
+// 根据课程状态计算标签和背景色（浅色版本）
 const getScheduleStyle = (schedule: API.Schedule) => {
-  if (schedule.status === 'cancelled') {
-    return { text: '已取消', color: 'error', bgColor: 'rgba(255, 77, 79, 0.1)' };
-  }
-  
-  const now = dayjs();
-  const start = dayjs(schedule.start_time);
-  const end = dayjs(schedule.end_time);
-  
-  // 已结束 - 浅灰色背景
-  if (now.isAfter(end)) {
-    return { text: '已结束', color: 'default', bgColor: 'rgba(140, 140, 140, 0.1)' };
-  }
-  // 进行中 - 浅黄色背景
-  if (now.isAfter(start) && now.isBefore(end)) {
-    return { text: '进行中', color: 'warning', bgColor: 'rgba(250, 173, 20, 0.15)' };
+  switch (schedule.status) {
+    case 'cancelled':
+      return { text: '已取消', color: 'error', bgColor: 'rgba(255, 77, 79, 0.1)' };
+    // 已完成 - 浅灰色背景
+    case 'completed':
+      return { text: '已完成', color: 'default', bgColor: 'rgba(140, 140, 140, 0.1)' };
+    // 上课中 - 浅黄色背景
+    case 'in_progress':
+      return { text: '上课中', color: 'warning', bgColor: 'rgba(250, 173, 20, 0.15)' };
+    // 未开始 - 浅蓝色背景
+    default:
+      return { text: '待上课', color: 'processing', bgColor: 'rgba(24, 144, 255, 0.1)' };
   }
-  // 未开始 - 浅蓝色背景
-  return { text: '待上课', color: 'processing', bgColor: 'rgba(24, 144, 255, 0.1)' };
 };
 
 const Welcome: React.FC = () => {
   const [studentCount, setStudentCount] = useState(0);
   const [courseCount, setCourseCount] = useState(0);
   const [todaySchedules, setTodaySchedules] = useState<API.Schedule[]>([]);
+  const [scoreAlerts, setScoreAlerts] = useState<API.ScoreAlert[]>([]);
   const [loading, setLoading] = useState(true);
 
   useEffect(() => {
     const loadData = async () => {
       setLoading(true);
       try {
-        const [students, courses, schedules] = await Promise.all([
+        const [students, courses, schedules, alerts] = await Promise.all([
           getStudents(),
           getCourses(),
           searchSchedules({
             start_date: dayjs().format('YYYY-MM-DD'),
             end_date: dayjs().format('YYYY-MM-DD'),
           }),
+          getScoreAlerts(),
         ]);
         setStudentCount(students?.length || 0);
         setCourseCount(courses?.length || 0);
         setTodaySchedules(schedules || []);
+        setScoreAlerts(alerts || []);
       } finally {
         setLoading(false);
       }
@@ -133,6 +138,31 @@ const Welcome: React.FC = () => {
           />
         )}
       </Card>
+
+      {scoreAlerts.length > 0 && (
+        <Card
+          title={
+            <span>
+              <WarningOutlined style={{ marginRight: 8 }} />
+              成绩预警
+            </span>
+          }
+          style={{ marginTop: 16 }}
+        >
+          <List
+            dataSource={scoreAlerts}
+            renderItem={(item) => (
+              <List.Item>
+                <List.Item.Meta
+                  title={`${item.student_name} - ${item.course_name}`}
+                  description={item.message}
+                />
+                <Tag color={ALERT_TYPE_MAP[item.type]?.color}>{ALERT_TYPE_MAP[item.type]?.text}</Tag>
+              </List.Item>
+            )}
+          />
+        </Card>
+      )}
     </PageContainer>
   );
 };
@@ -1,5 +1,8 @@
 import { request } from '@umijs/max';
 
+// 乐观锁：更新、删除时携带记录版本号
+const ifMatch = (version: number) => ({ 'If-Match': `"${version}"` });
+
 // 学生管理
 export async function getStudents() {
   return request<API.Student[]>('/api/students');
@@ -12,16 +15,18 @@ export async function createStudent(data: Partial<API.Student>) {
   });
 }
 
-export async function updateStudent(id: number, data: Partial<API.Student>) {
+export async function updateStudent(id: number, version: number, data: Partial<API.Student>) {
   return request<API.Student>(`/api/students/${id}`, {
-    method: 'PUT',
+    method: 'PATCH',
+    headers: ifMatch(version),
     data,
   });
 }
 
-export async function deleteStudent(id: number) {
+export async function deleteStudent(id: number, version: number) {
   return request(`/api/students/${id}`, {
     method: 'DELETE',
+    headers: ifMatch(version),
   });
 }
 
@@ -37,16 +42,18 @@ export async function createCourse(data: Partial<API.Course>) {
   });
 }
 
-export async function updateCourse(id: number, data: Partial<API.Course>) {
+export async function updateCourse(id: number, version: number, data: Partial<API.Course>) {
   return request<API.Course>(`/api/courses/${id}`, {
-    method: 'PUT',
+    method: 'PATCH',
+    headers: ifMatch(version),
     data,
   });
 }
 
-export async function deleteCourse(id: number) {
+export async function deleteCourse(id: number, version: number) {
   return request(`/api/courses/${id}`, {
     method: 'DELETE',
+    headers: ifMatch(version),
   });
 }
 
@@ -68,16 +75,46 @@ export async function createSchedule(data: Partial<API.Schedule>) {
   });
 }
 
-export async function updateSchedule(id: number, data: Partial<API.Schedule>) {
+export async function updateSchedule(id: number, version: number, data: Partial<API.Schedule>) {
   return request<API.Schedule>(`/api/schedules/${id}`, {
-    method: 'PUT',
+    method: 'PATCH',
+    headers: ifMatch(version),
     data,
   });
 }
 
-export async function deleteSchedule(id: number) {
+export async function deleteSchedule(id: number, version: number) {
   return request(`/api/schedules/${id}`, {
     method: 'DELETE',
+    headers: ifMatch(version),
+  });
+}
+
+export async function checkInSchedule(id: number, version: number, data: { time?: string } = {}) {
+  return request<API.Schedule>(`/api/schedules/${id}/check-in`, {
+    method: 'POST',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function checkOutSchedule(id: number, version: number, data: { time?: string } = {}) {
+  return request<API.Schedule>(`/api/schedules/${id}/check-out`, {
+    method: 'POST',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function markScheduleAbsence(
+  id: number,
+  version: number,
+  data: { attendance: 'absent' | 'excused'; reason?: string },
+) {
+  return request<API.Schedule>(`/api/schedules/${id}/absence`, {
+    method: 'POST',
+    headers: ifMatch(version),
+    data,
   });
 }
 
@@ -93,16 +130,18 @@ export async function createExamResult(data: Partial<API.ExamResult>) {
   });
 }
 
-export async function updateExamResult(id: number, data: Partial<API.ExamResult>) {
+export async function updateExamResult(id: number, version: number, data: Partial<API.ExamResult>) {
   return request<API.ExamResult>(`/api/exam-results/${id}`, {
-    method: 'PUT',
+    method: 'PATCH',
+    headers: ifMatch(version),
     data,
   });
 }
 
-export async function deleteExamResult(id: number) {
+export async function deleteExamResult(id: number, version: number) {
   return request(`/api/exam-results/${id}`, {
     method: 'DELETE',
+    headers: ifMatch(version),
   });
 }
 
@@ -111,3 +150,251 @@ export async function getExamResultsByStudent(studentId: number, courseId?: numb
     params: courseId ? { course_id: courseId } : undefined,
   });
 }
+
+export async function getStudentSummary(
+  studentId: number,
+  params?: { course_id?: number; exam_type?: string; start_date?: string; end_date?: string },
+) {
+  return request<API.StudentSummary>(`/api/analysis/${studentId}/summary`, { params });
+}
+
+export async function getStudentForecast(studentId: number, params?: { course_id?: number }) {
+  return request<API.CourseForecast[]>(`/api/analysis/${studentId}/forecast`, { params });
+}
+
+export async function getScoreAlerts() {
+  return request<API.ScoreAlert[]>('/api/dashboard/score-alerts');
+}
+
+// 图表数据（format=json），供前端自行渲染
+export async function getAnalysisChartData(
+  studentId: number,
+  chart: 'trend' | 'radar' | 'delta',
+  params?: { course_id?: number; exam_type?: string },
+) {
+  return request<API.ChartData>(`/api/analysis/${studentId}/${chart}.png`, {
+    params: { ...params, format: 'json' },
+  });
+}
+
+export async function getComparisonChartData(studentIds: number[], courseId: number) {
+  return request<API.ChartData>('/api/analysis/compare.png', {
+    params: { student_ids: studentIds.join(','), course_id: courseId, format: 'json' },
+  });
+}
+
+// 图表签名地址，用于 <img> 等无法携带 token 的场景
+export async function getSignedChartUrl(url: string) {
+  return request<API.SignedChartUrl>('/api/analysis/signed-url', {
+    method: 'POST',
+    data: { url },
+  });
+}
+
+// 课堂记录
+export async function getLessonRecords(params?: { schedule_id?: number; student_id?: number; course_id?: number }) {
+  return request<API.LessonRecord[]>('/api/lesson-records', { params });
+}
+
+export async function createLessonRecord(data: Partial<API.LessonRecord>) {
+  return request<API.LessonRecord>('/api/lesson-records', {
+    method: 'POST',
+    data,
+  });
+}
+
+export async function updateLessonRecord(id: number, version: number, data: Partial<API.LessonRecord>) {
+  return request<API.LessonRecord>(`/api/lesson-records/${id}`, {
+    method: 'PATCH',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function deleteLessonRecord(id: number, version: number) {
+  return request(`/api/lesson-records/${id}`, {
+    method: 'DELETE',
+    headers: ifMatch(version),
+  });
+}
+
+export async function getStudentTimeline(studentId: number, params?: API.TimelineParams) {
+  return request<API.LessonRecord[]>(`/api/students/${studentId}/timeline`, { params });
+}
+
+// 作业管理
+export async function getHomework(params?: { student_id?: number; course_id?: number; status?: string }) {
+  return request<API.Homework[]>('/api/homework', { params });
+}
+
+export async function getOverdueHomework(params?: { student_id?: number; course_id?: number }) {
+  return request<API.Homework[]>('/api/homework/overdue', { params });
+}
+
+export async function createHomework(data: Partial<API.Homework>) {
+  return request<API.Homework>('/api/homework', {
+    method: 'POST',
+    data,
+  });
+}
+
+export async function updateHomework(id: number, version: number, data: Partial<API.Homework>) {
+  return request<API.Homework>(`/api/homework/${id}`, {
+    method: 'PATCH',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function transitionHomework(
+  id: number,
+  version: number,
+  data: { status: API.Homework['status']; score?: number; feedback?: string },
+) {
+  return request<API.Homework>(`/api/homework/${id}/transition`, {
+    method: 'POST',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function deleteHomework(id: number, version: number) {
+  return request(`/api/homework/${id}`, {
+    method: 'DELETE',
+    headers: ifMatch(version),
+  });
+}
+
+// 学习目标
+export async function getGoals(params?: { student_id?: number; course_id?: number; exam_type?: string }) {
+  return request<API.Goal[]>('/api/goals', { params });
+}
+
+export async function createGoal(data: Partial<API.Goal>) {
+  return request<API.Goal>('/api/goals', {
+    method: 'POST',
+    data,
+  });
+}
+
+export async function updateGoal(id: number, version: number, data: Partial<API.Goal>) {
+  return request<API.Goal>(`/api/goals/${id}`, {
+    method: 'PATCH',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function deleteGoal(id: number, version: number) {
+  return request(`/api/goals/${id}`, {
+    method: 'DELETE',
+    headers: ifMatch(version),
+  });
+}
+
+// 附件管理
+export async function getAttachments(params: { owner_type: API.Attachment['owner_type']; owner_id: number }) {
+  return request<API.Attachment[]>('/api/attachments', { params });
+}
+
+export async function uploadAttachment(
+  file: File,
+  owner: { owner_type: API.Attachment['owner_type']; owner_id: number; description?: string },
+) {
+  const data = new FormData();
+  data.append('file', file);
+  data.append('owner_type', owner.owner_type);
+  data.append('owner_id', String(owner.owner_id));
+  if (owner.description) {
+    data.append('description', owner.description);
+  }
+  return request<API.Attachment>('/api/attachments', {
+    method: 'POST',
+    data,
+  });
+}
+
+export async function updateAttachment(
+  id: number,
+  version: number,
+  data: { file_name?: string; description?: string },
+) {
+  return request<API.Attachment>(`/api/attachments/${id}`, {
+    method: 'PATCH',
+    headers: ifMatch(version),
+    data,
+  });
+}
+
+export async function deleteAttachment(id: number, version: number) {
+  return request(`/api/attachments/${id}`, {
+    method: 'DELETE',
+    headers: ifMatch(version),
+  });
+}
+
+// 下载与缩略图需携带登录凭证，以 Blob 形式获取后再生成对象 URL
+export async function downloadAttachment(id: number) {
+  return request<Blob>(`/api/attachments/${id}/download`, { responseType: 'blob' });
+}
+
+export async function getAttachmentThumbnail(id: number) {
+  return request<Blob>(`/api/attachments/${id}/thumbnail`, { responseType: 'blob' });
+}
+
+// 批量导入：dry_run 时只返回逐行预览；正式导入存在错误行时返回 422，响应体同样为 ImportResult
+function importForm(file: File, options?: API.ImportOptions) {
+  const data = new FormData();
+  data.append('file', file);
+  if (options?.mapping) {
+    data.append('mapping', JSON.stringify(options.mapping));
+  }
+  if (options?.defaults) {
+    data.append('defaults', JSON.stringify(options.defaults));
+  }
+  if (options?.sheet) {
+    data.append('sheet', options.sheet);
+  }
+  if (options?.dry_run) {
+    data.append('dry_run', 'true');
+  }
+  return data;
+}
+
+export async function importStudents(file: File, options?: API.ImportOptions) {
+  return request<API.ImportResult>('/api/import/students', {
+    method: 'POST',
+    data: importForm(file, options),
+  });
+}
+
+export async function importExamResults(file: File, options?: API.ImportOptions) {
+  return request<API.ImportResult>('/api/import/exam-results', {
+    method: 'POST',
+    data: importForm(file, options),
+  });
+}
+
+// 数据导出：筛选参数与对应列表接口一致，以 Blob 形式下载
+export async function exportTable(
+  table: 'students' | 'courses' | 'schedules' | 'exam-results',
+  params?: Record<string, any> & { format?: 'csv' | 'xlsx' },
+) {
+  return request<Blob>(`/api/export/${table}`, { params, responseType: 'blob' });
+}
+
+// 全量备份与恢复，用于在不同部署之间迁移数据
+export async function exportBackup(includeUsers?: boolean) {
+  return request<Blob>('/api/backup/export', {
+    params: includeUsers ? { include_users: true } : undefined,
+    responseType: 'blob',
+  });
+}
+
+export async function importBackup(file: File) {
+  return request<API.BackupImportResult>('/api/backup/import', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    data: await file.text(),
+  });
+}
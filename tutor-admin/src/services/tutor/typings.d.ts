@@ -8,6 +8,7 @@ declare namespace API {
 
   interface Student {
     id: number;
+    version: number;
     name: string;
     parent_phone: string;
     grade: string;
@@ -18,6 +19,7 @@ declare namespace API {
 
   interface Course {
     id: number;
+    version: number;
     name: string;
     description: string;
     created_at?: string;
@@ -26,13 +28,23 @@ declare namespace API {
 
   interface Schedule {
     id: number;
+    version: number;
     student_id: number;
     student?: Student;
     course_id: number;
     course?: Course;
     start_time: string;
     end_time: string;
-    status: 'scheduled' | 'completed' | 'cancelled';
+    status: 'scheduled' | 'in_progress' | 'completed' | 'cancelled';
+    cancelled_by?: 'student' | 'parent' | 'tutor' | '';
+    cancel_reason?: string;
+    cancelled_at?: string | null;
+    late_cancel?: boolean;
+    attendance?: 'present' | 'late' | 'absent' | 'excused' | '';
+    check_in_at?: string | null;
+    check_out_at?: string | null;
+    hours?: number;
+    makeup_credit_id?: number | null;
     created_at?: string;
     updated_at?: string;
   }
@@ -47,6 +59,7 @@ declare namespace API {
 
   interface ExamResult {
     id: number;
+    version: number;
     student_id: number;
     student?: Student;
     course_id: number;
@@ -55,6 +68,7 @@ declare namespace API {
     exam_name: string;
     score: number;
     full_score: number;
+    percentage?: number;
     exam_date: string;
     comment?: string;
     created_at?: string;
@@ -66,4 +80,242 @@ declare namespace API {
     course_id?: number;
     exam_type?: string;
   }
+
+  interface ScoreStats {
+    count: number;
+    mean: number;
+    best: number;
+    worst: number;
+    std_dev: number;
+    latest: number;
+    improvement: number | null;
+  }
+
+  interface ExamScore {
+    exam_result_id: number;
+    exam_type: ExamResult['exam_type'];
+    exam_name: string;
+    exam_date: string;
+    score: number;
+    full_score: number;
+    percentage: number;
+    rank: number;
+    rank_total: number;
+  }
+
+  interface CourseSummary {
+    course_id: number;
+    course_name: string;
+    stats: ScoreStats;
+    by_exam_type: Record<string, ScoreStats>;
+    exams: ExamScore[];
+  }
+
+  interface StudentSummary {
+    student_id: number;
+    student_name: string;
+    overall: ScoreStats;
+    by_exam_type: Record<string, ScoreStats>;
+    courses: CourseSummary[];
+    goals: GoalProgress[];
+  }
+
+  interface ScoreProjection {
+    percentage: number;
+    lower: number;
+    upper: number;
+  }
+
+  interface ScoreAlert {
+    type: 'significant_drop' | 'downtrend' | 'below_pass';
+    student_id: number;
+    student_name: string;
+    course_id: number;
+    course_name: string;
+    exam_result_id: number;
+    exam_date: string;
+    percentage: number;
+    expected: number | null;
+    message: string;
+  }
+
+  interface CourseForecast {
+    course_id: number;
+    course_name: string;
+    count: number;
+    slope: number | null;
+    slope_significant: boolean;
+    moving_average: number | null;
+    next: ScoreProjection | null;
+    alerts: ScoreAlert[];
+  }
+
+  interface ChartSeries {
+    name: string;
+    values: (number | null)[];
+    goal?: boolean;
+  }
+
+  interface ChartData {
+    type: 'line' | 'bar' | 'radar';
+    title: string;
+    labels: string[];
+    series: ChartSeries[];
+    min?: number;
+    max?: number;
+    mark_avg: boolean;
+    show_label: boolean;
+  }
+
+  interface SignedChartUrl {
+    url: string;
+    expires_at: string;
+  }
+
+  interface LessonRecord {
+    id: number;
+    version: number;
+    schedule_id: number;
+    schedule?: Schedule;
+    student_id: number;
+    course_id: number;
+    topics: string;
+    homework: string;
+    tutor_notes: string;
+    rating: number;
+    parent_visible: boolean;
+    created_at?: string;
+    updated_at?: string;
+  }
+
+  interface TimelineParams {
+    course_id?: number;
+    start_date?: string;
+    end_date?: string;
+    parent_visible?: boolean;
+    order?: 'asc' | 'desc';
+  }
+
+  interface Homework {
+    id: number;
+    version: number;
+    student_id: number;
+    student?: Student;
+    course_id: number;
+    course?: Course;
+    schedule_id?: number | null;
+    title: string;
+    description?: string;
+    assigned_at: string;
+    due_date: string;
+    status: 'assigned' | 'submitted' | 'checked';
+    overdue?: boolean;
+    submitted_at?: string | null;
+    checked_at?: string | null;
+    score?: number | null;
+    feedback?: string;
+    created_at?: string;
+    updated_at?: string;
+  }
+
+  interface GoalProgress {
+    goal_id: number;
+    course_id: number;
+    course_name: string;
+    exam_type: string;
+    target_score: number;
+    full_score: number;
+    target_percentage: number;
+    deadline: string;
+    days_left: number;
+    status: 'achieved' | 'missed' | 'on_track' | 'at_risk' | 'no_data';
+    exam_count: number;
+    latest: number | null;
+    best: number | null;
+    gap: number | null;
+    projected: number | null;
+    achieved_at: string | null;
+    achieved_exam_result_id: number | null;
+  }
+
+  interface Goal {
+    id: number;
+    version: number;
+    student_id: number;
+    student?: Student;
+    course_id: number;
+    course?: Course;
+    target_score: number;
+    full_score: number;
+    exam_type?: '' | 'midterm' | 'final' | 'quiz';
+    deadline: string;
+    description?: string;
+    progress?: GoalProgress;
+    created_at?: string;
+    updated_at?: string;
+  }
+
+  interface Attachment {
+    id: number;
+    version: number;
+    owner_type: 'student' | 'exam_result' | 'lesson_record';
+    owner_id: number;
+    file_name: string;
+    content_type: string;
+    size: number;
+    checksum: string;
+    description?: string;
+    has_thumbnail: boolean;
+    created_at?: string;
+    updated_at?: string;
+  }
+
+  interface ImportOptions {
+    mapping?: Record<string, string>; // 字段 → 表头
+    defaults?: Record<string, string>; // 整表统一的字段值
+    sheet?: string;
+    dry_run?: boolean;
+  }
+
+  interface ImportRowResult {
+    row: number;
+    status: 'create' | 'skip' | 'error';
+    id?: number;
+    message?: string;
+    errors?: Record<string, string>;
+    data?: Record<string, any>;
+  }
+
+  interface ImportResult {
+    dry_run: boolean;
+    total: number;
+    created: number;
+    skipped: number;
+    failed: number;
+    rows: ImportRowResult[];
+  }
+
+  interface BackupImportCount {
+    created: number;
+    matched: number;
+  }
+
+  interface BackupImportResult {
+    users: BackupImportCount;
+    students: BackupImportCount;
+    courses: BackupImportCount;
+    tuition_rates: BackupImportCount;
+    lesson_packages: BackupImportCount;
+    schedules: BackupImportCount;
+    makeup_credits: BackupImportCount;
+    package_deductions: BackupImportCount;
+    lesson_records: BackupImportCount;
+    homework: BackupImportCount;
+    invoices: BackupImportCount;
+    payments: BackupImportCount;
+    exam_results: BackupImportCount;
+    goals: BackupImportCount;
+    attachments: BackupImportCount;
+    new_users?: { username: string; password: string }[]; // 新建账号的临时密码，仅返回一次
+  }
 }
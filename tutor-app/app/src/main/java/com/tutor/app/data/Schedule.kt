@@ -12,9 +12,15 @@ data class Schedule(
     @SerializedName("subject")
     val subject: String,
     @SerializedName("status")
-    val status: String, // pending, ongoing, completed
+    val status: String, // scheduled, in_progress, completed, cancelled
+    @SerializedName("attendance")
+    val attendance: String = "", // present, late, absent, excused
     @SerializedName("date")
-    val date: String = "" // yyyy-MM-dd 格式
+    val date: String = "", // yyyy-MM-dd 格式
+    @SerializedName("pending_homework")
+    val pendingHomework: Int = 0, // 该科目未提交的作业数
+    @SerializedName("overdue_homework")
+    val overdueHomework: Int = 0 // 其中已逾期的作业数
 ) {
     val startTime: String
         get() = timeSlot.split("-").firstOrNull() ?: ""
@@ -23,8 +29,12 @@ data class Schedule(
         get() = timeSlot.split("-").getOrNull(1) ?: ""
     
     val isOngoing: Boolean
-        get() = status == "ongoing"
+        get() = status == "in_progress"
     
     val isCompleted: Boolean
         get() = status == "completed"
+
+    // 已上完或已取消（含缺勤、请假）的课程
+    val isFinished: Boolean
+        get() = status == "completed" || status == "cancelled"
 }
@@ -175,7 +175,7 @@ class LessonReminderManager(private val context: Context) {
      */
     fun scheduleReminders(schedules: List<Schedule>) {
         schedules.forEach { schedule ->
-            if (schedule.status == "pending") {
+            if (schedule.status == "scheduled") {
                 scheduleReminder(schedule)
             }
         }
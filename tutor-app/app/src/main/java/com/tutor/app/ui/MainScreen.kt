@@ -589,13 +589,13 @@ private fun ScheduleCard(schedule: Schedule, currentDate: LocalDate) {
     
     val backgroundColor = when {
         schedule.isOngoing -> Color(0xFF4CAF50)
-        schedule.isCompleted -> Color(0xFFE0E0E0)
+        schedule.isFinished -> Color(0xFFE0E0E0)
         else -> Color.White
     }
 
     val textColor = when {
         schedule.isOngoing -> Color.White
-        schedule.isCompleted -> Color.Gray
+        schedule.isFinished -> Color.Gray
         else -> Color.Black
     }
     
@@ -686,6 +686,19 @@ private fun ScheduleCard(schedule: Schedule, currentDate: LocalDate) {
                     color = if (schedule.isOngoing) Color.White.copy(alpha = 0.7f)
                            else Color.Gray
                 )
+                if (schedule.pendingHomework > 0) {
+                    Text(
+                        text = if (schedule.overdueHomework > 0)
+                            "作业未交 ${schedule.pendingHomework} 项（逾期 ${schedule.overdueHomework} 项）"
+                        else "作业未交 ${schedule.pendingHomework} 项",
+                        fontSize = 12.sp,
+                        color = when {
+                            schedule.isOngoing -> Color.White
+                            schedule.overdueHomework > 0 -> Color(0xFFF44336)
+                            else -> Color(0xFFFF9800)
+                        }
+                    )
+                }
             }
 
             if (schedule.isOngoing) {
@@ -701,7 +714,7 @@ private fun ScheduleCard(schedule: Schedule, currentDate: LocalDate) {
                         color = Color(0xFF4CAF50)
                     )
                 }
-            } else if (!schedule.isCompleted) {
+            } else if (!schedule.isFinished) {
                 Icon(
                     imageVector = Icons.Default.Notifications,
                     contentDescription = "添加提醒",
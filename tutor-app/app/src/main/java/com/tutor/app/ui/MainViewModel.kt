@@ -189,8 +189,8 @@ class MainViewModel(application: Application) : AndroidViewModel(application) {
     private fun scheduleReminders(schedules: List<Schedule>) {
         schedules.forEach { schedule ->
             when (schedule.status) {
-                "pending" -> reminderManager.scheduleReminder(schedule)
-                "ongoing" -> reminderManager.showOngoingLessonNotification(schedule)
+                "scheduled" -> reminderManager.scheduleReminder(schedule)
+                "in_progress" -> reminderManager.showOngoingLessonNotification(schedule)
             }
         }
     }
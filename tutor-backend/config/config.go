@@ -18,8 +18,80 @@ type DatabaseConfig struct {
 	SQLite   string `yaml:"sqlite"`   // SQLite 文件路径
 }
 
+// BillingConfig 课时包与计费配置
+type BillingConfig struct {
+	LowBalanceThreshold  float64 `yaml:"low_balance_threshold"`   // 课时包余额低于等于该值时预警
+	CancelNoticeHours    float64 `yaml:"cancel_notice_hours"`     // 学生或家长在开课前不足该小时数取消视为迟取消，0 表示不启用
+	LateCancelChargeRate float64 `yaml:"late_cancel_charge_rate"` // 迟取消按课时费与课时的收取比例，1 为全额
+}
+
+// MakeupConfig 补课权益配置
+type MakeupConfig struct {
+	ExpiryDays         int `yaml:"expiry_days"`          // 取消的课程开课后多少天内未安排补课则失效，0 表示不过期
+	JobIntervalMinutes int `yaml:"job_interval_minutes"` // 失效处理任务的执行间隔
+}
+
+// AttendanceConfig 考勤配置
+type AttendanceConfig struct {
+	LateGraceMinutes int `yaml:"late_grace_minutes"` // 开课后超过该分钟数签到记为迟到
+}
+
+// ScheduleConfig 排课状态配置
+type ScheduleConfig struct {
+	AutoCompleteAfterMinutes int `yaml:"auto_complete_after_minutes"` // 下课后多少分钟自动标记为已完成，0 表示不自动完成
+	JobIntervalMinutes       int `yaml:"job_interval_minutes"`        // 自动完成任务的执行间隔
+}
+
+// AnalysisConfig 成绩分析与预警配置，分数均为得分率（%）
+type AnalysisConfig struct {
+	MovingAverageWindow int     `yaml:"moving_average_window"` // 移动平均取最近几次考试
+	PassPercentage      float64 `yaml:"pass_percentage"`       // 及格线，预测下次低于该值时预警
+	MinDropPoints       float64 `yaml:"min_drop_points"`       // 显著下滑至少低于预测值的百分点数
+	AlertLookbackDays   int     `yaml:"alert_lookback_days"`   // 看板只检查最近一次考试在该天数内的科目
+}
+
+// ChartConfig 分析图表配置
+type ChartConfig struct {
+	SigningKey       string `yaml:"signing_key"`        // 图表地址签名密钥，至少 32 个字符，也可通过 CHART_SIGNING_KEY 环境变量提供
+	SignedURLMinutes int    `yaml:"signed_url_minutes"` // 签名图表地址的有效期（分钟）
+	CacheEntries     int    `yaml:"cache_entries"`      // 渲染缓存最多保存的图表数，0 表示不缓存
+	CacheMinutes     int    `yaml:"cache_minutes"`      // 渲染缓存的最长保存时间（分钟）
+}
+
+// minChartSigningKeyLen 图表签名密钥的最短长度
+const minChartSigningKeyLen = 32
+
+// StorageConfig 附件存储配置
+type StorageConfig struct {
+	Type           string   `yaml:"type"`             // local 或 s3
+	LocalPath      string   `yaml:"local_path"`       // 本地存储目录（type 为 local 时使用）
+	MaxUploadMB    int      `yaml:"max_upload_mb"`    // 单个文件大小上限（MB）
+	AllowedTypes   []string `yaml:"allowed_types"`    // 允许上传的 MIME 类型，按文件内容识别
+	ThumbnailSize  int      `yaml:"thumbnail_size"`   // 图片缩略图最长边像素
+	MaxImagePixels int      `yaml:"max_image_pixels"` // 图片宽×高上限，超过的图片拒绝上传，避免解码时占用过多内存
+	S3             S3Config `yaml:"s3"`
+}
+
+// S3Config 兼容 S3 协议的对象存储配置（AWS S3、MinIO 等）
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // 服务地址，不含协议，如 minio:9000
+	Region    string `yaml:"region"`     // 区域，MinIO 可留空
+	Bucket    string `yaml:"bucket"`     // 存储桶，需预先创建
+	AccessKey string `yaml:"access_key"` // 访问密钥
+	SecretKey string `yaml:"secret_key"` // 私有密钥
+	UseSSL    bool   `yaml:"use_ssl"`    // 是否使用 HTTPS
+	PathStyle bool   `yaml:"path_style"` // 使用路径风格访问 bucket，MinIO 需开启
+}
+
 type Config struct {
-	Database DatabaseConfig `yaml:"database"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Billing    BillingConfig    `yaml:"billing"`
+	Makeup     MakeupConfig     `yaml:"makeup"`
+	Attendance AttendanceConfig `yaml:"attendance"`
+	Schedule   ScheduleConfig   `yaml:"schedule"`
+	Storage    StorageConfig    `yaml:"storage"`
+	Analysis   AnalysisConfig   `yaml:"analysis"`
+	Chart      ChartConfig      `yaml:"chart"`
 }
 
 // LoadConfig 加载配置文件
@@ -35,6 +107,41 @@ func LoadConfig() (*Config, error) {
 			DBName:   "tutor",
 			SQLite:   "data/tutor.db",
 		},
+		Billing: BillingConfig{
+			LowBalanceThreshold:  2,
+			CancelNoticeHours:    24,
+			LateCancelChargeRate: 1,
+		},
+		Makeup: MakeupConfig{
+			ExpiryDays:         30,
+			JobIntervalMinutes: 60,
+		},
+		Attendance: AttendanceConfig{
+			LateGraceMinutes: 5,
+		},
+		Schedule: ScheduleConfig{
+			AutoCompleteAfterMinutes: 30,
+			JobIntervalMinutes:       10,
+		},
+		Storage: StorageConfig{
+			Type:           "local",
+			LocalPath:      "data/uploads",
+			MaxUploadMB:    10,
+			AllowedTypes:   []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/heic", "application/pdf"},
+			ThumbnailSize:  320,
+			MaxImagePixels: 40000000,
+		},
+		Analysis: AnalysisConfig{
+			MovingAverageWindow: 3,
+			PassPercentage:      60,
+			MinDropPoints:       5,
+			AlertLookbackDays:   180,
+		},
+		Chart: ChartConfig{
+			SignedURLMinutes: 60,
+			CacheEntries:     200,
+			CacheMinutes:     60,
+		},
 	}
 
 	// 尝试从配置文件加载
@@ -67,6 +174,23 @@ func LoadConfig() (*Config, error) {
 	if sqlite := os.Getenv("DB_SQLITE"); sqlite != "" {
 		config.Database.SQLite = sqlite
 	}
+	if storageType := os.Getenv("STORAGE_TYPE"); storageType != "" {
+		config.Storage.Type = storageType
+	}
+	if accessKey := os.Getenv("S3_ACCESS_KEY"); accessKey != "" {
+		config.Storage.S3.AccessKey = accessKey
+	}
+	if secretKey := os.Getenv("S3_SECRET_KEY"); secretKey != "" {
+		config.Storage.S3.SecretKey = secretKey
+	}
+	if signingKey := os.Getenv("CHART_SIGNING_KEY"); signingKey != "" {
+		config.Chart.SigningKey = signingKey
+	}
+
+	// 签名密钥没有安全的默认值，未配置时拒绝启动
+	if len(config.Chart.SigningKey) < minChartSigningKeyLen {
+		return nil, fmt.Errorf("图表签名密钥 chart.signing_key（或环境变量 CHART_SIGNING_KEY）未配置或少于 %d 个字符", minChartSigningKeyLen)
+	}
 
 	return config, nil
 }
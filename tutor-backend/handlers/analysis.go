@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AnalysisHandler 成绩分析处理器
+type AnalysisHandler struct {
+	DB     *gorm.DB
+	Config config.AnalysisConfig
+}
+
+// NewAnalysisHandler 创建成绩分析处理器
+func NewAnalysisHandler(db *gorm.DB, cfg config.AnalysisConfig) *AnalysisHandler {
+	return &AnalysisHandler{DB: db, Config: cfg}
+}
+
+// ScoreStats 一组成绩的统计，分数均为得分率（%）
+type ScoreStats struct {
+	Count       int      `json:"count"`
+	Mean        float64  `json:"mean"`
+	Best        float64  `json:"best"`
+	Worst       float64  `json:"worst"`
+	StdDev      float64  `json:"std_dev"` // 总体标准差
+	Latest      float64  `json:"latest"`
+	Improvement *float64 `json:"improvement"` // 最近一次相对第一次的变化（百分点），只考过一次时为空
+}
+
+// ExamScore 汇总中的单次考试
+type ExamScore struct {
+	ExamResultID uint      `json:"exam_result_id"`
+	ExamType     string    `json:"exam_type"`
+	ExamName     string    `json:"exam_name"`
+	ExamDate     time.Time `json:"exam_date"`
+	Score        float64   `json:"score"`
+	FullScore    float64   `json:"full_score"`
+	Percentage   float64   `json:"percentage"`
+	Rank         int       `json:"rank"`       // 同一场考试（科目、名称、日期相同）所有学生中按得分率的名次
+	RankTotal    int       `json:"rank_total"` // 同一场考试的参考人数
+}
+
+// CourseSummary 单科成绩汇总
+type CourseSummary struct {
+	CourseID   uint                  `json:"course_id"`
+	CourseName string                `json:"course_name"`
+	Stats      ScoreStats            `json:"stats"`
+	ByExamType map[string]ScoreStats `json:"by_exam_type"`
+	Exams      []ExamScore           `json:"exams"` // 按考试日期升序
+}
+
+// StudentSummary 学生成绩汇总
+type StudentSummary struct {
+	StudentID   uint                  `json:"student_id"`
+	StudentName string                `json:"student_name"`
+	Overall     ScoreStats            `json:"overall"`
+	ByExamType  map[string]ScoreStats `json:"by_exam_type"`
+	Courses     []CourseSummary       `json:"courses"`
+	Goals       []GoalProgress        `json:"goals"` // 学习目标完成情况，不受考试类型与日期筛选影响
+}
+
+// examKey 标识同一场考试，用于计算名次
+type examKey struct {
+	courseID uint
+	name     string
+	date     string
+}
+
+func newExamKey(r models.ExamResult) examKey {
+	return examKey{courseID: r.CourseID, name: r.ExamName, date: r.ExamDate.Format("2006-01-02")}
+}
+
+// GetSummary 学生成绩汇总
+// @Summary 学生成绩汇总
+// @Description 按得分率（分数/满分）统计学生整体、各科目及各考试类型的平均、最好、最差、标准差与进步幅度，并给出每次考试在同场考试中的名次与各学习目标的完成情况
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Produce json
+// @Param student_id path int true "学生ID"
+// @Param course_id query int false "科目ID"
+// @Param exam_type query string false "考试类型"
+// @Param start_date query string false "考试开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "考试结束日期 (yyyy-MM-dd)"
+// @Success 200 {object} StudentSummary
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /analysis/{student_id}/summary [get]
+func (h *AnalysisHandler) GetSummary(c *gin.Context) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("student_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+
+	query := h.DB.Preload("Course").Where("student_id = ?", student.ID)
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if examType := c.Query("exam_type"); examType != "" {
+		query = query.Where("exam_type = ?", examType)
+	}
+	if raw := c.Query("start_date"); raw != "" {
+		start, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use yyyy-MM-dd"})
+			return
+		}
+		query = query.Where("exam_date >= ?", start)
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use yyyy-MM-dd"})
+			return
+		}
+		query = query.Where("exam_date < ?", end.AddDate(0, 0, 1))
+	}
+
+	var results []models.ExamResult
+	if err := query.Order("exam_date ASC, id ASC").Find(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ranks, err := examRanks(h.DB, results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summary := buildStudentSummary(student, results, ranks)
+	if summary.Goals, err = studentGoalProgress(h.DB, student.ID, c.Query("course_id"), c.Query("exam_type"), time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondWithETag(c, summary)
+}
+
+// buildStudentSummary 汇总学生成绩，results 需按考试日期升序
+func buildStudentSummary(student models.Student, results []models.ExamResult, ranks map[uint][2]int) StudentSummary {
+	summary := StudentSummary{
+		StudentID:   student.ID,
+		StudentName: student.Name,
+		Courses:     []CourseSummary{},
+	}
+
+	var overall []float64
+	byType := map[string][]float64{}
+	courseIndex := map[uint]int{}
+	courseByType := map[uint]map[string][]float64{}
+	for _, r := range results {
+		pct := scorePercentage(r)
+		overall = append(overall, pct)
+		byType[r.ExamType] = append(byType[r.ExamType], pct)
+
+		i, ok := courseIndex[r.CourseID]
+		if !ok {
+			name := ""
+			if r.Course != nil {
+				name = r.Course.Name
+			}
+			i = len(summary.Courses)
+			courseIndex[r.CourseID] = i
+			courseByType[r.CourseID] = map[string][]float64{}
+			summary.Courses = append(summary.Courses, CourseSummary{CourseID: r.CourseID, CourseName: name})
+		}
+		rank := ranks[r.ID]
+		summary.Courses[i].Exams = append(summary.Courses[i].Exams, ExamScore{
+			ExamResultID: r.ID,
+			ExamType:     r.ExamType,
+			ExamName:     r.ExamName,
+			ExamDate:     r.ExamDate,
+			Score:        r.Score,
+			FullScore:    r.FullScore,
+			Percentage:   pct,
+			Rank:         rank[0],
+			RankTotal:    rank[1],
+		})
+		courseByType[r.CourseID][r.ExamType] = append(courseByType[r.CourseID][r.ExamType], pct)
+	}
+
+	summary.Overall = scoreStats(overall)
+	summary.ByExamType = statsByType(byType)
+	for i := range summary.Courses {
+		course := &summary.Courses[i]
+		values := make([]float64, 0, len(course.Exams))
+		for _, e := range course.Exams {
+			values = append(values, e.Percentage)
+		}
+		course.Stats = scoreStats(values)
+		course.ByExamType = statsByType(courseByType[course.CourseID])
+	}
+	sort.SliceStable(summary.Courses, func(i, j int) bool {
+		return summary.Courses[i].CourseName < summary.Courses[j].CourseName
+	})
+	return summary
+}
+
+// examRanks 计算每条成绩在同一场考试所有学生中的名次，返回 成绩ID → [名次, 参考人数]
+// 得分率相同的名次并列
+func examRanks(db *gorm.DB, results []models.ExamResult) (map[uint][2]int, error) {
+	ranks := make(map[uint][2]int, len(results))
+	if len(results) == 0 {
+		return ranks, nil
+	}
+	keys := map[examKey]bool{}
+	courseIDs := map[uint]bool{}
+	names := map[string]bool{}
+	for _, r := range results {
+		keys[newExamKey(r)] = true
+		courseIDs[r.CourseID] = true
+		names[r.ExamName] = true
+	}
+
+	var peers []models.ExamResult
+	err := db.Select("id", "course_id", "exam_name", "exam_date", "score", "full_score").
+		Where("course_id IN ? AND exam_name IN ?", mapKeys(courseIDs), mapKeys(names)).
+		Find(&peers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[examKey][]models.ExamResult{}
+	for _, p := range peers {
+		key := newExamKey(p)
+		if keys[key] {
+			groups[key] = append(groups[key], p)
+		}
+	}
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return scorePercentage(group[i]) > scorePercentage(group[j])
+		})
+		rank := 0
+		for i, p := range group {
+			if i == 0 || scorePercentage(p) < scorePercentage(group[i-1]) {
+				rank = i + 1
+			}
+			ranks[p.ID] = [2]int{rank, len(group)}
+		}
+	}
+	return ranks, nil
+}
+
+// scorePercentage 得分率（%），保留两位小数
+func scorePercentage(r models.ExamResult) float64 {
+	if r.FullScore <= 0 {
+		return 0
+	}
+	return math.Round(r.Score/r.FullScore*10000) / 100
+}
+
+// scoreStats 统计一组按时间先后排列的得分率
+func scoreStats(values []float64) ScoreStats {
+	stats := ScoreStats{Count: len(values)}
+	if len(values) == 0 {
+		return stats
+	}
+	stats.Best, stats.Worst = values[0], values[0]
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		stats.Best = math.Max(stats.Best, v)
+		stats.Worst = math.Min(stats.Worst, v)
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	stats.Mean = math.Round(mean*100) / 100
+	stats.StdDev = math.Round(math.Sqrt(variance/float64(len(values)))*100) / 100
+	stats.Latest = values[len(values)-1]
+	if len(values) > 1 {
+		improvement := math.Round((stats.Latest-values[0])*100) / 100
+		stats.Improvement = &improvement
+	}
+	return stats
+}
+
+func statsByType(values map[string][]float64) map[string]ScoreStats {
+	result := make(map[string]ScoreStats, len(values))
+	for examType, v := range values {
+		result[examType] = scoreStats(v)
+	}
+	return result
+}
+
+func mapKeys[K comparable](m map[K]bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
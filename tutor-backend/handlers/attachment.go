@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+	"tutor-management/storage"
+	"tutor-management/utils"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+// 附件可关联的记录类型
+const (
+	AttachmentOwnerStudent      = "student"
+	AttachmentOwnerExamResult   = "exam_result"
+	AttachmentOwnerLessonRecord = "lesson_record"
+)
+
+// attachmentOwnerModels 附件关联类型对应的模型，用于校验关联记录是否存在
+var attachmentOwnerModels = map[string]func() any{
+	AttachmentOwnerStudent:      func() any { return &models.Student{} },
+	AttachmentOwnerExamResult:   func() any { return &models.ExamResult{} },
+	AttachmentOwnerLessonRecord: func() any { return &models.LessonRecord{} },
+}
+
+type AttachmentHandler struct {
+	DB      *gorm.DB
+	Storage storage.Storage
+	Config  config.StorageConfig
+}
+
+func NewAttachmentHandler(db *gorm.DB, store storage.Storage, cfg config.StorageConfig) *AttachmentHandler {
+	return &AttachmentHandler{DB: db, Storage: store, Config: cfg}
+}
+
+// UploadAttachmentRequest 上传附件的表单字段，文件放在 file 字段
+type UploadAttachmentRequest struct {
+	OwnerType   string `json:"owner_type" form:"owner_type" binding:"required,oneof=student exam_result lesson_record"`
+	OwnerID     uint   `json:"owner_id" form:"owner_id" binding:"required"`
+	Description string `json:"description" form:"description" binding:"max=500"`
+}
+
+// UpdateAttachmentRequest 更新附件信息请求，未提供的字段保持不变
+type UpdateAttachmentRequest struct {
+	FileName    *string `json:"file_name" binding:"omitempty,notblank,max=255"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+}
+
+// AttachmentResponse 附件信息
+type AttachmentResponse struct {
+	ID           uint      `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Version      uint      `json:"version"`
+	OwnerType    string    `json:"owner_type"`
+	OwnerID      uint      `json:"owner_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Checksum     string    `json:"checksum"`
+	Description  string    `json:"description"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+}
+
+func (r UpdateAttachmentRequest) apply(a *models.Attachment) {
+	if r.FileName != nil {
+		a.FileName = *r.FileName
+	}
+	if r.Description != nil {
+		a.Description = *r.Description
+	}
+}
+
+func newAttachmentResponse(a models.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:           a.ID,
+		CreatedAt:    a.CreatedAt,
+		UpdatedAt:    a.UpdatedAt,
+		Version:      a.Version,
+		OwnerType:    a.OwnerType,
+		OwnerID:      a.OwnerID,
+		FileName:     a.FileName,
+		ContentType:  a.ContentType,
+		Size:         a.Size,
+		Checksum:     a.Checksum,
+		Description:  a.Description,
+		HasThumbnail: a.ThumbnailKey != "",
+	}
+}
+
+// attachmentListSpec 附件列表允许的排序与筛选字段
+var attachmentListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+		"file_name":  "file_name",
+		"size":       "size",
+	},
+	DefaultSort: "created_at DESC",
+	Filters: map[string]string{
+		"owner_type":   "owner_type",
+		"owner_id":     "owner_id",
+		"content_type": "content_type",
+	},
+	LikeFilters: map[string]string{"file_name": "file_name"},
+	DateRange:   "created_at",
+}
+
+// GetAll 获取附件列表
+// @Summary 获取附件列表
+// @Description 通常按 owner_type + owner_id 查询某条记录的附件；携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 附件管理
+// @Security BearerAuth
+// @Produce json
+// @Param owner_type query string false "关联类型: student, exam_result, lesson_record"
+// @Param owner_id query int false "关联记录ID"
+// @Param content_type query string false "文件类型，如 image/jpeg"
+// @Param file_name query string false "文件名（模糊匹配）"
+// @Param start_date query string false "上传开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "上传结束日期 (yyyy-MM-dd)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, created_at, file_name, size（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Success 200 {array} AttachmentResponse
+// @Failure 400 {object} map[string]string
+// @Router /attachments [get]
+func (h *AttachmentHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, attachmentListSpec, newAttachmentResponse)
+}
+
+// Upload 上传附件
+// @Summary 上传附件
+// @Description multipart/form-data 上传，文件类型按内容识别，须在允许的类型内且不超过大小上限；图片会同时生成缩略图
+// @Tags 附件管理
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "文件"
+// @Param owner_type formData string true "关联类型: student, exam_result, lesson_record"
+// @Param owner_id formData int true "关联记录ID"
+// @Param description formData string false "说明"
+// @Success 201 {object} AttachmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /attachments [post]
+func (h *AttachmentHandler) Upload(c *gin.Context) {
+	maxSize := int64(h.Config.MaxUploadMB) << 20
+	// 预留表单其他字段的空间，超出后解析表单直接失败，避免读入过大的请求体
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	var req UploadAttachmentRequest
+	if err := c.ShouldBind(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", h.Config.MaxUploadMB)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("file", "不能为空"))
+		return
+	}
+	if header.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", h.Config.MaxUploadMB)})
+		return
+	}
+	if err := h.DB.First(attachmentOwnerModels[req.OwnerType](), req.OwnerID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("owner_id", "关联记录不存在"))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	mtype, err := mimetype.DetectReader(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.typeAllowed(mtype) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("不支持的文件类型: %s", mtype.String())})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.HasPrefix(mtype.String(), "image/") {
+		if err := checkImagePixels(file, h.Config.MaxImagePixels); err != nil {
+			if errors.Is(err, errImageTooLarge) {
+				c.JSON(http.StatusBadRequest, fieldError("file", fmt.Sprintf("图片像素不能超过 %d", h.Config.MaxImagePixels)))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	attachment := models.Attachment{
+		Version:     1,
+		OwnerType:   req.OwnerType,
+		OwnerID:     req.OwnerID,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: mtype.String(),
+		Size:        header.Size,
+		Description: req.Description,
+		StorageKey:  attachmentKey(req.OwnerType, req.OwnerID, mtype.Extension()),
+	}
+	hash := sha256.New()
+	ctx := c.Request.Context()
+	if err := h.Storage.Put(ctx, attachment.StorageKey, io.TeeReader(file, hash), attachment.Size, attachment.ContentType); err != nil {
+		utils.Error("Failed to store attachment", zap.String("key", attachment.StorageKey), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
+		return
+	}
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	// 缩略图生成失败（如 HEIC 等无法解码的图片）不影响上传
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		if thumbKey, err := h.storeThumbnail(c, file, attachment.StorageKey); err != nil {
+			utils.Warn("Failed to create thumbnail", zap.String("key", attachment.StorageKey), zap.Error(err))
+		} else {
+			attachment.ThumbnailKey = thumbKey
+		}
+	}
+
+	if err := h.DB.Create(&attachment).Error; err != nil {
+		h.removeObjects(attachment)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondVersioned(c, http.StatusCreated, attachment.Version, newAttachmentResponse(attachment))
+}
+
+// Get 获取附件信息
+// @Summary 获取附件信息
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 附件管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "附件ID"
+// @Success 200 {object} AttachmentResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /attachments/{id} [get]
+func (h *AttachmentHandler) Get(c *gin.Context) {
+	var attachment models.Attachment
+	if err := h.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, attachment.Version, newAttachmentResponse(attachment))
+}
+
+// Download 下载附件
+// @Summary 下载附件
+// @Description 默认以下载方式返回，inline=true 时供浏览器直接预览
+// @Tags 附件管理
+// @Security BearerAuth
+// @Produce octet-stream
+// @Param id path int true "附件ID"
+// @Param inline query bool false "是否在浏览器中直接打开"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /attachments/{id}/download [get]
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	var attachment models.Attachment
+	if err := h.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	disposition := "attachment"
+	if inline, _ := strconv.ParseBool(c.Query("inline")); inline {
+		disposition = "inline"
+	}
+	h.serveObject(c, attachment.StorageKey, attachment.ContentType, attachment.Size,
+		fmt.Sprintf(`%s; filename="attachment-%d%s"; filename*=UTF-8''%s`,
+			disposition, attachment.ID, filepath.Ext(attachment.FileName), url.PathEscape(attachment.FileName)))
+}
+
+// Thumbnail 获取图片缩略图
+// @Summary 获取附件缩略图
+// @Description 仅图片附件有缩略图，统一为 JPEG
+// @Tags 附件管理
+// @Security BearerAuth
+// @Produce jpeg
+// @Param id path int true "附件ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /attachments/{id}/thumbnail [get]
+func (h *AttachmentHandler) Thumbnail(c *gin.Context) {
+	var attachment models.Attachment
+	if err := h.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	if attachment.ThumbnailKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该附件没有缩略图"})
+		return
+	}
+	h.serveObject(c, attachment.ThumbnailKey, "image/jpeg", -1, "")
+}
+
+// Update 更新附件信息
+// @Summary 更新附件信息
+// @Description 仅可修改文件名与说明；需携带 If-Match，版本不一致返回 412
+// @Tags 附件管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "附件ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param attachment body UpdateAttachmentRequest true "附件信息"
+// @Success 200 {object} AttachmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /attachments/{id} [patch]
+func (h *AttachmentHandler) Update(c *gin.Context) {
+	var attachment models.Attachment
+	if err := h.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	if !checkIfMatch(c, attachment.Version) {
+		return
+	}
+	var req UpdateAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	req.apply(&attachment)
+	if err := saveVersioned(h.DB, &attachment, &attachment.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	respondVersioned(c, http.StatusOK, attachment.Version, newAttachmentResponse(attachment))
+}
+
+// Delete 删除附件
+// @Summary 删除附件
+// @Description 同时删除存储中的文件与缩略图
+// @Tags 附件管理
+// @Security BearerAuth
+// @Param id path int true "附件ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /attachments/{id} [delete]
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	var attachment models.Attachment
+	if err := h.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	if !checkIfMatch(c, attachment.Version) {
+		return
+	}
+	if err := deleteVersioned(h.DB, &attachment, attachment.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.removeObjects(attachment)
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// serveObject 从存储读取对象写入响应，size 未知时传 -1
+func (h *AttachmentHandler) serveObject(c *gin.Context, key, contentType string, size int64, disposition string) {
+	obj, err := h.Storage.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		utils.Error("Failed to open attachment", zap.String("key", key), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败: " + err.Error()})
+		return
+	}
+	defer obj.Close()
+
+	headers := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+	}
+	if disposition != "" {
+		headers["Content-Disposition"] = disposition
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, obj, headers)
+}
+
+// storeThumbnail 生成并保存图片缩略图，返回缩略图的存储 key
+func (h *AttachmentHandler) storeThumbnail(c *gin.Context, file multipart.File, key string) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := makeThumbnail(file, h.Config.ThumbnailSize, h.Config.MaxImagePixels)
+	if err != nil {
+		return "", err
+	}
+	thumbKey := strings.TrimSuffix(key, filepath.Ext(key)) + "_thumb.jpg"
+	if err := h.Storage.Put(c.Request.Context(), thumbKey, bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+		return "", err
+	}
+	return thumbKey, nil
+}
+
+// removeObjects 删除附件在存储中的文件
+func (h *AttachmentHandler) removeObjects(a models.Attachment) {
+	removeAttachmentObjects(h.Storage, []models.Attachment{a})
+}
+
+// deleteOwnerAttachments 在事务中删除关联记录的全部附件记录，返回被删除的附件
+// 存储中的文件无法回滚，需在事务提交后再用 removeAttachmentObjects 删除
+func deleteOwnerAttachments(tx *gorm.DB, ownerType string, ownerIDs []uint) ([]models.Attachment, error) {
+	if len(ownerIDs) == 0 {
+		return nil, nil
+	}
+	var attachments []models.Attachment
+	if err := tx.Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	return attachments, tx.Delete(&attachments).Error
+}
+
+// removeAttachmentObjects 删除附件在存储中的文件与缩略图，失败只记录日志，残留文件不影响业务
+func removeAttachmentObjects(store storage.Storage, attachments []models.Attachment) {
+	for _, a := range attachments {
+		for _, key := range []string{a.StorageKey, a.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := store.Delete(context.Background(), key); err != nil {
+				utils.Warn("Failed to delete attachment object", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (h *AttachmentHandler) typeAllowed(mtype *mimetype.MIME) bool {
+	for _, allowed := range h.Config.AllowedTypes {
+		if mtype.Is(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentKey 生成附件的存储 key：{关联类型}/{关联ID}/{随机串}{扩展名}
+func attachmentKey(ownerType string, ownerID uint, ext string) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return fmt.Sprintf("%s/%d/%s%s", ownerType, ownerID, hex.EncodeToString(buf), ext)
+}
+
+// errImageTooLarge 图片像素数超过上限
+var errImageTooLarge = errors.New("图片像素数超过上限")
+
+// checkImagePixels 只读取图片头部声明的尺寸，宽×高超过 maxPixels 时返回 errImageTooLarge
+// 无法识别的图片格式不限制（也不会生成缩略图）；读取后文件位置恢复到开头
+func checkImagePixels(r io.ReadSeeker, maxPixels int) error {
+	cfg, _, err := image.DecodeConfig(r)
+	if _, seekErr := r.Seek(0, io.SeekStart); seekErr != nil {
+		return seekErr
+	}
+	if err == nil && int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return errImageTooLarge
+	}
+	return nil
+}
+
+// makeThumbnail 将图片等比缩放到最长边不超过 maxSide，编码为 JPEG
+// 解码前先检查声明的尺寸，防止小文件声明超大尺寸导致解码时分配大量内存
+func makeThumbnail(r io.ReadSeeker, maxSide, maxPixels int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return nil, errImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			w, h = maxSide, max(1, h*maxSide/w)
+		} else {
+			w, h = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// 透明背景的 PNG/GIF 转 JPEG 时铺白底
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// pngHeader 只包含签名与 IHDR 的 PNG，声明任意尺寸而不携带像素数据
+func pngHeader(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	chunk := make([]byte, 17)
+	copy(chunk, "IHDR")
+	binary.BigEndian.PutUint32(chunk[4:], width)
+	binary.BigEndian.PutUint32(chunk[8:], height)
+	chunk[12] = 8 // 位深
+	chunk[13] = 6 // RGBA
+	binary.Write(&buf, binary.BigEndian, uint32(13))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckImagePixels(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"小图片", encodePNG(t, 10, 10), nil},
+		{"恰好等于上限", pngHeader(100, 100), nil},
+		{"声明尺寸超过上限", pngHeader(100000, 100000), errImageTooLarge},
+		{"无法识别的格式", []byte("not an image"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.data)
+			if err := checkImagePixels(r, 10000); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkImagePixels() = %v, want %v", err, tt.wantErr)
+			}
+			if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+				t.Errorf("读取后文件位置为 %d，应恢复到开头", pos)
+			}
+		})
+	}
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantW, wantH int
+		wantErr      error
+	}{
+		{"横图缩放", encodePNG(t, 400, 200), 100, 50, nil},
+		{"竖图缩放", encodePNG(t, 50, 200), 25, 100, nil},
+		{"小图保持原尺寸", encodePNG(t, 40, 30), 40, 30, nil},
+		{"超大尺寸不解码", pngHeader(100000, 100000), 0, 0, errImageTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := makeThumbnail(bytes.NewReader(tt.data), 100, 1000000)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("makeThumbnail() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("缩略图不是 JPEG: %v", err)
+			}
+			if cfg.Width != tt.wantW || cfg.Height != tt.wantH {
+				t.Errorf("缩略图尺寸 = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 考勤状态
+const (
+	AttendancePresent = "present"
+	AttendanceLate    = "late"
+	AttendanceAbsent  = "absent"
+	AttendanceExcused = "excused"
+)
+
+// CheckInRequest 签到请求
+type CheckInRequest struct {
+	Time       *time.Time `json:"time"`                                              // 签到时间，默认当前时间
+	Attendance string     `json:"attendance" binding:"omitempty,oneof=present late"` // 默认按迟到宽限时间自动判定
+}
+
+// CheckOutRequest 签退请求
+type CheckOutRequest struct {
+	Time *time.Time `json:"time"` // 签退时间，默认当前时间
+}
+
+// AbsenceRequest 登记缺勤或请假请求
+type AbsenceRequest struct {
+	Attendance string `json:"attendance" binding:"required,oneof=absent excused"`
+	Reason     string `json:"reason" binding:"max=500"`
+}
+
+// CheckIn 签到
+// @Summary 课程签到
+// @Description 记录实际上课开始时间，已排课的课程变为上课中；未指定 attendance 时，开课后超过迟到宽限时间签到记为 late。可重复调用以更正签到时间
+// @Tags 排课管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "排课ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param request body CheckInRequest false "签到信息"
+// @Success 200 {object} ScheduleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /schedules/{id}/check-in [post]
+func (h *ScheduleHandler) CheckIn(c *gin.Context) {
+	var schedule models.Schedule
+	if err := h.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
+		return
+	}
+	if !checkIfMatch(c, schedule.Version) {
+		return
+	}
+	var req CheckInRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, validationError(err))
+			return
+		}
+	}
+	if schedule.Status == ScheduleCancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "已取消的课程不能签到"})
+		return
+	}
+
+	before := schedule
+	checkIn := time.Now()
+	if req.Time != nil {
+		checkIn = *req.Time
+	}
+	if schedule.CheckOutAt != nil && !schedule.CheckOutAt.After(checkIn) {
+		c.JSON(http.StatusBadRequest, fieldError("time", "必须早于签退时间"))
+		return
+	}
+	schedule.CheckInAt = &checkIn
+	schedule.Attendance = req.Attendance
+	if schedule.Attendance == "" {
+		schedule.Attendance = arrivalAttendance(schedule, h.Attendance)
+	}
+	// 已排课的课程进入上课中，上课中或已完成的课程仅更正签到时间
+	if schedule.Status == ScheduleScheduled {
+		schedule.Status = ScheduleInProgress
+	}
+	h.saveAndRespond(c, before, schedule)
+}
+
+// CheckOut 签退
+// @Summary 课程签退
+// @Description 记录实际下课时间并将课程标记为已完成，计费时长按签到至签退的实际时间计算
+// @Tags 排课管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "排课ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param request body CheckOutRequest false "签退信息"
+// @Success 200 {object} ScheduleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /schedules/{id}/check-out [post]
+func (h *ScheduleHandler) CheckOut(c *gin.Context) {
+	var schedule models.Schedule
+	if err := h.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
+		return
+	}
+	if !checkIfMatch(c, schedule.Version) {
+		return
+	}
+	var req CheckOutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, validationError(err))
+			return
+		}
+	}
+	if schedule.CheckInAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "课程尚未签到"})
+		return
+	}
+
+	before := schedule
+	checkOut := time.Now()
+	if req.Time != nil {
+		checkOut = *req.Time
+	}
+	if !checkOut.After(*schedule.CheckInAt) {
+		c.JSON(http.StatusBadRequest, fieldError("time", "必须晚于签到时间"))
+		return
+	}
+	schedule.CheckOutAt = &checkOut
+	schedule.Status = ScheduleCompleted
+	h.saveAndRespond(c, before, schedule)
+}
+
+// MarkAbsence 登记缺勤或请假
+// @Summary 登记缺勤或请假
+// @Description 课程记为学生取消：absent（无故缺勤）按迟取消政策计费，excused（请假）不计费并生成补课权益
+// @Tags 排课管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "排课ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param request body AbsenceRequest true "缺勤信息"
+// @Success 200 {object} ScheduleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /schedules/{id}/absence [post]
+func (h *ScheduleHandler) MarkAbsence(c *gin.Context) {
+	var schedule models.Schedule
+	if err := h.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
+		return
+	}
+	if !checkIfMatch(c, schedule.Version) {
+		return
+	}
+	var req AbsenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+
+	if !scheduleTransitionAllowed(schedule.Status, ScheduleCancelled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "课程已取消"})
+		return
+	}
+
+	before := schedule
+	schedule.Status = ScheduleCancelled
+	schedule.Attendance = req.Attendance
+	schedule.CancelledBy = "student"
+	schedule.CancelReason = req.Reason
+	schedule.CheckInAt = nil
+	schedule.CheckOutAt = nil
+	h.saveAndRespond(c, before, schedule)
+}
+
+// arrivalAttendance 按签到时间判定出勤或迟到
+func arrivalAttendance(s models.Schedule, cfg config.AttendanceConfig) string {
+	grace := time.Duration(cfg.LateGraceMinutes) * time.Minute
+	if s.CheckInAt != nil && s.CheckInAt.After(s.StartTime.Add(grace)) {
+		return AttendanceLate
+	}
+	return AttendancePresent
+}
+
+// normalizeAttendance 清理与课程状态不符的考勤信息：
+// 取消的课程只保留缺勤/请假，其余状态不保留缺勤/请假
+func normalizeAttendance(s *models.Schedule) {
+	absent := s.Attendance == AttendanceAbsent || s.Attendance == AttendanceExcused
+	if s.Status == ScheduleCancelled {
+		if !absent {
+			s.Attendance = ""
+		}
+		s.CheckInAt = nil
+		s.CheckOutAt = nil
+	} else if absent {
+		s.Attendance = ""
+	}
+}
@@ -0,0 +1,1349 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 备份文件格式
+const (
+	backupFormat  = "tutor-management-backup"
+	backupVersion = 2
+	maxBackupSize = 100 << 20
+)
+
+// BackupHandler 全量备份与恢复处理器，用于在不同部署之间迁移数据
+type BackupHandler struct {
+	DB         *gorm.DB
+	ChartCache *ChartCache // 恢复成绩后清除相关学生的图表缓存
+}
+
+// NewBackupHandler 创建备份处理器
+func NewBackupHandler(db *gorm.DB, chartCache *ChartCache) *BackupHandler {
+	return &BackupHandler{DB: db, ChartCache: chartCache}
+}
+
+// Backup 备份文件，记录之间通过原库中的 ID 关联，导入时重新分配
+// 每条记录的校验规则与对应的创建接口一致
+// 包含账号（可选，不含密码）与全部业务数据；附件只含元数据，文件本身需另行复制存储目录或存储桶
+// 版本 1 只有学生、课程、排课与成绩，仍可导入
+type Backup struct {
+	Format            string                   `json:"format"`  // 固定为 tutor-management-backup
+	Version           int                      `json:"version"` // 格式版本
+	ExportedAt        time.Time                `json:"exported_at"`
+	Users             []BackupUser             `json:"users,omitempty"`
+	Students          []BackupStudent          `json:"students"`
+	Courses           []BackupCourse           `json:"courses"`
+	TuitionRates      []BackupTuitionRate      `json:"tuition_rates"`
+	LessonPackages    []BackupLessonPackage    `json:"lesson_packages"`
+	Schedules         []BackupSchedule         `json:"schedules"`
+	MakeupCredits     []BackupMakeupCredit     `json:"makeup_credits"`
+	PackageDeductions []BackupPackageDeduction `json:"package_deductions"`
+	LessonRecords     []BackupLessonRecord     `json:"lesson_records"`
+	Homework          []BackupHomework         `json:"homework"`
+	Invoices          []BackupInvoice          `json:"invoices"`
+	Payments          []BackupPayment          `json:"payments"`
+	ExamResults       []BackupExamResult       `json:"exam_results"`
+	Goals             []BackupGoal             `json:"goals"`
+	Attachments       []BackupAttachment       `json:"attachments"`
+}
+
+// BackupUser 账号，不含密码
+type BackupUser struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Username  string    `json:"username" binding:"required,notblank"`
+	Name      string    `json:"name"`
+	Avatar    string    `json:"avatar"`
+}
+
+// BackupStudent 学生
+type BackupStudent struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `json:"name" binding:"required,notblank,max=50"`
+	ParentPhone string    `json:"parent_phone" binding:"omitempty,cnmobile"`
+	Grade       string    `json:"grade" binding:"max=20"`
+	Notes       string    `json:"notes" binding:"max=2000"`
+}
+
+// BackupCourse 课程
+type BackupCourse struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `json:"name" binding:"required,notblank,max=50"`
+	Description string    `json:"description" binding:"max=500"`
+}
+
+// BackupSchedule 排课
+type BackupSchedule struct {
+	ID           uint       `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StudentID    uint       `json:"student_id" binding:"required"`
+	CourseID     uint       `json:"course_id" binding:"required"`
+	StartTime    time.Time  `json:"start_time" binding:"required"`
+	EndTime      time.Time  `json:"end_time" binding:"required,gtfield=StartTime"`
+	Status       string     `json:"status" binding:"required,oneof=scheduled in_progress completed cancelled"`
+	CancelledBy  string     `json:"cancelled_by" binding:"omitempty,oneof=student parent tutor"`
+	CancelReason string     `json:"cancel_reason" binding:"max=500"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	LateCancel   bool       `json:"late_cancel"`
+	Attendance   string     `json:"attendance" binding:"omitempty,oneof=present late absent excused"`
+	CheckInAt    *time.Time `json:"check_in_at"`
+	CheckOutAt   *time.Time `json:"check_out_at"`
+
+	MakeupCreditID *uint `json:"makeup_credit_id" binding:"omitempty,gt=0"` // 作为补课时使用的补课权益
+}
+
+// BackupTuitionRate 课时单价
+type BackupTuitionRate struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CourseID   uint      `json:"course_id" binding:"required"`
+	Grade      string    `json:"grade" binding:"max=20"`
+	StudentID  uint      `json:"student_id"` // 0 表示通用价格
+	HourlyRate float64   `json:"hourly_rate" binding:"gte=0"`
+	Notes      string    `json:"notes" binding:"max=500"`
+}
+
+// BackupLessonPackage 课时包
+type BackupLessonPackage struct {
+	ID          uint       `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StudentID   uint       `json:"student_id" binding:"required"`
+	CourseID    uint       `json:"course_id" binding:"required"`
+	Unit        string     `json:"unit" binding:"required,oneof=hour lesson"`
+	Quantity    float64    `json:"quantity" binding:"required,gt=0"`
+	Used        float64    `json:"used" binding:"gte=0"`
+	Price       float64    `json:"price" binding:"gte=0"`
+	PurchasedAt time.Time  `json:"purchased_at" binding:"required"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Notes       string     `json:"notes" binding:"max=500"`
+}
+
+// BackupPackageDeduction 课时包扣减记录
+type BackupPackageDeduction struct {
+	ID         uint       `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	PackageID  uint       `json:"package_id" binding:"required"`
+	ScheduleID uint       `json:"schedule_id" binding:"required"`
+	Amount     float64    `json:"amount" binding:"gte=0"`
+	ReversedAt *time.Time `json:"reversed_at"`
+}
+
+// BackupMakeupCredit 补课权益
+type BackupMakeupCredit struct {
+	ID                 uint       `json:"id"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	StudentID          uint       `json:"student_id" binding:"required"`
+	CourseID           uint       `json:"course_id" binding:"required"`
+	SourceScheduleID   uint       `json:"source_schedule_id" binding:"required"`
+	Hours              float64    `json:"hours" binding:"gte=0"`
+	Status             string     `json:"status" binding:"required,oneof=pending redeemed expired"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	RedeemedScheduleID *uint      `json:"redeemed_schedule_id" binding:"omitempty,gt=0"`
+	RedeemedAt         *time.Time `json:"redeemed_at"`
+	Notes              string     `json:"notes" binding:"max=500"`
+}
+
+// BackupLessonRecord 课堂记录
+type BackupLessonRecord struct {
+	ID            uint      `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	ScheduleID    uint      `json:"schedule_id" binding:"required"`
+	StudentID     uint      `json:"student_id" binding:"required"`
+	CourseID      uint      `json:"course_id" binding:"required"`
+	Topics        string    `json:"topics" binding:"max=2000"`
+	Homework      string    `json:"homework" binding:"max=2000"`
+	TutorNotes    string    `json:"tutor_notes" binding:"max=2000"`
+	Rating        int       `json:"rating" binding:"min=0,max=5"`
+	ParentVisible bool      `json:"parent_visible"`
+}
+
+// BackupHomework 作业
+type BackupHomework struct {
+	ID          uint       `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StudentID   uint       `json:"student_id" binding:"required"`
+	CourseID    uint       `json:"course_id" binding:"required"`
+	ScheduleID  *uint      `json:"schedule_id" binding:"omitempty,gt=0"`
+	Title       string     `json:"title" binding:"required,notblank,max=100"`
+	Description string     `json:"description" binding:"max=2000"`
+	AssignedAt  time.Time  `json:"assigned_at" binding:"required"`
+	DueDate     time.Time  `json:"due_date" binding:"required"`
+	Status      string     `json:"status" binding:"required,oneof=assigned submitted checked"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+	CheckedAt   *time.Time `json:"checked_at"`
+	Score       *float64   `json:"score" binding:"omitempty,gte=0,lte=100"`
+	Feedback    string     `json:"feedback" binding:"max=2000"`
+}
+
+// BackupInvoice 账单及其明细
+type BackupInvoice struct {
+	ID          uint                `json:"id"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Number      string              `json:"number" binding:"required,notblank,max=32"`
+	StudentID   uint                `json:"student_id" binding:"required"`
+	PeriodStart time.Time           `json:"period_start" binding:"required"`
+	PeriodEnd   time.Time           `json:"period_end" binding:"required,gtefield=PeriodStart"`
+	Status      string              `json:"status" binding:"required,oneof=draft issued paid void"`
+	Amount      float64             `json:"amount" binding:"gte=0"`
+	Paid        float64             `json:"paid" binding:"gte=0"`
+	IssuedAt    *time.Time          `json:"issued_at"`
+	PaidAt      *time.Time          `json:"paid_at"`
+	VoidedAt    *time.Time          `json:"voided_at"`
+	Notes       string              `json:"notes" binding:"max=500"`
+	Items       []BackupInvoiceItem `json:"items"`
+}
+
+// BackupInvoiceItem 账单明细，每节课一条
+type BackupInvoiceItem struct {
+	ScheduleID  uint    `json:"schedule_id" binding:"required"`
+	CourseID    uint    `json:"course_id" binding:"required"`
+	Description string  `json:"description" binding:"max=500"`
+	Hours       float64 `json:"hours" binding:"gte=0"`
+	Rate        float64 `json:"rate" binding:"gte=0"`
+	Amount      float64 `json:"amount" binding:"gte=0"`
+}
+
+// BackupPayment 收款记录
+type BackupPayment struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	StudentID uint      `json:"student_id" binding:"required"`
+	InvoiceID *uint     `json:"invoice_id" binding:"omitempty,gt=0"`
+	Amount    float64   `json:"amount" binding:"required,gt=0"`
+	Method    string    `json:"method" binding:"required,oneof=cash wechat alipay"`
+	PaidAt    time.Time `json:"paid_at" binding:"required"`
+	Reference string    `json:"reference" binding:"max=100"`
+	Notes     string    `json:"notes" binding:"max=500"`
+}
+
+// BackupExamResult 考试成绩
+type BackupExamResult struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	StudentID uint      `json:"student_id" binding:"required"`
+	CourseID  uint      `json:"course_id" binding:"required"`
+	ExamType  string    `json:"exam_type" binding:"required,oneof=midterm final quiz"`
+	ExamName  string    `json:"exam_name" binding:"required,notblank,max=100"`
+	Score     float64   `json:"score" binding:"gte=0,ltefield=FullScore"`
+	FullScore float64   `json:"full_score" binding:"required,gt=0"`
+	ExamDate  time.Time `json:"exam_date" binding:"required"`
+	Comment   string    `json:"comment" binding:"max=500"`
+}
+
+// BackupGoal 学习目标
+type BackupGoal struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	StudentID   uint      `json:"student_id" binding:"required"`
+	CourseID    uint      `json:"course_id" binding:"required"`
+	TargetScore float64   `json:"target_score" binding:"required,gt=0,ltefield=FullScore"`
+	FullScore   float64   `json:"full_score" binding:"required,gt=0"`
+	ExamType    string    `json:"exam_type" binding:"omitempty,oneof=midterm final quiz"`
+	Deadline    time.Time `json:"deadline" binding:"required"`
+	Description string    `json:"description" binding:"max=500"`
+}
+
+// BackupAttachment 附件元数据，存储键沿用原库，文件需按相同的键复制到目标存储
+type BackupAttachment struct {
+	ID           uint      `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	OwnerType    string    `json:"owner_type" binding:"required,oneof=student exam_result lesson_record"`
+	OwnerID      uint      `json:"owner_id" binding:"required"`
+	FileName     string    `json:"file_name" binding:"required,notblank,max=255"`
+	ContentType  string    `json:"content_type" binding:"max=100"`
+	Size         int64     `json:"size" binding:"gte=0"`
+	Checksum     string    `json:"checksum" binding:"max=64"`
+	StorageKey   string    `json:"storage_key" binding:"required,max=255"`
+	ThumbnailKey string    `json:"thumbnail_key" binding:"max=255"`
+	Description  string    `json:"description" binding:"max=500"`
+}
+
+// BackupImportCount 单类记录的导入统计
+type BackupImportCount struct {
+	Created int `json:"created"`
+	Matched int `json:"matched"` // 目标库中已存在、沿用已有记录
+}
+
+// BackupNewUser 导入时新建的账号及其临时密码
+type BackupNewUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// BackupImportResult 导入结果
+type BackupImportResult struct {
+	Users             BackupImportCount `json:"users"`
+	Students          BackupImportCount `json:"students"`
+	Courses           BackupImportCount `json:"courses"`
+	TuitionRates      BackupImportCount `json:"tuition_rates"`
+	LessonPackages    BackupImportCount `json:"lesson_packages"`
+	Schedules         BackupImportCount `json:"schedules"`
+	MakeupCredits     BackupImportCount `json:"makeup_credits"`
+	PackageDeductions BackupImportCount `json:"package_deductions"`
+	LessonRecords     BackupImportCount `json:"lesson_records"`
+	Homework          BackupImportCount `json:"homework"`
+	Invoices          BackupImportCount `json:"invoices"`
+	Payments          BackupImportCount `json:"payments"`
+	ExamResults       BackupImportCount `json:"exam_results"`
+	Goals             BackupImportCount `json:"goals"`
+	Attachments       BackupImportCount `json:"attachments"`
+	NewUsers          []BackupNewUser   `json:"new_users,omitempty"` // 仅在本次响应中返回，请尽快登录修改
+}
+
+// Export 导出全量备份
+// @Summary 导出全量备份
+// @Description 导出全部业务数据（可选包含账号，不含密码）为带版本号的 JSON 文件，可在其他部署中导入。附件只导出元数据，文件需另行复制
+// @Tags 数据备份
+// @Security BearerAuth
+// @Produce json
+// @Param include_users query bool false "是否包含账号"
+// @Success 200 {object} Backup
+// @Router /backup/export [get]
+func (h *BackupHandler) Export(c *gin.Context) {
+	backup := Backup{
+		Format:            backupFormat,
+		Version:           backupVersion,
+		ExportedAt:        time.Now(),
+		Students:          []BackupStudent{},
+		Courses:           []BackupCourse{},
+		TuitionRates:      []BackupTuitionRate{},
+		LessonPackages:    []BackupLessonPackage{},
+		Schedules:         []BackupSchedule{},
+		MakeupCredits:     []BackupMakeupCredit{},
+		PackageDeductions: []BackupPackageDeduction{},
+		LessonRecords:     []BackupLessonRecord{},
+		Homework:          []BackupHomework{},
+		Invoices:          []BackupInvoice{},
+		Payments:          []BackupPayment{},
+		ExamResults:       []BackupExamResult{},
+		Goals:             []BackupGoal{},
+		Attachments:       []BackupAttachment{},
+	}
+
+	if includeUsers, _ := strconv.ParseBool(c.Query("include_users")); includeUsers {
+		var users []models.User
+		if err := h.DB.Order("id ASC").Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		backup.Users = make([]BackupUser, 0, len(users))
+		for _, u := range users {
+			backup.Users = append(backup.Users, BackupUser{
+				ID: u.ID, CreatedAt: u.CreatedAt, Username: u.Username, Name: u.Name, Avatar: u.Avatar,
+			})
+		}
+	}
+
+	var students []models.Student
+	var courses []models.Course
+	var rates []models.TuitionRate
+	var packages []models.LessonPackage
+	var schedules []models.Schedule
+	var credits []models.MakeupCredit
+	var deductions []models.PackageDeduction
+	var records []models.LessonRecord
+	var homework []models.Homework
+	var invoices []models.Invoice
+	var payments []models.Payment
+	var results []models.ExamResult
+	var goals []models.Goal
+	var attachments []models.Attachment
+	for _, dest := range []interface{}{
+		&students, &courses, &rates, &packages, &schedules, &credits, &deductions,
+		&records, &homework, &payments, &results, &goals, &attachments,
+	} {
+		if err := h.DB.Order("id ASC").Find(dest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := h.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Order("id ASC").Find(&invoices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, s := range students {
+		backup.Students = append(backup.Students, BackupStudent{
+			ID: s.ID, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt,
+			Name: s.Name, ParentPhone: s.ParentPhone, Grade: s.Grade, Notes: s.Notes,
+		})
+	}
+	for _, co := range courses {
+		backup.Courses = append(backup.Courses, BackupCourse{
+			ID: co.ID, CreatedAt: co.CreatedAt, UpdatedAt: co.UpdatedAt,
+			Name: co.Name, Description: co.Description,
+		})
+	}
+	for _, r := range rates {
+		backup.TuitionRates = append(backup.TuitionRates, BackupTuitionRate{
+			ID: r.ID, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+			CourseID: r.CourseID, Grade: r.Grade, StudentID: r.StudentID, HourlyRate: r.HourlyRate, Notes: r.Notes,
+		})
+	}
+	for _, p := range packages {
+		backup.LessonPackages = append(backup.LessonPackages, BackupLessonPackage{
+			ID: p.ID, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+			StudentID: p.StudentID, CourseID: p.CourseID, Unit: p.Unit, Quantity: p.Quantity, Used: p.Used,
+			Price: p.Price, PurchasedAt: p.PurchasedAt, ExpiresAt: p.ExpiresAt, Notes: p.Notes,
+		})
+	}
+	for _, s := range schedules {
+		backup.Schedules = append(backup.Schedules, BackupSchedule{
+			ID: s.ID, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt,
+			StudentID: s.StudentID, CourseID: s.CourseID, StartTime: s.StartTime, EndTime: s.EndTime,
+			Status: s.Status, CancelledBy: s.CancelledBy, CancelReason: s.CancelReason,
+			CancelledAt: s.CancelledAt, LateCancel: s.LateCancel,
+			Attendance: s.Attendance, CheckInAt: s.CheckInAt, CheckOutAt: s.CheckOutAt,
+			MakeupCreditID: s.MakeupCreditID,
+		})
+	}
+	for _, m := range credits {
+		backup.MakeupCredits = append(backup.MakeupCredits, BackupMakeupCredit{
+			ID: m.ID, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt,
+			StudentID: m.StudentID, CourseID: m.CourseID, SourceScheduleID: m.SourceScheduleID, Hours: m.Hours,
+			Status: m.Status, ExpiresAt: m.ExpiresAt, RedeemedScheduleID: m.RedeemedScheduleID,
+			RedeemedAt: m.RedeemedAt, Notes: m.Notes,
+		})
+	}
+	for _, d := range deductions {
+		backup.PackageDeductions = append(backup.PackageDeductions, BackupPackageDeduction{
+			ID: d.ID, CreatedAt: d.CreatedAt,
+			PackageID: d.PackageID, ScheduleID: d.ScheduleID, Amount: d.Amount, ReversedAt: d.ReversedAt,
+		})
+	}
+	for _, r := range records {
+		backup.LessonRecords = append(backup.LessonRecords, BackupLessonRecord{
+			ID: r.ID, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+			ScheduleID: r.ScheduleID, StudentID: r.StudentID, CourseID: r.CourseID,
+			Topics: r.Topics, Homework: r.Homework, TutorNotes: r.TutorNotes,
+			Rating: r.Rating, ParentVisible: r.ParentVisible,
+		})
+	}
+	for _, hw := range homework {
+		backup.Homework = append(backup.Homework, BackupHomework{
+			ID: hw.ID, CreatedAt: hw.CreatedAt, UpdatedAt: hw.UpdatedAt,
+			StudentID: hw.StudentID, CourseID: hw.CourseID, ScheduleID: hw.ScheduleID,
+			Title: hw.Title, Description: hw.Description, AssignedAt: hw.AssignedAt, DueDate: hw.DueDate,
+			Status: hw.Status, SubmittedAt: hw.SubmittedAt, CheckedAt: hw.CheckedAt,
+			Score: hw.Score, Feedback: hw.Feedback,
+		})
+	}
+	for _, inv := range invoices {
+		items := make([]BackupInvoiceItem, 0, len(inv.Items))
+		for _, it := range inv.Items {
+			items = append(items, BackupInvoiceItem{
+				ScheduleID: it.ScheduleID, CourseID: it.CourseID, Description: it.Description,
+				Hours: it.Hours, Rate: it.Rate, Amount: it.Amount,
+			})
+		}
+		backup.Invoices = append(backup.Invoices, BackupInvoice{
+			ID: inv.ID, CreatedAt: inv.CreatedAt, UpdatedAt: inv.UpdatedAt,
+			Number: inv.Number, StudentID: inv.StudentID, PeriodStart: inv.PeriodStart, PeriodEnd: inv.PeriodEnd,
+			Status: inv.Status, Amount: inv.Amount, Paid: inv.Paid,
+			IssuedAt: inv.IssuedAt, PaidAt: inv.PaidAt, VoidedAt: inv.VoidedAt, Notes: inv.Notes, Items: items,
+		})
+	}
+	for _, p := range payments {
+		backup.Payments = append(backup.Payments, BackupPayment{
+			ID: p.ID, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+			StudentID: p.StudentID, InvoiceID: p.InvoiceID, Amount: p.Amount, Method: p.Method,
+			PaidAt: p.PaidAt, Reference: p.Reference, Notes: p.Notes,
+		})
+	}
+	for _, r := range results {
+		backup.ExamResults = append(backup.ExamResults, BackupExamResult{
+			ID: r.ID, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+			StudentID: r.StudentID, CourseID: r.CourseID, ExamType: r.ExamType, ExamName: r.ExamName,
+			Score: r.Score, FullScore: r.FullScore, ExamDate: r.ExamDate, Comment: r.Comment,
+		})
+	}
+	for _, g := range goals {
+		backup.Goals = append(backup.Goals, BackupGoal{
+			ID: g.ID, CreatedAt: g.CreatedAt, UpdatedAt: g.UpdatedAt,
+			StudentID: g.StudentID, CourseID: g.CourseID, TargetScore: g.TargetScore, FullScore: g.FullScore,
+			ExamType: g.ExamType, Deadline: g.Deadline, Description: g.Description,
+		})
+	}
+	for _, a := range attachments {
+		backup.Attachments = append(backup.Attachments, BackupAttachment{
+			ID: a.ID, CreatedAt: a.CreatedAt, UpdatedAt: a.UpdatedAt,
+			OwnerType: a.OwnerType, OwnerID: a.OwnerID, FileName: a.FileName, ContentType: a.ContentType,
+			Size: a.Size, Checksum: a.Checksum, StorageKey: a.StorageKey, ThumbnailKey: a.ThumbnailKey,
+			Description: a.Description,
+		})
+	}
+
+	data, err := json.Marshal(backup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setAttachmentFilename(c, "tutor-backup-"+backup.ExportedAt.Format("20060102-150405"), FormatJSON)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// Import 导入全量备份
+// @Summary 导入全量备份
+// @Description 在一个事务中按依赖顺序导入备份文件并重新分配 ID、保持关联关系。已存在的记录按自然键匹配后沿用，不会重复创建，可重复导入：
+// @Description 账号按用户名，学生按姓名与家长电话，课程按名称，课时单价按课程、年级与学生，课时包按学生、课程、单位与购买时间，
+// @Description 排课按学生、课程与开始时间，补课权益按被取消的排课，扣减记录按课时包、排课与扣减时间，课堂记录按排课，
+// @Description 作业按学生、课程、标题与布置时间，账单按学生与账单号，收款按学生、收款时间、金额与单号，
+// @Description 成绩按学生、课程、考试名称与日期，学习目标按学生、课程、考试类型、截止日期与目标分，附件按所属记录、校验和与文件名。
+// @Description 附件只导入元数据，文件需按原存储键复制到本部署的存储中。
+// @Description 新建的账号使用随机临时密码，仅在响应中返回一次
+// @Tags 数据备份
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param backup body Backup true "备份文件内容"
+// @Success 200 {object} BackupImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /backup/import [post]
+func (h *BackupHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBackupSize)
+	var backup Backup
+	if err := json.NewDecoder(c.Request.Body).Decode(&backup); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("备份文件不能超过 %d MB", maxBackupSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "备份文件不是有效的 JSON: " + err.Error()})
+		return
+	}
+	if backup.Format != backupFormat {
+		c.JSON(http.StatusBadRequest, fieldError("format", "不是本系统的备份文件"))
+		return
+	}
+	if backup.Version < 1 || backup.Version > backupVersion {
+		c.JSON(http.StatusBadRequest, fieldError("version", fmt.Sprintf("不支持的备份版本 %d，当前支持 1-%d", backup.Version, backupVersion)))
+		return
+	}
+	if fields := validateBackup(backup); fields != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数校验失败", "fields": fields})
+		return
+	}
+
+	var result BackupImportResult
+	studentIDs := map[uint]bool{}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		result = BackupImportResult{}
+		imp := backupImporter{tx: tx, result: &result}
+		if err := imp.users(backup.Users); err != nil {
+			return err
+		}
+		students, err := imp.students(backup.Students)
+		if err != nil {
+			return err
+		}
+		courses, err := imp.courses(backup.Courses)
+		if err != nil {
+			return err
+		}
+		if err := imp.tuitionRates(backup.TuitionRates, students, courses); err != nil {
+			return err
+		}
+		packages, err := imp.lessonPackages(backup.LessonPackages, students, courses)
+		if err != nil {
+			return err
+		}
+		schedules, err := imp.schedules(backup.Schedules, students, courses)
+		if err != nil {
+			return err
+		}
+		credits, err := imp.makeupCredits(backup.MakeupCredits, students, courses, schedules)
+		if err != nil {
+			return err
+		}
+		if err := imp.linkMakeupCredits(backup.Schedules, schedules, credits); err != nil {
+			return err
+		}
+		if err := imp.packageDeductions(backup.PackageDeductions, packages, schedules); err != nil {
+			return err
+		}
+		records, err := imp.lessonRecords(backup.LessonRecords, students, courses, schedules)
+		if err != nil {
+			return err
+		}
+		if err := imp.homework(backup.Homework, students, courses, schedules); err != nil {
+			return err
+		}
+		invoices, err := imp.invoices(backup.Invoices, students, courses, schedules)
+		if err != nil {
+			return err
+		}
+		if err := imp.payments(backup.Payments, students, invoices); err != nil {
+			return err
+		}
+		results, err := imp.examResults(backup.ExamResults, students, courses)
+		if err != nil {
+			return err
+		}
+		if err := imp.goals(backup.Goals, students, courses); err != nil {
+			return err
+		}
+		owners := map[string]map[uint]uint{
+			AttachmentOwnerStudent:      students,
+			AttachmentOwnerExamResult:   results,
+			AttachmentOwnerLessonRecord: records,
+		}
+		if err := imp.attachments(backup.Attachments, owners); err != nil {
+			return err
+		}
+		for _, id := range students {
+			studentIDs[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Error("Backup import failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败，未写入任何数据: " + err.Error()})
+		return
+	}
+	for id := range studentIDs {
+		h.ChartCache.InvalidateStudent(id)
+	}
+	utils.Info("Backup imported",
+		zap.Int("students", result.Students.Created),
+		zap.Int("schedules", result.Schedules.Created),
+		zap.Int("invoices", result.Invoices.Created),
+		zap.Int("exam_results", result.ExamResults.Created),
+		zap.Int("attachments", result.Attachments.Created),
+	)
+	c.JSON(http.StatusOK, result)
+}
+
+// validateBackup 按创建接口的规则校验每条记录，并检查 ID 唯一且所有关联都指向备份中的记录
+// 返回第一个错误所在的字段，如 schedules[3].end_time，全部通过时返回 nil
+func validateBackup(b Backup) map[string]string {
+	if _, fields := validateBackupRows("users", b.Users, func(u BackupUser) uint { return u.ID }, nil); fields != nil {
+		return fields
+	}
+	students, fields := validateBackupRows("students", b.Students, func(s BackupStudent) uint { return s.ID }, nil)
+	if fields != nil {
+		return fields
+	}
+	courses, fields := validateBackupRows("courses", b.Courses, func(co BackupCourse) uint { return co.ID }, nil)
+	if fields != nil {
+		return fields
+	}
+	owned := func(studentID, courseID uint) []backupRef {
+		return []backupRef{{"student_id", studentID, students}, {"course_id", courseID, courses}}
+	}
+
+	if _, fields := validateBackupRows("tuition_rates", b.TuitionRates,
+		func(r BackupTuitionRate) uint { return r.ID },
+		func(r BackupTuitionRate) []backupRef { return owned(r.StudentID, r.CourseID) },
+	); fields != nil {
+		return fields
+	}
+	packages, fields := validateBackupRows("lesson_packages", b.LessonPackages,
+		func(p BackupLessonPackage) uint { return p.ID },
+		func(p BackupLessonPackage) []backupRef { return owned(p.StudentID, p.CourseID) },
+	)
+	if fields != nil {
+		return fields
+	}
+	schedules, fields := validateBackupRows("schedules", b.Schedules,
+		func(s BackupSchedule) uint { return s.ID },
+		func(s BackupSchedule) []backupRef { return owned(s.StudentID, s.CourseID) },
+	)
+	if fields != nil {
+		return fields
+	}
+	credits, fields := validateBackupRows("makeup_credits", b.MakeupCredits,
+		func(m BackupMakeupCredit) uint { return m.ID },
+		func(m BackupMakeupCredit) []backupRef {
+			return append(owned(m.StudentID, m.CourseID),
+				backupRef{"source_schedule_id", m.SourceScheduleID, schedules},
+				backupRef{"redeemed_schedule_id", optionalID(m.RedeemedScheduleID), schedules})
+		},
+	)
+	if fields != nil {
+		return fields
+	}
+	// 排课与补课权益互相引用，补课权益校验完后再检查排课一侧
+	for i, s := range b.Schedules {
+		if id := optionalID(s.MakeupCreditID); id != 0 && !credits[id] {
+			return backupRowError("schedules", i, "makeup_credit_id", fmt.Sprintf("%d 不在备份中", id))
+		}
+	}
+	if _, fields := validateBackupRows("package_deductions", b.PackageDeductions,
+		func(d BackupPackageDeduction) uint { return d.ID },
+		func(d BackupPackageDeduction) []backupRef {
+			return []backupRef{{"package_id", d.PackageID, packages}, {"schedule_id", d.ScheduleID, schedules}}
+		},
+	); fields != nil {
+		return fields
+	}
+	records, fields := validateBackupRows("lesson_records", b.LessonRecords,
+		func(r BackupLessonRecord) uint { return r.ID },
+		func(r BackupLessonRecord) []backupRef {
+			return append(owned(r.StudentID, r.CourseID), backupRef{"schedule_id", r.ScheduleID, schedules})
+		},
+	)
+	if fields != nil {
+		return fields
+	}
+	if _, fields := validateBackupRows("homework", b.Homework,
+		func(hw BackupHomework) uint { return hw.ID },
+		func(hw BackupHomework) []backupRef {
+			return append(owned(hw.StudentID, hw.CourseID), backupRef{"schedule_id", optionalID(hw.ScheduleID), schedules})
+		},
+	); fields != nil {
+		return fields
+	}
+	invoices, fields := validateBackupRows("invoices", b.Invoices,
+		func(inv BackupInvoice) uint { return inv.ID },
+		func(inv BackupInvoice) []backupRef { return []backupRef{{"student_id", inv.StudentID, students}} },
+	)
+	if fields != nil {
+		return fields
+	}
+	for i, inv := range b.Invoices {
+		table := fmt.Sprintf("invoices[%d].items", i)
+		for j, it := range inv.Items {
+			if fields := backupRowErrors(table, j, it); fields != nil {
+				return fields
+			}
+			if !schedules[it.ScheduleID] {
+				return backupRowError(table, j, "schedule_id", fmt.Sprintf("%d 不在备份中", it.ScheduleID))
+			}
+			if !courses[it.CourseID] {
+				return backupRowError(table, j, "course_id", fmt.Sprintf("%d 不在备份中", it.CourseID))
+			}
+		}
+	}
+	if _, fields := validateBackupRows("payments", b.Payments,
+		func(p BackupPayment) uint { return p.ID },
+		func(p BackupPayment) []backupRef {
+			return []backupRef{{"student_id", p.StudentID, students}, {"invoice_id", optionalID(p.InvoiceID), invoices}}
+		},
+	); fields != nil {
+		return fields
+	}
+	results, fields := validateBackupRows("exam_results", b.ExamResults,
+		func(r BackupExamResult) uint { return r.ID },
+		func(r BackupExamResult) []backupRef { return owned(r.StudentID, r.CourseID) },
+	)
+	if fields != nil {
+		return fields
+	}
+	if _, fields := validateBackupRows("goals", b.Goals,
+		func(g BackupGoal) uint { return g.ID },
+		func(g BackupGoal) []backupRef { return owned(g.StudentID, g.CourseID) },
+	); fields != nil {
+		return fields
+	}
+	owners := map[string]map[uint]bool{
+		AttachmentOwnerStudent:      students,
+		AttachmentOwnerExamResult:   results,
+		AttachmentOwnerLessonRecord: records,
+	}
+	_, fields = validateBackupRows("attachments", b.Attachments,
+		func(a BackupAttachment) uint { return a.ID },
+		func(a BackupAttachment) []backupRef { return []backupRef{{"owner_id", a.OwnerID, owners[a.OwnerType]}} },
+	)
+	return fields
+}
+
+// backupRef 记录中指向其他记录的 ID，为 0 表示未关联
+type backupRef struct {
+	field string
+	id    uint
+	ids   map[uint]bool // 被引用的记录在备份中的 ID
+}
+
+// validateBackupRows 校验一类记录并返回其 ID 集合，refs 为空表示该类记录不引用其他记录
+func validateBackupRows[T any](table string, rows []T, id func(T) uint, refs func(T) []backupRef) (map[uint]bool, map[string]string) {
+	ids := make(map[uint]bool, len(rows))
+	for i, row := range rows {
+		if fields := backupRowErrors(table, i, row); fields != nil {
+			return nil, fields
+		}
+		if ids[id(row)] {
+			return nil, backupRowError(table, i, "id", fmt.Sprintf("重复的 id %d", id(row)))
+		}
+		ids[id(row)] = true
+		if refs == nil {
+			continue
+		}
+		for _, ref := range refs(row) {
+			if ref.id != 0 && !ref.ids[ref.id] {
+				return nil, backupRowError(table, i, ref.field, fmt.Sprintf("%d 不在备份中", ref.id))
+			}
+		}
+	}
+	return ids, nil
+}
+
+// optionalID 返回可为空的关联 ID，为空时返回 0
+func optionalID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// backupRowErrors 校验单条记录，有多个字段出错时按字段名取第一个，保证提示稳定
+func backupRowErrors(table string, index int, row interface{}) map[string]string {
+	fields := importValidate(row)
+	if fields == nil {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return backupRowError(table, index, names[0], fields[names[0]])
+}
+
+func backupRowError(table string, index int, field, message string) map[string]string {
+	return map[string]string{fmt.Sprintf("%s[%d].%s", table, index, field): message}
+}
+
+// backupMatcher 按自然键查找目标库中已有的记录
+// 同一个键有多条记录时按 ID 顺序依次认领，备份中的同键记录与已有记录一一对应，重复导入结果不变
+type backupMatcher map[string][]uint
+
+func (m backupMatcher) add(key string, id uint) {
+	m[key] = append(m[key], id)
+}
+
+func (m backupMatcher) claim(key string) (uint, bool) {
+	ids := m[key]
+	if len(ids) == 0 {
+		return 0, false
+	}
+	m[key] = ids[1:]
+	return ids[0], true
+}
+
+// backupImporter 在事务中逐类导入，返回 备份ID → 新ID 的映射
+type backupImporter struct {
+	tx     *gorm.DB
+	result *BackupImportResult
+}
+
+func (imp backupImporter) users(users []BackupUser) error {
+	var existing []models.User
+	if err := imp.tx.Select("id", "username").Order("id ASC").Find(&existing).Error; err != nil {
+		return err
+	}
+	matcher := backupMatcher{}
+	for _, u := range existing {
+		matcher.add(u.Username, u.ID)
+	}
+	for _, u := range users {
+		if _, ok := matcher.claim(u.Username); ok {
+			imp.result.Users.Matched++
+			continue
+		}
+		password, err := randomPassword()
+		if err != nil {
+			return err
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user := models.User{CreatedAt: u.CreatedAt, Username: u.Username, Password: string(hashed), Name: u.Name, Avatar: u.Avatar}
+		if err := imp.tx.Create(&user).Error; err != nil {
+			return err
+		}
+		imp.result.Users.Created++
+		imp.result.NewUsers = append(imp.result.NewUsers, BackupNewUser{Username: u.Username, Password: password})
+	}
+	return nil
+}
+
+func (imp backupImporter) students(students []BackupStudent) (map[uint]uint, error) {
+	var existing []models.Student
+	if err := imp.tx.Select("id", "name", "parent_phone").Order("id ASC").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	key := func(name, phone string) string { return name + "\x00" + phone }
+	matcher := backupMatcher{}
+	for _, s := range existing {
+		matcher.add(key(s.Name, s.ParentPhone), s.ID)
+	}
+	return importBackupRows(students, matcher, &imp.result.Students,
+		func(s BackupStudent) uint { return s.ID },
+		func(s BackupStudent) string { return key(s.Name, s.ParentPhone) },
+		func(s BackupStudent) (uint, error) {
+			student := models.Student{
+				CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt,
+				Name: s.Name, ParentPhone: s.ParentPhone, Grade: s.Grade, Notes: s.Notes,
+			}
+			err := imp.tx.Create(&student).Error
+			return student.ID, err
+		})
+}
+
+func (imp backupImporter) courses(courses []BackupCourse) (map[uint]uint, error) {
+	var existing []models.Course
+	if err := imp.tx.Select("id", "name").Order("id ASC").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	matcher := backupMatcher{}
+	for _, co := range existing {
+		matcher.add(co.Name, co.ID)
+	}
+	return importBackupRows(courses, matcher, &imp.result.Courses,
+		func(co BackupCourse) uint { return co.ID },
+		func(co BackupCourse) string { return co.Name },
+		func(co BackupCourse) (uint, error) {
+			course := models.Course{
+				CreatedAt: co.CreatedAt, UpdatedAt: co.UpdatedAt,
+				Name: co.Name, Description: co.Description,
+			}
+			err := imp.tx.Create(&course).Error
+			return course.ID, err
+		})
+}
+
+func (imp backupImporter) tuitionRates(rates []BackupTuitionRate, students, courses map[uint]uint) error {
+	var existing []models.TuitionRate
+	if err := imp.tx.Select("id", "course_id", "grade", "student_id").Order("id ASC").Find(&existing).Error; err != nil {
+		return err
+	}
+	key := func(courseID uint, grade string, studentID uint) string {
+		return fmt.Sprintf("%d/%d/%s", courseID, studentID, grade)
+	}
+	matcher := backupMatcher{}
+	for _, r := range existing {
+		matcher.add(key(r.CourseID, r.Grade, r.StudentID), r.ID)
+	}
+	// 通用价格的 student_id 为 0，映射中没有该键，仍得到 0
+	_, err := importBackupRows(rates, matcher, &imp.result.TuitionRates,
+		func(r BackupTuitionRate) uint { return r.ID },
+		func(r BackupTuitionRate) string { return key(courses[r.CourseID], r.Grade, students[r.StudentID]) },
+		func(r BackupTuitionRate) (uint, error) {
+			rate := models.TuitionRate{
+				CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+				CourseID: courses[r.CourseID], Grade: r.Grade, StudentID: students[r.StudentID],
+				HourlyRate: r.HourlyRate, Notes: r.Notes,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&rate).Error
+			return rate.ID, err
+		})
+	return err
+}
+
+func (imp backupImporter) lessonPackages(packages []BackupLessonPackage, students, courses map[uint]uint) (map[uint]uint, error) {
+	var existing []models.LessonPackage
+	err := imp.tx.Select("id", "student_id", "course_id", "unit", "purchased_at").Order("id ASC").Find(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	key := func(studentID, courseID uint, unit string, purchasedAt time.Time) string {
+		return fmt.Sprintf("%d/%d/%s/%d", studentID, courseID, unit, purchasedAt.Unix())
+	}
+	matcher := backupMatcher{}
+	for _, p := range existing {
+		matcher.add(key(p.StudentID, p.CourseID, p.Unit, p.PurchasedAt), p.ID)
+	}
+	return importBackupRows(packages, matcher, &imp.result.LessonPackages,
+		func(p BackupLessonPackage) uint { return p.ID },
+		func(p BackupLessonPackage) string {
+			return key(students[p.StudentID], courses[p.CourseID], p.Unit, p.PurchasedAt)
+		},
+		func(p BackupLessonPackage) (uint, error) {
+			pkg := models.LessonPackage{
+				CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+				StudentID: students[p.StudentID], CourseID: courses[p.CourseID], Unit: p.Unit,
+				Quantity: p.Quantity, Used: p.Used, Price: p.Price,
+				PurchasedAt: p.PurchasedAt, ExpiresAt: p.ExpiresAt, Notes: p.Notes,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&pkg).Error
+			return pkg.ID, err
+		})
+}
+
+// schedules 导入排课，补课权益的关联在补课权益导入后由 linkMakeupCredits 补上
+func (imp backupImporter) schedules(schedules []BackupSchedule, students, courses map[uint]uint) (map[uint]uint, error) {
+	var existing []models.Schedule
+	if err := imp.tx.Select("id", "student_id", "course_id", "start_time").Order("id ASC").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	key := func(studentID, courseID uint, start time.Time) string {
+		return fmt.Sprintf("%d/%d/%d", studentID, courseID, start.Unix())
+	}
+	matcher := backupMatcher{}
+	for _, s := range existing {
+		matcher.add(key(s.StudentID, s.CourseID, s.StartTime), s.ID)
+	}
+	return importBackupRows(schedules, matcher, &imp.result.Schedules,
+		func(s BackupSchedule) uint { return s.ID },
+		func(s BackupSchedule) string { return key(students[s.StudentID], courses[s.CourseID], s.StartTime) },
+		func(s BackupSchedule) (uint, error) {
+			schedule := models.Schedule{
+				CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt,
+				StudentID: students[s.StudentID], CourseID: courses[s.CourseID], StartTime: s.StartTime, EndTime: s.EndTime,
+				Status: s.Status, CancelledBy: s.CancelledBy, CancelReason: s.CancelReason,
+				CancelledAt: s.CancelledAt, LateCancel: s.LateCancel,
+				Attendance: s.Attendance, CheckInAt: s.CheckInAt, CheckOutAt: s.CheckOutAt,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&schedule).Error
+			return schedule.ID, err
+		})
+}
+
+func (imp backupImporter) makeupCredits(credits []BackupMakeupCredit, students, courses, schedules map[uint]uint) (map[uint]uint, error) {
+	var existing []models.MakeupCredit
+	if err := imp.tx.Select("id", "source_schedule_id").Order("id ASC").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	matcher := backupMatcher{}
+	for _, m := range existing {
+		matcher.add(strconv.FormatUint(uint64(m.SourceScheduleID), 10), m.ID)
+	}
+	return importBackupRows(credits, matcher, &imp.result.MakeupCredits,
+		func(m BackupMakeupCredit) uint { return m.ID },
+		func(m BackupMakeupCredit) string {
+			return strconv.FormatUint(uint64(schedules[m.SourceScheduleID]), 10)
+		},
+		func(m BackupMakeupCredit) (uint, error) {
+			credit := models.MakeupCredit{
+				CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt,
+				StudentID: students[m.StudentID], CourseID: courses[m.CourseID],
+				SourceScheduleID: schedules[m.SourceScheduleID], Hours: m.Hours, Status: m.Status,
+				ExpiresAt: m.ExpiresAt, RedeemedScheduleID: remapID(m.RedeemedScheduleID, schedules),
+				RedeemedAt: m.RedeemedAt, Notes: m.Notes,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&credit).Error
+			return credit.ID, err
+		})
+}
+
+// linkMakeupCredits 为补课排课关联补课权益，目标库中已关联的排课保持不变
+func (imp backupImporter) linkMakeupCredits(schedules []BackupSchedule, scheduleIDs, creditIDs map[uint]uint) error {
+	for _, s := range schedules {
+		if s.MakeupCreditID == nil {
+			continue
+		}
+		err := imp.tx.Model(&models.Schedule{}).
+			Where("id = ? AND makeup_credit_id IS NULL", scheduleIDs[s.ID]).
+			UpdateColumn("makeup_credit_id", creditIDs[*s.MakeupCreditID]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp backupImporter) packageDeductions(deductions []BackupPackageDeduction, packages, schedules map[uint]uint) error {
+	var existing []models.PackageDeduction
+	if err := imp.tx.Select("id", "package_id", "schedule_id", "created_at").Order("id ASC").Find(&existing).Error; err != nil {
+		return err
+	}
+	key := func(packageID, scheduleID uint, createdAt time.Time) string {
+		return fmt.Sprintf("%d/%d/%d", packageID, scheduleID, createdAt.Unix())
+	}
+	matcher := backupMatcher{}
+	for _, d := range existing {
+		matcher.add(key(d.PackageID, d.ScheduleID, d.CreatedAt), d.ID)
+	}
+	_, err := importBackupRows(deductions, matcher, &imp.result.PackageDeductions,
+		func(d BackupPackageDeduction) uint { return d.ID },
+		func(d BackupPackageDeduction) string {
+			return key(packages[d.PackageID], schedules[d.ScheduleID], d.CreatedAt)
+		},
+		func(d BackupPackageDeduction) (uint, error) {
+			deduction := models.PackageDeduction{
+				CreatedAt: d.CreatedAt, PackageID: packages[d.PackageID], ScheduleID: schedules[d.ScheduleID],
+				Amount: d.Amount, ReversedAt: d.ReversedAt,
+			}
+			err := imp.tx.Create(&deduction).Error
+			return deduction.ID, err
+		})
+	return err
+}
+
+func (imp backupImporter) lessonRecords(records []BackupLessonRecord, students, courses, schedules map[uint]uint) (map[uint]uint, error) {
+	var existing []models.LessonRecord
+	if err := imp.tx.Select("id", "schedule_id").Order("id ASC").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	matcher := backupMatcher{}
+	for _, r := range existing {
+		matcher.add(strconv.FormatUint(uint64(r.ScheduleID), 10), r.ID)
+	}
+	return importBackupRows(records, matcher, &imp.result.LessonRecords,
+		func(r BackupLessonRecord) uint { return r.ID },
+		func(r BackupLessonRecord) string { return strconv.FormatUint(uint64(schedules[r.ScheduleID]), 10) },
+		func(r BackupLessonRecord) (uint, error) {
+			record := models.LessonRecord{
+				CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+				ScheduleID: schedules[r.ScheduleID], StudentID: students[r.StudentID], CourseID: courses[r.CourseID],
+				Topics: r.Topics, Homework: r.Homework, TutorNotes: r.TutorNotes,
+				Rating: r.Rating, ParentVisible: r.ParentVisible,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&record).Error
+			return record.ID, err
+		})
+}
+
+func (imp backupImporter) homework(homework []BackupHomework, students, courses, schedules map[uint]uint) error {
+	var existing []models.Homework
+	err := imp.tx.Select("id", "student_id", "course_id", "title", "assigned_at").Order("id ASC").Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	key := func(studentID, courseID uint, title string, assignedAt time.Time) string {
+		return fmt.Sprintf("%d/%d/%d/%s", studentID, courseID, assignedAt.Unix(), title)
+	}
+	matcher := backupMatcher{}
+	for _, hw := range existing {
+		matcher.add(key(hw.StudentID, hw.CourseID, hw.Title, hw.AssignedAt), hw.ID)
+	}
+	_, err = importBackupRows(homework, matcher, &imp.result.Homework,
+		func(hw BackupHomework) uint { return hw.ID },
+		func(hw BackupHomework) string {
+			return key(students[hw.StudentID], courses[hw.CourseID], hw.Title, hw.AssignedAt)
+		},
+		func(hw BackupHomework) (uint, error) {
+			item := models.Homework{
+				CreatedAt: hw.CreatedAt, UpdatedAt: hw.UpdatedAt,
+				StudentID: students[hw.StudentID], CourseID: courses[hw.CourseID], ScheduleID: remapID(hw.ScheduleID, schedules),
+				Title: hw.Title, Description: hw.Description, AssignedAt: hw.AssignedAt, DueDate: hw.DueDate,
+				Status: hw.Status, SubmittedAt: hw.SubmittedAt, CheckedAt: hw.CheckedAt,
+				Score: hw.Score, Feedback: hw.Feedback,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&item).Error
+			return item.ID, err
+		})
+	return err
+}
+
+// invoices 导入账单，明细随新建的账单一起写入；账单号沿用原库，匹配到的账单不改动明细
+func (imp backupImporter) invoices(invoices []BackupInvoice, students, courses, schedules map[uint]uint) (map[uint]uint, error) {
+	var existing []models.Invoice
+	if err := imp.tx.Select("id", "student_id", "number").Order("id ASC").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	key := func(studentID uint, number string) string { return fmt.Sprintf("%d/%s", studentID, number) }
+	matcher := backupMatcher{}
+	for _, inv := range existing {
+		matcher.add(key(inv.StudentID, inv.Number), inv.ID)
+	}
+	return importBackupRows(invoices, matcher, &imp.result.Invoices,
+		func(inv BackupInvoice) uint { return inv.ID },
+		func(inv BackupInvoice) string { return key(students[inv.StudentID], inv.Number) },
+		func(inv BackupInvoice) (uint, error) {
+			invoice := models.Invoice{
+				CreatedAt: inv.CreatedAt, UpdatedAt: inv.UpdatedAt,
+				Number: inv.Number, StudentID: students[inv.StudentID],
+				PeriodStart: inv.PeriodStart, PeriodEnd: inv.PeriodEnd, Status: inv.Status,
+				Amount: inv.Amount, Paid: inv.Paid,
+				IssuedAt: inv.IssuedAt, PaidAt: inv.PaidAt, VoidedAt: inv.VoidedAt, Notes: inv.Notes,
+			}
+			if err := imp.tx.Omit(clause.Associations).Create(&invoice).Error; err != nil {
+				return 0, err
+			}
+			if len(inv.Items) == 0 {
+				return invoice.ID, nil
+			}
+			items := make([]models.InvoiceItem, 0, len(inv.Items))
+			for _, it := range inv.Items {
+				items = append(items, models.InvoiceItem{
+					InvoiceID: invoice.ID, ScheduleID: schedules[it.ScheduleID], CourseID: courses[it.CourseID],
+					Description: it.Description, Hours: it.Hours, Rate: it.Rate, Amount: it.Amount,
+				})
+			}
+			return invoice.ID, imp.tx.Create(&items).Error
+		})
+}
+
+func (imp backupImporter) payments(payments []BackupPayment, students, invoices map[uint]uint) error {
+	var existing []models.Payment
+	err := imp.tx.Select("id", "student_id", "amount", "paid_at", "reference").Order("id ASC").Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	key := func(studentID uint, paidAt time.Time, amount float64, reference string) string {
+		return fmt.Sprintf("%d/%d/%v/%s", studentID, paidAt.Unix(), amount, reference)
+	}
+	matcher := backupMatcher{}
+	for _, p := range existing {
+		matcher.add(key(p.StudentID, p.PaidAt, p.Amount, p.Reference), p.ID)
+	}
+	_, err = importBackupRows(payments, matcher, &imp.result.Payments,
+		func(p BackupPayment) uint { return p.ID },
+		func(p BackupPayment) string { return key(students[p.StudentID], p.PaidAt, p.Amount, p.Reference) },
+		func(p BackupPayment) (uint, error) {
+			payment := models.Payment{
+				CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+				StudentID: students[p.StudentID], InvoiceID: remapID(p.InvoiceID, invoices),
+				Amount: p.Amount, Method: p.Method, PaidAt: p.PaidAt, Reference: p.Reference, Notes: p.Notes,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&payment).Error
+			return payment.ID, err
+		})
+	return err
+}
+
+func (imp backupImporter) examResults(results []BackupExamResult, students, courses map[uint]uint) (map[uint]uint, error) {
+	var existing []models.ExamResult
+	err := imp.tx.Select("id", "student_id", "course_id", "exam_name", "exam_date").Order("id ASC").Find(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	key := func(studentID, courseID uint, name string, date time.Time) string {
+		return fmt.Sprintf("%d/%d/%s/%s", studentID, courseID, date.In(time.Local).Format("2006-01-02"), name)
+	}
+	matcher := backupMatcher{}
+	for _, r := range existing {
+		matcher.add(key(r.StudentID, r.CourseID, r.ExamName, r.ExamDate), r.ID)
+	}
+	return importBackupRows(results, matcher, &imp.result.ExamResults,
+		func(r BackupExamResult) uint { return r.ID },
+		func(r BackupExamResult) string {
+			return key(students[r.StudentID], courses[r.CourseID], r.ExamName, r.ExamDate)
+		},
+		func(r BackupExamResult) (uint, error) {
+			result := models.ExamResult{
+				CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+				StudentID: students[r.StudentID], CourseID: courses[r.CourseID], ExamType: r.ExamType, ExamName: r.ExamName,
+				Score: r.Score, FullScore: r.FullScore, ExamDate: r.ExamDate, Comment: r.Comment,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&result).Error
+			return result.ID, err
+		})
+}
+
+func (imp backupImporter) goals(goals []BackupGoal, students, courses map[uint]uint) error {
+	var existing []models.Goal
+	err := imp.tx.Select("id", "student_id", "course_id", "exam_type", "deadline", "target_score").Order("id ASC").Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	key := func(studentID, courseID uint, examType string, deadline time.Time, target float64) string {
+		return fmt.Sprintf("%d/%d/%s/%d/%v", studentID, courseID, examType, deadline.Unix(), target)
+	}
+	matcher := backupMatcher{}
+	for _, g := range existing {
+		matcher.add(key(g.StudentID, g.CourseID, g.ExamType, g.Deadline, g.TargetScore), g.ID)
+	}
+	_, err = importBackupRows(goals, matcher, &imp.result.Goals,
+		func(g BackupGoal) uint { return g.ID },
+		func(g BackupGoal) string {
+			return key(students[g.StudentID], courses[g.CourseID], g.ExamType, g.Deadline, g.TargetScore)
+		},
+		func(g BackupGoal) (uint, error) {
+			goal := models.Goal{
+				CreatedAt: g.CreatedAt, UpdatedAt: g.UpdatedAt,
+				StudentID: students[g.StudentID], CourseID: courses[g.CourseID],
+				TargetScore: g.TargetScore, FullScore: g.FullScore, ExamType: g.ExamType,
+				Deadline: g.Deadline, Description: g.Description,
+			}
+			err := imp.tx.Omit(clause.Associations).Create(&goal).Error
+			return goal.ID, err
+		})
+	return err
+}
+
+// attachments 导入附件元数据，owners 为各类所属记录的 备份ID → 新ID
+func (imp backupImporter) attachments(attachments []BackupAttachment, owners map[string]map[uint]uint) error {
+	var existing []models.Attachment
+	err := imp.tx.Select("id", "owner_type", "owner_id", "checksum", "file_name").Order("id ASC").Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	key := func(ownerType string, ownerID uint, checksum, fileName string) string {
+		return fmt.Sprintf("%s/%d/%s/%s", ownerType, ownerID, checksum, fileName)
+	}
+	matcher := backupMatcher{}
+	for _, a := range existing {
+		matcher.add(key(a.OwnerType, a.OwnerID, a.Checksum, a.FileName), a.ID)
+	}
+	_, err = importBackupRows(attachments, matcher, &imp.result.Attachments,
+		func(a BackupAttachment) uint { return a.ID },
+		func(a BackupAttachment) string {
+			return key(a.OwnerType, owners[a.OwnerType][a.OwnerID], a.Checksum, a.FileName)
+		},
+		func(a BackupAttachment) (uint, error) {
+			attachment := models.Attachment{
+				CreatedAt: a.CreatedAt, UpdatedAt: a.UpdatedAt,
+				OwnerType: a.OwnerType, OwnerID: owners[a.OwnerType][a.OwnerID],
+				FileName: a.FileName, ContentType: a.ContentType, Size: a.Size, Checksum: a.Checksum,
+				StorageKey: a.StorageKey, ThumbnailKey: a.ThumbnailKey, Description: a.Description,
+			}
+			err := imp.tx.Create(&attachment).Error
+			return attachment.ID, err
+		})
+	return err
+}
+
+// importBackupRows 逐条按自然键认领目标库中已有的记录，认领不到时调用 create 新建，返回 备份ID → 新ID
+func importBackupRows[T any](rows []T, matcher backupMatcher, count *BackupImportCount,
+	id func(T) uint, key func(T) string, create func(T) (uint, error)) (map[uint]uint, error) {
+	ids := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		if existing, ok := matcher.claim(key(row)); ok {
+			ids[id(row)] = existing
+			count.Matched++
+			continue
+		}
+		newID, err := create(row)
+		if err != nil {
+			return nil, err
+		}
+		ids[id(row)] = newID
+		count.Created++
+	}
+	return ids, nil
+}
+
+// remapID 将可为空的关联 ID 换成新ID
+func remapID(id *uint, ids map[uint]uint) *uint {
+	if id == nil {
+		return nil
+	}
+	newID := ids[*id]
+	return &newID
+}
+
+// randomPassword 生成导入账号的临时密码
+func randomPassword() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
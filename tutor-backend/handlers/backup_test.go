@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateBackup(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	valid := func() Backup {
+		return Backup{
+			Format:   backupFormat,
+			Version:  backupVersion,
+			Users:    []BackupUser{{ID: 1, Username: "admin"}},
+			Students: []BackupStudent{{ID: 1, Name: "张三", ParentPhone: "13800138000"}},
+			Courses:  []BackupCourse{{ID: 1, Name: "数学"}},
+			Schedules: []BackupSchedule{{
+				ID: 1, StudentID: 1, CourseID: 1, StartTime: start, EndTime: start.Add(time.Hour),
+				Status: "completed", Attendance: "present",
+			}},
+			ExamResults: []BackupExamResult{{
+				ID: 1, StudentID: 1, CourseID: 1, ExamType: "midterm", ExamName: "期中",
+				Score: 90, FullScore: 100, ExamDate: start,
+			}},
+			MakeupCredits: []BackupMakeupCredit{{
+				ID: 1, StudentID: 1, CourseID: 1, SourceScheduleID: 1, Hours: 1, Status: "pending",
+			}},
+			Invoices: []BackupInvoice{{
+				ID: 1, Number: "INV202403-00001", StudentID: 1, PeriodStart: start, PeriodEnd: start, Status: "issued",
+				Amount: 200, Items: []BackupInvoiceItem{{ScheduleID: 1, CourseID: 1, Hours: 1, Rate: 200, Amount: 200}},
+			}},
+			Payments: []BackupPayment{{ID: 1, StudentID: 1, InvoiceID: uintPtr(1), Amount: 200, Method: "wechat", PaidAt: start}},
+			Attachments: []BackupAttachment{{
+				ID: 1, OwnerType: "exam_result", OwnerID: 1, FileName: "卷子.png", StorageKey: "exam_result/1/a.png",
+			}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(b *Backup)
+		want   map[string]string
+	}{
+		{"有效备份", func(b *Backup) {}, nil},
+		{"学生姓名为空", func(b *Backup) { b.Students[0].Name = " " }, map[string]string{"students[0].name": "不能为空"}},
+		{"家长电话格式错误", func(b *Backup) { b.Students[0].ParentPhone = "12345" }, map[string]string{"students[0].parent_phone": "手机号格式不正确"}},
+		{"学生 ID 重复", func(b *Backup) { b.Students = append(b.Students, BackupStudent{ID: 1, Name: "李四"}) }, map[string]string{"students[1].id": "重复的 id 1"}},
+		{"课程名称过长", func(b *Backup) { b.Courses[0].Name = strings.Repeat("数", 51) }, map[string]string{"courses[0].name": "长度不能超过 50"}},
+		{"用户名为空", func(b *Backup) { b.Users[0].Username = "" }, map[string]string{"users[0].username": "不能为空"}},
+		{"排课状态无效", func(b *Backup) { b.Schedules[0].Status = "done" }, map[string]string{"schedules[0].status": "必须是以下值之一: scheduled in_progress completed cancelled"}},
+		{"排课进行中", func(b *Backup) { b.Schedules[0].Status = "in_progress" }, nil},
+		{"出勤状态无效", func(b *Backup) { b.Schedules[0].Attendance = "sick" }, map[string]string{"schedules[0].attendance": "必须是以下值之一: present late absent excused"}},
+		{"结束时间早于开始时间", func(b *Backup) { b.Schedules[0].EndTime = start }, map[string]string{"schedules[0].end_time": "必须晚于 start_time"}},
+		{"排课引用不存在的学生", func(b *Backup) { b.Schedules[0].StudentID = 2 }, map[string]string{"schedules[0].student_id": "2 不在备份中"}},
+		{"分数超过满分", func(b *Backup) { b.ExamResults[0].Score = 120 }, map[string]string{"exam_results[0].score": "不能大于 full_score"}},
+		{"考试类型无效", func(b *Backup) { b.ExamResults[0].ExamType = "mock" }, map[string]string{"exam_results[0].exam_type": "必须是以下值之一: midterm final quiz"}},
+		{"考试日期为空", func(b *Backup) { b.ExamResults[0].ExamDate = time.Time{} }, map[string]string{"exam_results[0].exam_date": "不能为空"}},
+		{"补课权益引用不存在的排课", func(b *Backup) { b.MakeupCredits[0].SourceScheduleID = 9 }, map[string]string{"makeup_credits[0].source_schedule_id": "9 不在备份中"}},
+		{"排课引用不存在的补课权益", func(b *Backup) { b.Schedules[0].MakeupCreditID = uintPtr(9) }, map[string]string{"schedules[0].makeup_credit_id": "9 不在备份中"}},
+		{"排课引用补课权益", func(b *Backup) { b.Schedules[0].MakeupCreditID = uintPtr(1) }, nil},
+		{"账单状态无效", func(b *Backup) { b.Invoices[0].Status = "sent" }, map[string]string{"invoices[0].status": "必须是以下值之一: draft issued paid void"}},
+		{"账单明细引用不存在的排课", func(b *Backup) { b.Invoices[0].Items[0].ScheduleID = 9 }, map[string]string{"invoices[0].items[0].schedule_id": "9 不在备份中"}},
+		{"收款引用不存在的账单", func(b *Backup) { b.Payments[0].InvoiceID = uintPtr(9) }, map[string]string{"payments[0].invoice_id": "9 不在备份中"}},
+		{"预收款不关联账单", func(b *Backup) { b.Payments[0].InvoiceID = nil }, nil},
+		{"收款方式无效", func(b *Backup) { b.Payments[0].Method = "card" }, map[string]string{"payments[0].method": "必须是以下值之一: cash wechat alipay"}},
+		{"附件所属记录按类型检查", func(b *Backup) { b.Attachments[0].OwnerType = "lesson_record" }, map[string]string{"attachments[0].owner_id": "1 不在备份中"}},
+		{"成绩引用不存在的课程", func(b *Backup) { b.ExamResults[0].CourseID = 3 }, map[string]string{"exam_results[0].course_id": "3 不在备份中"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := valid()
+			tt.modify(&b)
+			if got := validateBackup(b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateBackup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string][]uint
+		claims   []string
+		want     []uint // 0 表示未匹配
+	}{
+		{"无已有记录", nil, []string{"a"}, []uint{0}},
+		{"单条匹配", map[string][]uint{"a": {5}}, []string{"a", "a"}, []uint{5, 0}},
+		{"同键多条按顺序认领", map[string][]uint{"a": {3, 7}}, []string{"a", "a", "a"}, []uint{3, 7, 0}},
+		{"不同键互不影响", map[string][]uint{"a": {1}, "b": {2}}, []string{"b", "a", "b"}, []uint{2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := backupMatcher{}
+			for key, ids := range tt.existing {
+				for _, id := range ids {
+					m.add(key, id)
+				}
+			}
+			for i, key := range tt.claims {
+				id, ok := m.claim(key)
+				if ok != (tt.want[i] != 0) || id != tt.want[i] {
+					t.Errorf("claim %d (%q) = %d, %v, want %d", i, key, id, ok, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func uintPtr(v uint) *uint { return &v }
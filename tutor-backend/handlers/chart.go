@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vicanso/go-charts/v2"
+)
+
+// 图表类型
+const (
+	ChartLine  = "line"
+	ChartBar   = "bar"
+	ChartRadar = "radar"
+)
+
+// 图表输出格式
+const (
+	ChartFormatPNG  = "png"
+	ChartFormatSVG  = "svg"
+	ChartFormatJSON = "json"
+)
+
+// 图表尺寸范围
+const (
+	minChartWidth  = 200
+	maxChartWidth  = 2000
+	minChartHeight = 150
+	maxChartHeight = 1500
+)
+
+// chineseFont 已加载的中文字体数据，图表与 PDF 共用；加载失败时为空
+var chineseFont []byte
+
+func init() {
+	// 加载中文字体
+	fontPath := filepath.Join("fonts", "NotoSansSC-Regular.ttf")
+	fontData, err := os.ReadFile(fontPath)
+	if err != nil {
+		log.Printf("警告: 无法加载字体文件 %s: %v", fontPath, err)
+		return
+	}
+	chineseFont = fontData
+
+	err = charts.InstallFont("noto", fontData)
+	if err != nil {
+		log.Printf("警告: 安装字体失败: %v", err)
+		return
+	}
+
+	// 获取字体并设置为默认
+	font, err := charts.GetFont("noto")
+	if err != nil {
+		log.Printf("警告: 获取字体失败: %v", err)
+		return
+	}
+	charts.SetDefaultFont(font)
+	log.Println("中文字体加载成功")
+}
+
+// ChartSeries 图表中的一条数据序列，缺失的数据点为 null
+type ChartSeries struct {
+	Name   string     `json:"name"`
+	Values []*float64 `json:"values"`
+	Goal   bool       `json:"goal,omitempty"` // 目标线：渲染时不标平均线与数值
+}
+
+// ChartData 图表数据，与渲染方式无关
+type ChartData struct {
+	Type      string        `json:"type"` // line, bar, radar
+	Title     string        `json:"title"`
+	Labels    []string      `json:"labels"` // 折线图、柱状图为横轴标签，雷达图为各维度名称
+	Series    []ChartSeries `json:"series"`
+	Min       *float64      `json:"min,omitempty"` // 纵轴下限，雷达图不使用
+	Max       *float64      `json:"max,omitempty"` // 纵轴上限，雷达图为各维度的最大值
+	MarkAvg   bool          `json:"mark_avg"`      // 是否为每条序列标出平均线
+	ShowLabel bool          `json:"show_label"`    // 是否在数据点上显示数值
+}
+
+// chartOptions 图表的输出格式、尺寸与主题
+type chartOptions struct {
+	Format string
+	Width  int
+	Height int
+	Theme  string
+}
+
+var defaultChartOptions = chartOptions{Format: ChartFormatPNG, Width: 800, Height: 500, Theme: charts.ThemeLight}
+
+// parseChartOptions 解析 format、width、height、theme 查询参数，未提供的使用默认值
+func parseChartOptions(c *gin.Context) (chartOptions, error) {
+	opts := defaultChartOptions
+	if format := c.Query("format"); format != "" {
+		switch format {
+		case ChartFormatPNG, ChartFormatSVG, ChartFormatJSON:
+			opts.Format = format
+		default:
+			return opts, fmt.Errorf("format 只能是 png、svg 或 json")
+		}
+	}
+	if theme := c.Query("theme"); theme != "" {
+		switch theme {
+		case charts.ThemeLight, charts.ThemeDark:
+			opts.Theme = theme
+		default:
+			return opts, fmt.Errorf("theme 只能是 light 或 dark")
+		}
+	}
+	var err error
+	if opts.Width, err = parseChartSize(c, "width", opts.Width, minChartWidth, maxChartWidth); err != nil {
+		return opts, err
+	}
+	if opts.Height, err = parseChartSize(c, "height", opts.Height, minChartHeight, maxChartHeight); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func parseChartSize(c *gin.Context, name string, def, lo, hi int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("%s 必须是 %d-%d 之间的整数", name, lo, hi)
+	}
+	return v, nil
+}
+
+// renderChart 将图表数据渲染为 PNG 或 SVG
+func renderChart(data ChartData, options chartOptions) ([]byte, error) {
+	if len(data.Series) == 0 {
+		return nil, fmt.Errorf("没有可绘制的数据")
+	}
+	outputType := charts.ChartOutputPNG
+	if options.Format == ChartFormatSVG {
+		outputType = charts.ChartOutputSVG
+		// SVG 渲染器不转义文本，学生、科目名称需先转义
+		data = escapeChartText(data)
+	}
+	values := make([][]float64, len(data.Series))
+	names := make([]string, len(data.Series))
+	for i, s := range data.Series {
+		names[i] = s.Name
+		values[i] = make([]float64, len(s.Values))
+		for j, v := range s.Values {
+			if v == nil {
+				values[i][j] = charts.GetNullValue()
+			} else {
+				values[i][j] = *v
+			}
+		}
+	}
+
+	opts := []charts.OptionFunc{
+		charts.TypeOptionFunc(outputType),
+		charts.TitleTextOptionFunc(data.Title),
+		charts.ThemeOptionFunc(options.Theme),
+		charts.WidthOptionFunc(options.Width),
+		charts.HeightOptionFunc(options.Height),
+		charts.LegendOptionFunc(charts.LegendOption{
+			Show: charts.TrueFlag(),
+			Data: names,
+		}),
+		charts.PaddingOptionFunc(charts.Box{
+			Top:    50,
+			Right:  40,
+			Bottom: 30,
+			Left:   50,
+		}),
+	}
+	if data.Type != ChartRadar {
+		opts = append(opts,
+			charts.XAxisDataOptionFunc(data.Labels),
+			charts.YAxisOptionFunc(charts.YAxisOption{Min: data.Min, Max: data.Max}),
+		)
+	}
+	opts = append(opts, func(opt *charts.ChartOption) {
+		for i := range opt.SeriesList {
+			if data.Series[i].Goal {
+				continue
+			}
+			opt.SeriesList[i].Label.Show = data.ShowLabel
+			opt.SeriesList[i].Label.FontSize = 11
+			if data.MarkAvg {
+				opt.SeriesList[i].MarkLine = charts.NewMarkLine(charts.SeriesMarkDataTypeAverage)
+			}
+		}
+	})
+
+	var p *charts.Painter
+	var err error
+	switch data.Type {
+	case ChartLine:
+		p, err = charts.LineRender(values, opts...)
+	case ChartBar:
+		p, err = charts.BarRender(values, opts...)
+	case ChartRadar:
+		if len(data.Labels) < 3 {
+			return nil, fmt.Errorf("雷达图至少需要 3 个维度")
+		}
+		indicatorMax := 100.0
+		if data.Max != nil {
+			indicatorMax = *data.Max
+		}
+		maxes := make([]float64, len(data.Labels))
+		for i := range maxes {
+			maxes[i] = indicatorMax
+		}
+		p, err = charts.RadarRender(values, append(opts, charts.RadarIndicatorOptionFunc(data.Labels, maxes))...)
+	default:
+		return nil, fmt.Errorf("不支持的图表类型: %s", data.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.Bytes()
+}
+
+// encodeChart 按输出格式编码图表；json 格式返回图表数据供前端渲染
+func encodeChart(data ChartData, options chartOptions) (string, []byte, error) {
+	switch options.Format {
+	case ChartFormatJSON:
+		buf, err := json.Marshal(data)
+		return "application/json; charset=utf-8", buf, err
+	case ChartFormatSVG:
+		buf, err := renderChart(data, options)
+		return "image/svg+xml", buf, err
+	default:
+		buf, err := renderChart(data, options)
+		return "image/png", buf, err
+	}
+}
+
+// escapeChartText 返回文本经过 XML 转义的图表数据副本
+func escapeChartText(data ChartData) ChartData {
+	escaped := data
+	escaped.Title = html.EscapeString(data.Title)
+	escaped.Labels = make([]string, len(data.Labels))
+	for i, label := range data.Labels {
+		escaped.Labels[i] = html.EscapeString(label)
+	}
+	escaped.Series = make([]ChartSeries, len(data.Series))
+	for i, s := range data.Series {
+		escaped.Series[i] = ChartSeries{Name: html.EscapeString(s.Name), Values: s.Values, Goal: s.Goal}
+	}
+	return escaped
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"tutor-management/config"
+)
+
+// ChartCache 已渲染图表的 LRU 缓存
+// 缓存键包含学生成绩的最新修改时间，成绩变更后旧条目不会再命中；
+// 成绩增删改时另行按学生清除，避免过期条目占用容量
+type ChartCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List // 最近使用的在前
+}
+
+type chartCacheEntry struct {
+	key         string
+	studentIDs  []uint
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// NewChartCache 创建图表缓存，容量为 0 时不缓存
+func NewChartCache(cfg config.ChartConfig) *ChartCache {
+	return &ChartCache{
+		capacity: cfg.CacheEntries,
+		ttl:      time.Duration(cfg.CacheMinutes) * time.Minute,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Get 读取缓存的图表
+func (c *ChartCache) Get(key string) (contentType string, body []byte, ok bool) {
+	if c == nil || c.capacity <= 0 {
+		return "", nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", nil, false
+	}
+	entry := el.Value.(*chartCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(el)
+		return "", nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.contentType, entry.body, true
+}
+
+// Put 缓存图表，studentIDs 为图表涉及的学生，用于失效
+func (c *ChartCache) Put(key string, studentIDs []uint, contentType string, body []byte) {
+	if c == nil || c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&chartCacheEntry{
+		key:         key,
+		studentIDs:  studentIDs,
+		contentType: contentType,
+		body:        body,
+		expiresAt:   time.Now().Add(c.ttl),
+	})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// InvalidateStudent 清除涉及指定学生的全部图表
+func (c *ChartCache) InvalidateStudent(studentID uint) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		for _, id := range el.Value.(*chartCacheEntry).studentIDs {
+			if id == studentID {
+				c.remove(el)
+				break
+			}
+		}
+		el = next
+	}
+}
+
+func (c *ChartCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*chartCacheEntry).key)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vicanso/go-charts/v2"
+)
+
+func TestParseChartOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    chartOptions
+		wantErr string
+	}{
+		{"默认值", "", defaultChartOptions, ""},
+		{"指定全部参数", "format=svg&theme=dark&width=1200&height=600", chartOptions{Format: ChartFormatSVG, Width: 1200, Height: 600, Theme: charts.ThemeDark}, ""},
+		{"JSON 格式", "format=json", chartOptions{Format: ChartFormatJSON, Width: 800, Height: 500, Theme: charts.ThemeLight}, ""},
+		{"尺寸下限", "width=200&height=150", chartOptions{Format: ChartFormatPNG, Width: 200, Height: 150, Theme: charts.ThemeLight}, ""},
+		{"尺寸上限", "width=2000&height=1500", chartOptions{Format: ChartFormatPNG, Width: 2000, Height: 1500, Theme: charts.ThemeLight}, ""},
+		{"不支持的格式", "format=jpg", chartOptions{}, "format 只能是 png、svg 或 json"},
+		{"不支持的主题", "theme=blue", chartOptions{}, "theme 只能是 light 或 dark"},
+		{"宽度过小", "width=199", chartOptions{}, "width 必须是 200-2000 之间的整数"},
+		{"高度过大", "height=1501", chartOptions{}, "height 必须是 150-1500 之间的整数"},
+		{"宽度不是整数", "width=80%25", chartOptions{}, "width 必须是 200-2000 之间的整数"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/chart.png?"+tt.query, nil)
+			got, err := parseChartOptions(c)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("options = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"tutor-management/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chartPathPattern 允许签名的图表地址
+var chartPathPattern = regexp.MustCompile(`^/api/analysis/(\d+/(trend|radar|delta)|compare)\.png$`)
+
+// ChartURLRequest 申请图表签名地址请求
+type ChartURLRequest struct {
+	URL string `json:"url" binding:"required,max=2000"` // 图表地址，如 /api/analysis/1/trend.png?course_id=2
+}
+
+// ChartURLResponse 图表签名地址
+type ChartURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// chartQuery 去掉签名参数后按参数名排序的查询字符串，用于签名与缓存键
+func chartQuery(query url.Values) string {
+	canonical := url.Values{}
+	for k, v := range query {
+		if k != "sig" && k != "expires" {
+			canonical[k] = v
+		}
+	}
+	return canonical.Encode()
+}
+
+func chartSignature(key []byte, path, query string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + query + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signChartURL 为图表地址附加 expires 与 sig 参数
+func signChartURL(key []byte, path string, query url.Values, expiresAt time.Time) string {
+	canonical := chartQuery(query)
+	signed, _ := url.ParseQuery(canonical)
+	signed.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	signed.Set("sig", chartSignature(key, path, canonical, expiresAt.Unix()))
+	return path + "?" + signed.Encode()
+}
+
+// ChartAuthMiddleware 图表接口认证：带 sig 参数时校验签名与有效期，否则按 JWT 认证
+// 签名地址供 <img> 等无法携带 Authorization 头的场景使用
+func ChartAuthMiddleware(cfg config.ChartConfig) gin.HandlerFunc {
+	key := []byte(cfg.SigningKey)
+	jwtAuth := JWTMiddleware()
+	return func(c *gin.Context) {
+		sig := c.Query("sig")
+		if sig == "" {
+			jwtAuth(c)
+			return
+		}
+		expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+		expected := chartSignature(key, c.Request.URL.Path, chartQuery(c.Request.URL.Query()), expires)
+		if err != nil || time.Now().Unix() > expires || !hmac.Equal([]byte(sig), []byte(expected)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "图表链接无效或已过期"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SignChartURL 生成图表签名地址
+// @Summary 生成图表签名地址
+// @Description 为成绩分析图表生成带有效期的签名地址，可直接用于 <img> 标签而无需携带 token
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ChartURLRequest true "图表地址"
+// @Success 200 {object} ChartURLResponse
+// @Failure 400 {object} map[string]string
+// @Router /analysis/signed-url [post]
+func (h *TrendHandler) SignChartURL(c *gin.Context) {
+	var req ChartURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || u.IsAbs() || !chartPathPattern.MatchString(u.Path) {
+		c.JSON(http.StatusBadRequest, fieldError("url", "不是有效的图表地址"))
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(h.Config.SignedURLMinutes) * time.Minute).Truncate(time.Second)
+	c.JSON(http.StatusOK, ChartURLResponse{
+		URL:       signChartURL([]byte(h.Config.SigningKey), u.Path, u.Query(), expiresAt),
+		ExpiresAt: expiresAt,
+	})
+}
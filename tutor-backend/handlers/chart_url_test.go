@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChartQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"空查询", "", ""},
+		{"按参数名排序", "width=600&course_id=2", "course_id=2&width=600"},
+		{"去掉签名参数", "course_id=2&sig=abc&expires=123", "course_id=2"},
+		{"保留重复参数", "b=2&a=1&a=0", "a=1&a=0&b=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			if got := chartQuery(values); got != tt.want {
+				t.Errorf("chartQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChartAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.ChartConfig{SigningKey: "0123456789abcdef0123456789abcdef"}
+	key := []byte(cfg.SigningKey)
+	path := "/api/analysis/1/trend.png"
+	future := time.Now().Add(time.Hour)
+	signed := signChartURL(key, path, url.Values{"course_id": {"2"}}, future)
+	signedURL, _ := url.Parse(signed)
+	q := signedURL.Query()
+
+	with := func(modify func(url.Values)) string {
+		v := url.Values{}
+		for k, vs := range q {
+			v[k] = append([]string(nil), vs...)
+		}
+		modify(v)
+		return path + "?" + v.Encode()
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"有效签名", signed, http.StatusOK},
+		{"参数重新排序仍有效", with(func(v url.Values) {}), http.StatusOK},
+		{"篡改参数", with(func(v url.Values) { v.Set("course_id", "3") }), http.StatusForbidden},
+		{"追加参数", with(func(v url.Values) { v.Set("width", "600") }), http.StatusForbidden},
+		{"篡改有效期", with(func(v url.Values) { v.Set("expires", strconv.FormatInt(future.Unix()+60, 10)) }), http.StatusForbidden},
+		{"其他密钥签名", signChartURL([]byte("another-key-another-key-another-k"), path, url.Values{"course_id": {"2"}}, future), http.StatusForbidden},
+		{"已过期", signChartURL(key, path, url.Values{"course_id": {"2"}}, time.Now().Add(-time.Minute)), http.StatusForbidden},
+		{"签名用于其他学生", "/api/analysis/2/trend.png?" + q.Encode(), http.StatusForbidden},
+		{"无签名且无 token", path, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/api/analysis/:student_id/trend.png", ChartAuthMiddleware(cfg), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.url, w.Code, tt.want)
+			}
+		})
+	}
+}
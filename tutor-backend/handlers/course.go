@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"tutor-management/models"
 
@@ -10,27 +11,93 @@ import (
 )
 
 type CourseHandler struct {
-	DB *gorm.DB
+	DB         *gorm.DB
+	ChartCache *ChartCache // 删除课程时一并删除学习目标，需清除相关学生的图表缓存
 }
 
-func NewCourseHandler(db *gorm.DB) *CourseHandler {
-	return &CourseHandler{DB: db}
+func NewCourseHandler(db *gorm.DB, chartCache *ChartCache) *CourseHandler {
+	return &CourseHandler{DB: db, ChartCache: chartCache}
+}
+
+// CreateCourseRequest 创建课程请求
+type CreateCourseRequest struct {
+	Name        string `json:"name" binding:"required,notblank,max=50"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// UpdateCourseRequest 更新课程请求，未提供的字段保持不变
+type UpdateCourseRequest struct {
+	Name        *string `json:"name" binding:"omitempty,notblank,max=50"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+}
+
+// CourseResponse 课程信息
+type CourseResponse struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     uint      `json:"version"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+}
+
+func (r CreateCourseRequest) toModel() models.Course {
+	return models.Course{
+		Version:     1,
+		Name:        r.Name,
+		Description: r.Description,
+	}
+}
+
+func (r UpdateCourseRequest) apply(course *models.Course) {
+	if r.Name != nil {
+		course.Name = *r.Name
+	}
+	if r.Description != nil {
+		course.Description = *r.Description
+	}
+}
+
+func newCourseResponse(course models.Course) CourseResponse {
+	return CourseResponse{
+		ID:          course.ID,
+		CreatedAt:   course.CreatedAt,
+		UpdatedAt:   course.UpdatedAt,
+		Version:     course.Version,
+		Name:        course.Name,
+		Description: course.Description,
+	}
+}
+
+// courseListSpec 课程列表允许的排序与筛选字段
+var courseListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "id ASC",
+	LikeFilters: map[string]string{"name": "name"},
 }
 
 // GetAll 获取所有课程
 // @Summary 获取所有课程
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
 // @Tags 课程管理
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {array} models.Course
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, name, created_at, updated_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Param name query string false "课程名称（模糊匹配）"
+// @Success 200 {array} CourseResponse
+// @Failure 400 {object} map[string]string
 // @Router /courses [get]
 func (h *CourseHandler) GetAll(c *gin.Context) {
-	var courses []models.Course
-	if err := h.DB.Find(&courses).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, courses)
+	respondList(c, h.DB, courseListSpec, newCourseResponse)
 }
 
 // Create 创建课程
@@ -39,33 +106,61 @@ func (h *CourseHandler) GetAll(c *gin.Context) {
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param course body models.Course true "课程信息"
-// @Success 201 {object} models.Course
+// @Param course body CreateCourseRequest true "课程信息"
+// @Success 201 {object} CourseResponse
+// @Failure 400 {object} map[string]string
 // @Router /courses [post]
 func (h *CourseHandler) Create(c *gin.Context) {
-	var course models.Course
-	if err := c.ShouldBindJSON(&course); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
+	course := req.toModel()
 	if err := h.DB.Create(&course).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, course)
+	respondVersioned(c, http.StatusCreated, course.Version, newCourseResponse(course))
+}
+
+// Get 获取课程详情
+// @Summary 获取课程详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 课程管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "课程ID"
+// @Success 200 {object} CourseResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /courses/{id} [get]
+func (h *CourseHandler) Get(c *gin.Context) {
+	var course models.Course
+	if err := h.DB.First(&course, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, course.Version, newCourseResponse(course))
 }
 
 // Update 更新课程
 // @Summary 更新课程
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412
 // @Tags 课程管理
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "课程ID"
-// @Param course body models.Course true "课程信息"
-// @Success 200 {object} models.Course
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param course body UpdateCourseRequest true "课程信息"
+// @Success 200 {object} CourseResponse
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /courses/{id} [put]
+// @Router /courses/{id} [patch]
 func (h *CourseHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var course models.Course
@@ -73,29 +168,59 @@ func (h *CourseHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
 		return
 	}
-	if err := c.ShouldBindJSON(&course); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !checkIfMatch(c, course.Version) {
 		return
 	}
-	if err := h.DB.Save(&course).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req UpdateCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
-	c.JSON(http.StatusOK, course)
+	req.apply(&course)
+	if err := saveVersioned(h.DB, &course, &course.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	respondVersioned(c, http.StatusOK, course.Version, newCourseResponse(course))
 }
 
 // Delete 删除课程
 // @Summary 删除课程
+// @Description 该课程的学习目标一并删除
 // @Tags 课程管理
 // @Security BearerAuth
 // @Param id path int true "课程ID"
+// @Param If-Match header string true "当前版本的 ETag"
 // @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /courses/{id} [delete]
 func (h *CourseHandler) Delete(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.DB.Delete(&models.Course{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var course models.Course
+	if err := h.DB.First(&course, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
 		return
 	}
+	if !checkIfMatch(c, course.Version) {
+		return
+	}
+	var studentIDs []uint
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Goal{}).Where("course_id = ?", course.ID).Distinct().Pluck("student_id", &studentIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("course_id = ?", course.ID).Delete(&models.Goal{}).Error; err != nil {
+			return err
+		}
+		return deleteVersioned(tx, &course, course.Version)
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	for _, id := range studentIDs {
+		h.ChartCache.InvalidateStudent(id)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
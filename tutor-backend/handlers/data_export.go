@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize 导出时每次从数据库读取的行数
+const exportBatchSize = 500
+
+// 导出表格中使用的中文标签
+var (
+	examTypeLabels = map[string]string{
+		"midterm": "期中",
+		"final":   "期末",
+		"quiz":    "小测",
+	}
+	attendanceLabels = map[string]string{
+		AttendancePresent: "出勤",
+		"late":            "迟到",
+		"absent":          "缺勤",
+		"excused":         "请假",
+	}
+	cancelledByLabels = map[string]string{
+		"student": "学生",
+		"parent":  "家长",
+		"tutor":   "老师",
+	}
+)
+
+// exportColumn 导出表格的一列
+type exportColumn[T any] struct {
+	Header string
+	Value  func(T) interface{}
+}
+
+// ExportHandler 数据导出处理器
+type ExportHandler struct {
+	DB *gorm.DB
+}
+
+// NewExportHandler 创建数据导出处理器
+func NewExportHandler(db *gorm.DB) *ExportHandler {
+	return &ExportHandler{DB: db}
+}
+
+var studentExportColumns = []exportColumn[models.Student]{
+	{"ID", func(s models.Student) interface{} { return s.ID }},
+	{"姓名", func(s models.Student) interface{} { return s.Name }},
+	{"家长电话", func(s models.Student) interface{} { return s.ParentPhone }},
+	{"年级", func(s models.Student) interface{} { return s.Grade }},
+	{"备注", func(s models.Student) interface{} { return s.Notes }},
+	{"创建时间", func(s models.Student) interface{} { return formatExportTime(&s.CreatedAt) }},
+}
+
+var courseExportColumns = []exportColumn[models.Course]{
+	{"ID", func(c models.Course) interface{} { return c.ID }},
+	{"名称", func(c models.Course) interface{} { return c.Name }},
+	{"描述", func(c models.Course) interface{} { return c.Description }},
+	{"创建时间", func(c models.Course) interface{} { return formatExportTime(&c.CreatedAt) }},
+}
+
+var scheduleExportColumns = []exportColumn[models.Schedule]{
+	{"ID", func(s models.Schedule) interface{} { return s.ID }},
+	{"学生", func(s models.Schedule) interface{} { return s.Student.Name }},
+	{"课程", func(s models.Schedule) interface{} { return s.Course.Name }},
+	{"开始时间", func(s models.Schedule) interface{} { return formatExportTime(&s.StartTime) }},
+	{"结束时间", func(s models.Schedule) interface{} { return formatExportTime(&s.EndTime) }},
+	{"课时(小时)", func(s models.Schedule) interface{} { return scheduleHours(s) }},
+	{"状态", func(s models.Schedule) interface{} { return exportLabel(scheduleStatusLabels, s.Status) }},
+	{"考勤", func(s models.Schedule) interface{} { return exportLabel(attendanceLabels, s.Attendance) }},
+	{"签到时间", func(s models.Schedule) interface{} { return formatExportTime(s.CheckInAt) }},
+	{"签退时间", func(s models.Schedule) interface{} { return formatExportTime(s.CheckOutAt) }},
+	{"取消方", func(s models.Schedule) interface{} { return exportLabel(cancelledByLabels, s.CancelledBy) }},
+	{"取消原因", func(s models.Schedule) interface{} { return s.CancelReason }},
+	{"迟取消", func(s models.Schedule) interface{} {
+		if s.LateCancel {
+			return "是"
+		}
+		return ""
+	}},
+}
+
+// examResultExportColumns 表头与成绩导入识别的表头一致，导出的文件可直接再导入
+var examResultExportColumns = []exportColumn[models.ExamResult]{
+	{"ID", func(r models.ExamResult) interface{} { return r.ID }},
+	{"姓名", func(r models.ExamResult) interface{} {
+		if r.Student != nil {
+			return r.Student.Name
+		}
+		return ""
+	}},
+	{"科目", func(r models.ExamResult) interface{} {
+		if r.Course != nil {
+			return r.Course.Name
+		}
+		return ""
+	}},
+	{"考试类型", func(r models.ExamResult) interface{} { return exportLabel(examTypeLabels, r.ExamType) }},
+	{"考试名称", func(r models.ExamResult) interface{} { return r.ExamName }},
+	{"分数", func(r models.ExamResult) interface{} { return r.Score }},
+	{"满分", func(r models.ExamResult) interface{} { return r.FullScore }},
+	{"得分率(%)", func(r models.ExamResult) interface{} { return scorePercentage(r) }},
+	{"考试日期", func(r models.ExamResult) interface{} { return r.ExamDate.Format("2006-01-02") }},
+	{"评语", func(r models.ExamResult) interface{} { return r.Comment }},
+}
+
+// ExportStudents 导出学生
+// @Summary 导出学生
+// @Description 筛选与排序参数同学生列表，分批读取并流式写出
+// @Tags 数据导出
+// @Security BearerAuth
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "导出格式: csv（默认）, xlsx"
+// @Param name query string false "姓名（模糊匹配）"
+// @Param grade query string false "年级"
+// @Param sort query string false "排序字段"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Router /export/students [get]
+func (h *ExportHandler) ExportStudents(c *gin.Context) {
+	streamExport(c, h.DB, studentListSpec, "学生", studentExportColumns)
+}
+
+// ExportCourses 导出课程
+// @Summary 导出课程
+// @Tags 数据导出
+// @Security BearerAuth
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "导出格式: csv（默认）, xlsx"
+// @Param name query string false "名称（模糊匹配）"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Router /export/courses [get]
+func (h *ExportHandler) ExportCourses(c *gin.Context) {
+	streamExport(c, h.DB, courseListSpec, "课程", courseExportColumns)
+}
+
+// ExportSchedules 导出排课
+// @Summary 导出排课
+// @Description 筛选与排序参数同排课列表，按上课时间筛选日期范围
+// @Tags 数据导出
+// @Security BearerAuth
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "导出格式: csv（默认）, xlsx"
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)"
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param status query string false "状态"
+// @Param sort query string false "排序字段"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Router /export/schedules [get]
+func (h *ExportHandler) ExportSchedules(c *gin.Context) {
+	streamExport(c, h.DB, scheduleListSpec, "排课", scheduleExportColumns)
+}
+
+// ExportExamResults 导出成绩
+// @Summary 导出成绩
+// @Description 筛选与排序参数同成绩列表；表头与成绩导入一致，可直接再导入
+// @Tags 数据导出
+// @Security BearerAuth
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "导出格式: csv（默认）, xlsx"
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param exam_type query string false "考试类型"
+// @Param exam_name query string false "考试名称（模糊匹配）"
+// @Param start_date query string false "考试开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "考试结束日期 (yyyy-MM-dd)"
+// @Param sort query string false "排序字段"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Router /export/exam-results [get]
+func (h *ExportHandler) ExportExamResults(c *gin.Context) {
+	streamExport(c, h.DB, examResultListSpec, "成绩", examResultExportColumns)
+}
+
+// streamExport 按列表接口的筛选与排序规则分批查询，并逐行写出 CSV 或 XLSX
+// 分页参数会被忽略，始终导出全部匹配的记录
+func streamExport[T any](c *gin.Context, db *gorm.DB, spec ListSpec, title string, columns []exportColumn[T]) {
+	format := c.DefaultQuery("format", FormatCSV)
+	if format != FormatCSV && format != FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return
+	}
+	q, err := ParseListQuery(c, spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var model T
+	query, err := spec.ApplyFilters(c, db.Model(&model))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, p := range spec.Preloads {
+		query = query.Preload(p)
+	}
+	// 追加 id 保证分批读取时顺序稳定
+	query = query.Order(q.Sort).Order("id ASC")
+
+	table := tableExport{
+		Filename: fmt.Sprintf("%s-%s", title, time.Now().Format("20060102")),
+		Sheet:    title,
+		Headers:  make([]string, len(columns)),
+	}
+	for i, col := range columns {
+		table.Headers[i] = col.Header
+	}
+
+	// 先读取第一批，查询出错时仍可返回 JSON 错误
+	batch := make([]T, 0, exportBatchSize)
+	if err := query.Session(&gorm.Session{}).Limit(exportBatchSize).Find(&batch).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := newTableWriter(c, format, table)
+	if err != nil {
+		abortExport(c, title, err)
+		return
+	}
+
+	row := make([]interface{}, len(columns))
+	for offset := 0; ; {
+		for _, item := range batch {
+			for i, col := range columns {
+				row[i] = col.Value(item)
+			}
+			if err := w.WriteRow(row); err != nil {
+				abortExport(c, title, err)
+				return
+			}
+		}
+		if len(batch) < exportBatchSize {
+			break
+		}
+		offset += exportBatchSize
+		batch = batch[:0]
+		if err := query.Session(&gorm.Session{}).Offset(offset).Limit(exportBatchSize).Find(&batch).Error; err != nil {
+			abortExport(c, title, err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		abortExport(c, title, err)
+	}
+}
+
+// abortExport 导出出错：尚未写出内容时返回 500，否则中断连接，避免客户端收到不完整的文件
+func abortExport(c *gin.Context, title string, err error) {
+	utils.Error("Export failed", zap.String("table", title), zap.Error(err))
+	if !c.Writer.Written() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func exportLabel(labels map[string]string, value string) string {
+	if label, ok := labels[value]; ok {
+		return label
+	}
+	return value
+}
+
+// formatExportTime 格式化为本地时间，空值输出为空
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
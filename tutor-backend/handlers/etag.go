@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errVersionConflict 记录已被其他客户端修改
+var errVersionConflict = errors.New("记录已被修改，请刷新后重试")
+
+// versionETag 根据记录版本号生成强 ETag
+func versionETag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// etagMatches 判断 If-Match / If-None-Match 头是否包含指定 ETag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch 校验写操作的 If-Match 头
+// 缺少时返回 428，与当前版本不一致时返回 412，均已写出响应
+func checkIfMatch(c *gin.Context, version uint) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "缺少 If-Match 请求头"})
+		return false
+	}
+	if !etagMatches(header, versionETag(version)) {
+		c.Header("ETag", versionETag(version))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionConflict.Error()})
+		return false
+	}
+	return true
+}
+
+// respondVersioned 写出单条记录，附带基于版本号的 ETag，并处理 If-None-Match
+func respondVersioned(c *gin.Context, status int, version uint, body interface{}) {
+	etag := versionETag(version)
+	c.Header("ETag", etag)
+	if status == http.StatusOK && etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(status, body)
+}
+
+// respondWithETag 写出响应体，附带基于内容摘要的弱 ETag，并处理 If-None-Match
+func respondWithETag(c *gin.Context, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondDataWithETag(c, "application/json; charset=utf-8", data)
+}
+
+// respondDataWithETag 写出已编码的响应体，附带基于内容摘要的弱 ETag，并处理 If-None-Match
+func respondDataWithETag(c *gin.Context, contentType string, data []byte) {
+	sum := sha1.Sum(data)
+	etag := `W/"` + hex.EncodeToString(sum[:]) + `"`
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// saveVersioned 以乐观锁方式保存记录：仅当数据库中的版本仍为 version 时写入，并将版本号加一
+func saveVersioned(db *gorm.DB, model interface{}, version *uint) error {
+	current := *version
+	*version = current + 1
+	res := db.Model(model).
+		Where("version = ?", current).
+		Select("*").
+		Omit("id", "created_at", clause.Associations).
+		Updates(model)
+	if res.Error != nil {
+		*version = current
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		*version = current
+		return errVersionConflict
+	}
+	return nil
+}
+
+// deleteVersioned 以乐观锁方式删除记录
+func deleteVersioned(db *gorm.DB, model interface{}, version uint) error {
+	res := db.Where("version = ?", version).Delete(model)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
+// conflictError 违反业务规则的写操作（如补课权益已被使用），响应 409
+type conflictError struct {
+	msg string
+}
+
+func (e conflictError) Error() string {
+	return e.msg
+}
+
+// respondWriteError 写出保存/删除失败的响应，版本冲突返回 412，业务冲突返回 409
+func respondWriteError(c *gin.Context, err error) {
+	if errors.Is(err, errVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	var conflict conflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -2,53 +2,178 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"tutor-management/models"
+	"tutor-management/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type ExamResultHandler struct {
-	DB *gorm.DB
+	DB         *gorm.DB
+	ChartCache *ChartCache     // 成绩变更时清除相关学生的图表缓存
+	Storage    storage.Storage // 删除成绩时一并删除其附件文件
 }
 
-func NewExamResultHandler(db *gorm.DB) *ExamResultHandler {
-	return &ExamResultHandler{DB: db}
+func NewExamResultHandler(db *gorm.DB, chartCache *ChartCache, store storage.Storage) *ExamResultHandler {
+	return &ExamResultHandler{DB: db, ChartCache: chartCache, Storage: store}
+}
+
+// CreateExamResultRequest 创建成绩请求
+type CreateExamResultRequest struct {
+	StudentID uint      `json:"student_id" binding:"required"`
+	CourseID  uint      `json:"course_id" binding:"required"`
+	ExamType  string    `json:"exam_type" binding:"required,oneof=midterm final quiz"`
+	ExamName  string    `json:"exam_name" binding:"required,notblank,max=100"`
+	Score     float64   `json:"score" binding:"gte=0,ltefield=FullScore"`
+	FullScore float64   `json:"full_score" binding:"required,gt=0"`
+	ExamDate  time.Time `json:"exam_date" binding:"required"`
+	Comment   string    `json:"comment" binding:"max=500"`
+}
+
+// UpdateExamResultRequest 更新成绩请求，未提供的字段保持不变
+type UpdateExamResultRequest struct {
+	StudentID *uint      `json:"student_id" binding:"omitempty,gt=0"`
+	CourseID  *uint      `json:"course_id" binding:"omitempty,gt=0"`
+	ExamType  *string    `json:"exam_type" binding:"omitempty,oneof=midterm final quiz"`
+	ExamName  *string    `json:"exam_name" binding:"omitempty,notblank,max=100"`
+	Score     *float64   `json:"score" binding:"omitempty,gte=0"`
+	FullScore *float64   `json:"full_score" binding:"omitempty,gt=0"`
+	ExamDate  *time.Time `json:"exam_date"`
+	Comment   *string    `json:"comment" binding:"omitempty,max=500"`
+}
+
+// ExamResultResponse 成绩信息
+type ExamResultResponse struct {
+	ID         uint             `json:"id"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	Version    uint             `json:"version"`
+	StudentID  uint             `json:"student_id"`
+	Student    *StudentResponse `json:"student,omitempty"`
+	CourseID   uint             `json:"course_id"`
+	Course     *CourseResponse  `json:"course,omitempty"`
+	ExamType   string           `json:"exam_type"`
+	ExamName   string           `json:"exam_name"`
+	Score      float64          `json:"score"`
+	FullScore  float64          `json:"full_score"`
+	Percentage float64          `json:"percentage"` // 得分率（%），便于比较不同满分的考试
+	ExamDate   time.Time        `json:"exam_date"`
+	Comment    string           `json:"comment"`
+}
+
+func (r CreateExamResultRequest) toModel() models.ExamResult {
+	return models.ExamResult{
+		Version:   1,
+		StudentID: r.StudentID,
+		CourseID:  r.CourseID,
+		ExamType:  r.ExamType,
+		ExamName:  r.ExamName,
+		Score:     r.Score,
+		FullScore: r.FullScore,
+		ExamDate:  r.ExamDate,
+		Comment:   r.Comment,
+	}
+}
+
+func (r UpdateExamResultRequest) apply(result *models.ExamResult) {
+	if r.StudentID != nil {
+		result.StudentID = *r.StudentID
+	}
+	if r.CourseID != nil {
+		result.CourseID = *r.CourseID
+	}
+	if r.ExamType != nil {
+		result.ExamType = *r.ExamType
+	}
+	if r.ExamName != nil {
+		result.ExamName = *r.ExamName
+	}
+	if r.Score != nil {
+		result.Score = *r.Score
+	}
+	if r.FullScore != nil {
+		result.FullScore = *r.FullScore
+	}
+	if r.ExamDate != nil {
+		result.ExamDate = *r.ExamDate
+	}
+	if r.Comment != nil {
+		result.Comment = *r.Comment
+	}
+}
+
+func newExamResultResponse(r models.ExamResult) ExamResultResponse {
+	resp := ExamResultResponse{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Version:    r.Version,
+		StudentID:  r.StudentID,
+		CourseID:   r.CourseID,
+		ExamType:   r.ExamType,
+		ExamName:   r.ExamName,
+		Score:      r.Score,
+		FullScore:  r.FullScore,
+		Percentage: scorePercentage(r),
+		ExamDate:   r.ExamDate,
+		Comment:    r.Comment,
+	}
+	if r.Student != nil {
+		student := newStudentResponse(*r.Student)
+		resp.Student = &student
+	}
+	if r.Course != nil {
+		course := newCourseResponse(*r.Course)
+		resp.Course = &course
+	}
+	return resp
+}
+
+// examResultListSpec 成绩列表允许的排序与筛选字段
+var examResultListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"exam_date":  "exam_date",
+		"score":      "score",
+		"exam_type":  "exam_type",
+		"created_at": "created_at",
+	},
+	DefaultSort: "exam_date DESC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"course_id":  "course_id",
+		"exam_type":  "exam_type",
+	},
+	LikeFilters: map[string]string{"exam_name": "exam_name"},
+	DateRange:   "exam_date",
+	Preloads:    []string{"Student", "Course"},
 }
 
 // GetAll 获取所有成绩
 // @Summary 获取所有成绩
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
 // @Tags 成绩管理
 // @Security BearerAuth
 // @Produce json
 // @Param student_id query int false "学生ID"
 // @Param course_id query int false "课程ID"
 // @Param exam_type query string false "考试类型"
-// @Success 200 {array} models.ExamResult
+// @Param exam_name query string false "考试名称（模糊匹配）"
+// @Param start_date query string false "考试开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "考试结束日期 (yyyy-MM-dd)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, exam_date, score, exam_type, created_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Success 200 {array} ExamResultResponse
+// @Failure 400 {object} map[string]string
 // @Router /exam-results [get]
 func (h *ExamResultHandler) GetAll(c *gin.Context) {
-	var results []models.ExamResult
-	query := h.DB.Preload("Student").Preload("Course")
-
-	// 支持按学生筛选
-	if studentID := c.Query("student_id"); studentID != "" {
-		query = query.Where("student_id = ?", studentID)
-	}
-	// 支持按科目筛选
-	if courseID := c.Query("course_id"); courseID != "" {
-		query = query.Where("course_id = ?", courseID)
-	}
-	// 支持按考试类型筛选
-	if examType := c.Query("exam_type"); examType != "" {
-		query = query.Where("exam_type = ?", examType)
-	}
-
-	if err := query.Order("exam_date desc").Find(&results).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, results)
+	respondList(c, h.DB, examResultListSpec, newExamResultResponse)
 }
 
 // Create 创建成绩
@@ -57,35 +182,64 @@ func (h *ExamResultHandler) GetAll(c *gin.Context) {
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param result body models.ExamResult true "成绩信息"
-// @Success 201 {object} models.ExamResult
+// @Param result body CreateExamResultRequest true "成绩信息"
+// @Success 201 {object} ExamResultResponse
+// @Failure 400 {object} map[string]string
 // @Router /exam-results [post]
 func (h *ExamResultHandler) Create(c *gin.Context) {
-	var result models.ExamResult
-	if err := c.ShouldBindJSON(&result); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateExamResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
+	result := req.toModel()
 	if err := h.DB.Create(&result).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.ChartCache.InvalidateStudent(result.StudentID)
 	// 重新加载关联数据
 	h.DB.Preload("Student").Preload("Course").First(&result, result.ID)
-	c.JSON(http.StatusCreated, result)
+	respondVersioned(c, http.StatusCreated, result.Version, newExamResultResponse(result))
+}
+
+// Get 获取成绩记录详情
+// @Summary 获取成绩记录详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 成绩管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "成绩ID"
+// @Success 200 {object} ExamResultResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /exam-results/{id} [get]
+func (h *ExamResultHandler) Get(c *gin.Context) {
+	var result models.ExamResult
+	if err := h.DB.Preload("Student").Preload("Course").First(&result, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "成绩记录不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, result.Version, newExamResultResponse(result))
 }
 
 // Update 更新成绩
 // @Summary 更新成绩记录
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412
 // @Tags 成绩管理
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "成绩ID"
-// @Param result body models.ExamResult true "成绩信息"
-// @Success 200 {object} models.ExamResult
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param result body UpdateExamResultRequest true "成绩信息"
+// @Success 200 {object} ExamResultResponse
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /exam-results/{id} [put]
+// @Router /exam-results/{id} [patch]
 func (h *ExamResultHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var result models.ExamResult
@@ -93,16 +247,28 @@ func (h *ExamResultHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "成绩记录不存在"})
 		return
 	}
-	if err := c.ShouldBindJSON(&result); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !checkIfMatch(c, result.Version) {
 		return
 	}
-	if err := h.DB.Save(&result).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req UpdateExamResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	previousStudentID := result.StudentID
+	req.apply(&result)
+	if result.Score > result.FullScore {
+		c.JSON(http.StatusBadRequest, fieldError("score", "不能大于 full_score"))
 		return
 	}
+	if err := saveVersioned(h.DB, &result, &result.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.ChartCache.InvalidateStudent(previousStudentID)
+	h.ChartCache.InvalidateStudent(result.StudentID)
 	h.DB.Preload("Student").Preload("Course").First(&result, result.ID)
-	c.JSON(http.StatusOK, result)
+	respondVersioned(c, http.StatusOK, result.Version, newExamResultResponse(result))
 }
 
 // Delete 删除成绩
@@ -110,14 +276,35 @@ func (h *ExamResultHandler) Update(c *gin.Context) {
 // @Tags 成绩管理
 // @Security BearerAuth
 // @Param id path int true "成绩ID"
+// @Param If-Match header string true "当前版本的 ETag"
 // @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /exam-results/{id} [delete]
 func (h *ExamResultHandler) Delete(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.DB.Delete(&models.ExamResult{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var result models.ExamResult
+	if err := h.DB.First(&result, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "成绩记录不存在"})
+		return
+	}
+	if !checkIfMatch(c, result.Version) {
+		return
+	}
+	var attachments []models.Attachment
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if attachments, err = deleteOwnerAttachments(tx, AttachmentOwnerExamResult, []uint{result.ID}); err != nil {
+			return err
+		}
+		return deleteVersioned(tx, &result, result.Version)
+	})
+	if err != nil {
+		respondWriteError(c, err)
 		return
 	}
+	removeAttachmentObjects(h.Storage, attachments)
+	h.ChartCache.InvalidateStudent(result.StudentID)
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
@@ -128,7 +315,7 @@ func (h *ExamResultHandler) Delete(c *gin.Context) {
 // @Produce json
 // @Param student_id path int true "学生ID"
 // @Param course_id query int false "课程ID"
-// @Success 200 {array} models.ExamResult
+// @Success 200 {array} ExamResultResponse
 // @Router /exam-results/student/{student_id} [get]
 func (h *ExamResultHandler) GetByStudent(c *gin.Context) {
 	studentID := c.Param("student_id")
@@ -145,5 +332,9 @@ func (h *ExamResultHandler) GetByStudent(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, results)
+	resp := make([]ExamResultResponse, 0, len(results))
+	for _, r := range results {
+		resp = append(resp, newExamResultResponse(r))
+	}
+	respondWithETag(c, resp)
 }
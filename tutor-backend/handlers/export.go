@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// 导出格式
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// utf8BOM 写在 CSV 开头，保证 Excel 正确识别中文
+const utf8BOM = "\xEF\xBB\xBF"
+
+// tableExport 以表格形式导出的数据
+type tableExport struct {
+	Filename string // 不含扩展名
+	Sheet    string // XLSX 工作表名
+	Headers  []string
+	Rows     [][]interface{}
+}
+
+// parseExportFormat 解析 format 参数，默认 json；不支持的格式已写出 400 响应
+func parseExportFormat(c *gin.Context) (string, bool) {
+	switch format := c.DefaultQuery("format", FormatJSON); format {
+	case FormatJSON, FormatCSV, FormatXLSX:
+		return format, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return "", false
+	}
+}
+
+// respondTable 按 CSV 或 XLSX 写出表格附件
+func respondTable(c *gin.Context, format string, table tableExport) {
+	var (
+		data        []byte
+		contentType string
+		err         error
+	)
+	switch format {
+	case FormatCSV:
+		data, err = encodeCSV(table)
+		contentType = "text/csv; charset=utf-8"
+	case FormatXLSX:
+		data, err = encodeXLSX(table)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		err = fmt.Errorf("不支持的导出格式: %s", format)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setAttachmentFilename(c, table.Filename, format)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// setAttachmentFilename 设置下载文件名，中文名通过 filename* 传递
+func setAttachmentFilename(c *gin.Context, name, format string) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="export.%s"; filename*=UTF-8''%s`,
+		format, url.PathEscape(name+"."+format)))
+}
+
+// tableWriter 逐行写出表格，用于数据量较大、不宜整表放在内存中的导出
+// 开始写出后无法再返回 JSON 错误，调用方出错时只能中断连接
+type tableWriter interface {
+	WriteRow(row []interface{}) error
+	Close() error
+}
+
+// newTableWriter 写出响应头与表头，返回 CSV 或 XLSX 的逐行写入器
+func newTableWriter(c *gin.Context, format string, table tableExport) (tableWriter, error) {
+	switch format {
+	case FormatCSV:
+		setAttachmentFilename(c, table.Filename, format)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		if _, err := c.Writer.WriteString(utf8BOM); err != nil {
+			return nil, err
+		}
+		w := &csvTableWriter{c: c, w: csv.NewWriter(c.Writer)}
+		return w, w.w.Write(table.Headers)
+	case FormatXLSX:
+		return newXLSXTableWriter(c, table)
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// csvFlushRows CSV 每写出多少行推送一次
+const csvFlushRows = 500
+
+type csvTableWriter struct {
+	c    *gin.Context
+	w    *csv.Writer
+	rows int
+}
+
+func (t *csvTableWriter) WriteRow(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = csvValue(v)
+	}
+	if err := t.w.Write(record); err != nil {
+		return err
+	}
+	if t.rows++; t.rows%csvFlushRows == 0 {
+		t.w.Flush()
+		t.c.Writer.Flush()
+	}
+	return t.w.Error()
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// xlsxTableWriter 使用流式写入，超出内存阈值的行暂存到临时文件，结束时一次写出
+type xlsxTableWriter struct {
+	c    *gin.Context
+	f    *excelize.File
+	sw   *excelize.StreamWriter
+	name string
+	row  int
+}
+
+func newXLSXTableWriter(c *gin.Context, table tableExport) (tableWriter, error) {
+	f := excelize.NewFile()
+	sheet := table.Sheet
+	if sheet == "" {
+		sheet = "Sheet1"
+	}
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		f.Close()
+		return nil, err
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	header := make([]interface{}, len(table.Headers))
+	for i, h := range table.Headers {
+		header[i] = excelize.Cell{StyleID: bold, Value: h}
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxTableWriter{c: c, f: f, sw: sw, name: table.Filename, row: 1}, nil
+}
+
+func (t *xlsxTableWriter) WriteRow(row []interface{}) error {
+	t.row++
+	cell, _ := excelize.CoordinatesToCellName(1, t.row)
+	values := make([]interface{}, len(row))
+	for i, v := range row {
+		values[i] = xlsxValue(v)
+	}
+	return t.sw.SetRow(cell, values)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	defer t.f.Close()
+	if err := t.sw.Flush(); err != nil {
+		return err
+	}
+	setAttachmentFilename(t.c, t.name, FormatXLSX)
+	t.c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	t.c.Status(http.StatusOK)
+	return t.f.Write(t.c.Writer)
+}
+
+func encodeCSV(table tableExport) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(utf8BOM)
+	w := csv.NewWriter(&buf)
+	if err := w.Write(table.Headers); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(table.Headers))
+	for _, row := range table.Rows {
+		for i, v := range row {
+			record[i] = csvValue(v)
+		}
+		if err := w.Write(record[:len(row)]); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func encodeXLSX(table tableExport) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := table.Sheet
+	if sheet == "" {
+		sheet = "Sheet1"
+	}
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(table.Headers))
+	for i, h := range table.Headers {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return nil, err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	if len(table.Headers) > 0 {
+		last, _ := excelize.CoordinatesToCellName(len(table.Headers), 1)
+		if err := f.SetCellStyle(sheet, "A1", last, bold); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, row := range table.Rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = xlsxValue(v)
+		}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvValue 将单元格值转为文本，空指针输出为空
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case *float64:
+		if val == nil {
+			return ""
+		}
+		return formatAmount(*val)
+	case float64:
+		return formatAmount(val)
+	case string:
+		return escapeFormula(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// escapeFormula 以 = + - @ 等开头的文本前加单引号，避免在表格软件中被当作公式执行
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// xlsxValue 解引用指针，保留数值类型以便在 Excel 中计算，文本同样转义公式前缀
+func xlsxValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *float64:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case string:
+		return escapeFormula(val)
+	default:
+		return val
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCellValueEscapesFormulas(t *testing.T) {
+	amount := -12.5
+	tests := []struct {
+		name     string
+		value    interface{}
+		wantCSV  string
+		wantXLSX interface{}
+	}{
+		{"普通文本", "张三", "张三", "张三"},
+		{"等号开头", "=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"加号开头", "+86 13800000000", "'+86 13800000000", "'+86 13800000000"},
+		{"减号开头", "-1+2", "'-1+2", "'-1+2"},
+		{"@开头", "@SUM(A1)", "'@SUM(A1)", "'@SUM(A1)"},
+		{"制表符开头", "\t=1", "'\t=1", "'\t=1"},
+		{"空文本", "", "", ""},
+		{"负数保持数值", -3.0, "-3", -3.0},
+		{"负数指针保持数值", &amount, "-12.5", -12.5},
+		{"空指针", (*float64)(nil), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csvValue(tt.value); got != tt.wantCSV {
+				t.Errorf("csvValue = %q, want %q", got, tt.wantCSV)
+			}
+			if got := xlsxValue(tt.value); got != tt.wantXLSX {
+				t.Errorf("xlsxValue = %#v, want %#v", got, tt.wantXLSX)
+			}
+		})
+	}
+}
+
+func TestEncodeTableEscapesFormulas(t *testing.T) {
+	table := tableExport{Headers: []string{"姓名", "金额"}, Rows: [][]interface{}{{"=1+1", -5.0}}}
+
+	data, err := encodeCSV(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte(utf8BOM)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := records[1]; got[0] != "'=1+1" || got[1] != "-5" {
+		t.Errorf("csv row = %q", got)
+	}
+
+	data, err = encodeXLSX(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if formula, _ := f.GetCellFormula("Sheet1", "A2"); formula != "" {
+		t.Errorf("A2 formula = %q, want none", formula)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "A2"); got != "'=1+1" {
+		t.Errorf("A2 = %q, want %q", got, "'=1+1")
+	}
+	if got, _ := f.GetCellValue("Sheet1", "B2"); got != "-5" {
+		t.Errorf("B2 = %q, want -5", got)
+	}
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 成绩预警类型
+const (
+	AlertSignificantDrop = "significant_drop" // 最近一次成绩显著低于历史趋势的预测
+	AlertDowntrend       = "downtrend"        // 成绩整体呈显著下降趋势
+	AlertBelowPass       = "below_pass"       // 按趋势预测下次成绩低于及格线
+)
+
+// tCritical95 双侧 95% 置信水平下 t 分布的临界值，下标为自由度；自由度超过 30 时取 1.96
+var tCritical95 = []float64{0,
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// ScoreProjection 下次考试的预测得分率及 95% 预测区间
+type ScoreProjection struct {
+	Percentage float64 `json:"percentage"`
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+}
+
+// ScoreAlert 成绩预警
+type ScoreAlert struct {
+	Type         string    `json:"type"` // significant_drop, downtrend, below_pass
+	StudentID    uint      `json:"student_id"`
+	StudentName  string    `json:"student_name"`
+	CourseID     uint      `json:"course_id"`
+	CourseName   string    `json:"course_name"`
+	ExamResultID uint      `json:"exam_result_id"` // 触发预警的最近一次考试
+	ExamDate     time.Time `json:"exam_date"`
+	Percentage   float64   `json:"percentage"` // 最近一次得分率
+	Expected     *float64  `json:"expected"`   // 按趋势预测的得分率
+	Message      string    `json:"message"`
+}
+
+// CourseForecast 单科成绩趋势与预测
+// 趋势按考试先后次序（而非日期）做线性回归，斜率单位为每次考试变化的百分点
+type CourseForecast struct {
+	CourseID         uint             `json:"course_id"`
+	CourseName       string           `json:"course_name"`
+	Count            int              `json:"count"`
+	Slope            *float64         `json:"slope"`             // 至少两次考试才有
+	SlopeSignificant bool             `json:"slope_significant"` // 斜率在 95% 水平下显著不为 0
+	MovingAverage    *float64         `json:"moving_average"`    // 最近几次考试的平均得分率
+	Next             *ScoreProjection `json:"next"`              // 至少三次考试才有
+	Alerts           []ScoreAlert     `json:"alerts"`
+}
+
+// linearFit 最小二乘拟合 y = intercept + slope*x，x 为 0..n-1
+type linearFit struct {
+	n         int
+	slope     float64
+	intercept float64
+	xMean     float64
+	sxx       float64
+	residual  float64 // 残差标准误，n <= 2 时为 0
+}
+
+func fitLinear(ys []float64) linearFit {
+	n := len(ys)
+	fit := linearFit{n: n}
+	if n == 0 {
+		return fit
+	}
+	fit.xMean = float64(n-1) / 2
+	yMean := 0.0
+	for _, y := range ys {
+		yMean += y
+	}
+	yMean /= float64(n)
+
+	sxy := 0.0
+	for i, y := range ys {
+		dx := float64(i) - fit.xMean
+		fit.sxx += dx * dx
+		sxy += dx * (y - yMean)
+	}
+	if fit.sxx > 0 {
+		fit.slope = sxy / fit.sxx
+	}
+	fit.intercept = yMean - fit.slope*fit.xMean
+
+	if n > 2 {
+		sse := 0.0
+		for i, y := range ys {
+			e := y - fit.predict(float64(i))
+			sse += e * e
+		}
+		fit.residual = math.Sqrt(sse / float64(n-2))
+	}
+	return fit
+}
+
+func (f linearFit) predict(x float64) float64 {
+	return f.intercept + f.slope*x
+}
+
+// interval 返回 x 处单次新观测的 95% 预测区间半宽，需要至少三个点
+func (f linearFit) interval(x float64) float64 {
+	return tCritical(f.n-2) * f.residual * math.Sqrt(1+1/float64(f.n)+(x-f.xMean)*(x-f.xMean)/f.sxx)
+}
+
+// slopeSignificant 斜率的 t 检验在 95% 水平下是否显著
+func (f linearFit) slopeSignificant() bool {
+	if f.n <= 2 || f.sxx == 0 {
+		return false
+	}
+	se := f.residual / math.Sqrt(f.sxx)
+	if se == 0 {
+		return f.slope != 0
+	}
+	return math.Abs(f.slope/se) > tCritical(f.n-2)
+}
+
+func tCritical(df int) float64 {
+	if df <= 0 {
+		return math.Inf(1)
+	}
+	if df < len(tCritical95) {
+		return tCritical95[df]
+	}
+	return 1.96
+}
+
+// clampPercentage 将得分率限制在 0-100 并保留两位小数
+func clampPercentage(v float64) float64 {
+	return math.Round(math.Max(0, math.Min(100, v))*100) / 100
+}
+
+// GetForecast 学生成绩趋势预测
+// @Summary 成绩趋势预测
+// @Description 按科目对历次考试得分率做线性回归与移动平均，预测下次考试得分率及 95% 预测区间，并给出显著下滑、持续下降、预计不及格等预警
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Produce json
+// @Param student_id path int true "学生ID"
+// @Param course_id query int false "科目ID"
+// @Success 200 {array} CourseForecast
+// @Failure 404 {object} map[string]string
+// @Router /analysis/{student_id}/forecast [get]
+func (h *AnalysisHandler) GetForecast(c *gin.Context) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("student_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+	query := h.DB.Preload("Course").Where("student_id = ?", student.ID)
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	var results []models.ExamResult
+	if err := query.Order("exam_date ASC, id ASC").Find(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	forecasts := []CourseForecast{}
+	for _, group := range groupByCourse(results) {
+		forecasts = append(forecasts, forecastCourse(student, group, h.Config))
+	}
+	respondWithETag(c, forecasts)
+}
+
+// GetAlerts 成绩预警看板
+// @Summary 成绩预警（看板）
+// @Description 检查所有学生最近有考试的科目，返回显著下滑、持续下降、预计不及格等预警，按考试日期倒序
+// @Tags App接口
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} ScoreAlert
+// @Router /dashboard/score-alerts [get]
+func (h *AnalysisHandler) GetAlerts(c *gin.Context) {
+	alerts, err := h.scoreAlerts(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondWithETag(c, alerts)
+}
+
+// scoreAlerts 汇总所有学生的成绩预警，最近一次考试早于回看天数的科目不再预警
+func (h *AnalysisHandler) scoreAlerts(now time.Time) ([]ScoreAlert, error) {
+	var results []models.ExamResult
+	if err := h.DB.Preload("Student").Preload("Course").Order("exam_date ASC, id ASC").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	byStudent := map[uint][]models.ExamResult{}
+	var studentIDs []uint
+	for _, r := range results {
+		if _, ok := byStudent[r.StudentID]; !ok {
+			studentIDs = append(studentIDs, r.StudentID)
+		}
+		byStudent[r.StudentID] = append(byStudent[r.StudentID], r)
+	}
+
+	cutoff := now.AddDate(0, 0, -h.Config.AlertLookbackDays)
+	alerts := []ScoreAlert{}
+	for _, id := range studentIDs {
+		var student models.Student
+		if s := byStudent[id][0].Student; s != nil {
+			student = *s
+		}
+		for _, group := range groupByCourse(byStudent[id]) {
+			if h.Config.AlertLookbackDays > 0 && group[len(group)-1].ExamDate.Before(cutoff) {
+				continue
+			}
+			alerts = append(alerts, forecastCourse(student, group, h.Config).Alerts...)
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].ExamDate.After(alerts[j].ExamDate)
+	})
+	return alerts, nil
+}
+
+// groupByCourse 按科目分组，保持组内原有的时间顺序，组按首次出现的先后排列
+func groupByCourse(results []models.ExamResult) [][]models.ExamResult {
+	index := map[uint]int{}
+	var groups [][]models.ExamResult
+	for _, r := range results {
+		i, ok := index[r.CourseID]
+		if !ok {
+			i = len(groups)
+			index[r.CourseID] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	return groups
+}
+
+// forecastCourse 计算单科趋势、预测与预警，results 为同一学生同一科目、按考试日期升序的成绩
+func forecastCourse(student models.Student, results []models.ExamResult, cfg config.AnalysisConfig) CourseForecast {
+	latest := results[len(results)-1]
+	forecast := CourseForecast{
+		CourseID: latest.CourseID,
+		Count:    len(results),
+		Alerts:   []ScoreAlert{},
+	}
+	if latest.Course != nil {
+		forecast.CourseName = latest.Course.Name
+	}
+
+	values := percentageValues(results)
+	window := cfg.MovingAverageWindow
+	if window <= 0 || window > len(values) {
+		window = len(values)
+	}
+	avg := 0.0
+	for _, v := range values[len(values)-window:] {
+		avg += v
+	}
+	avg = math.Round(avg/float64(window)*100) / 100
+	forecast.MovingAverage = &avg
+
+	newAlert := func(alertType string, expected *float64, message string) ScoreAlert {
+		return ScoreAlert{
+			Type:         alertType,
+			StudentID:    student.ID,
+			StudentName:  student.Name,
+			CourseID:     forecast.CourseID,
+			CourseName:   forecast.CourseName,
+			ExamResultID: latest.ID,
+			ExamDate:     latest.ExamDate,
+			Percentage:   values[len(values)-1],
+			Expected:     expected,
+			Message:      message,
+		}
+	}
+
+	// 最近一次与此前趋势比较：需此前至少三次考试才能估计波动范围
+	if len(values) >= 4 {
+		history := fitLinear(values[:len(values)-1])
+		x := float64(len(values) - 1)
+		expected := clampPercentage(history.predict(x))
+		lower := history.predict(x) - history.interval(x)
+		actual := values[len(values)-1]
+		if actual < lower && expected-actual >= cfg.MinDropPoints {
+			forecast.Alerts = append(forecast.Alerts, newAlert(AlertSignificantDrop, &expected,
+				fmt.Sprintf("%s 得分率 %.1f%%，显著低于按此前趋势预测的 %.1f%%", latest.ExamName, actual, expected)))
+		}
+	}
+
+	if len(values) < 2 {
+		return forecast
+	}
+	fit := fitLinear(values)
+	slope := math.Round(fit.slope*100) / 100
+	forecast.Slope = &slope
+	forecast.SlopeSignificant = fit.slopeSignificant()
+	if forecast.SlopeSignificant && fit.slope < 0 {
+		forecast.Alerts = append(forecast.Alerts, newAlert(AlertDowntrend, nil,
+			fmt.Sprintf("近 %d 次考试得分率持续下降，平均每次下降 %.1f 个百分点", len(values), -fit.slope)))
+	}
+
+	if len(values) < 3 {
+		return forecast
+	}
+	x := float64(len(values))
+	half := fit.interval(x)
+	next := ScoreProjection{
+		Percentage: clampPercentage(fit.predict(x)),
+		Lower:      clampPercentage(fit.predict(x) - half),
+		Upper:      clampPercentage(fit.predict(x) + half),
+	}
+	forecast.Next = &next
+	if cfg.PassPercentage > 0 && next.Percentage < cfg.PassPercentage {
+		expected := next.Percentage
+		forecast.Alerts = append(forecast.Alerts, newAlert(AlertBelowPass, &expected,
+			fmt.Sprintf("按趋势预测下次考试得分率 %.1f%%，低于及格线 %.0f%%", next.Percentage, cfg.PassPercentage)))
+	}
+	return forecast
+}
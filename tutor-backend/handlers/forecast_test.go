@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitLinear(t *testing.T) {
+	tests := []struct {
+		name          string
+		ys            []float64
+		wantSlope     float64
+		wantIntercept float64
+		wantResidual  float64
+		wantNext      float64
+	}{
+		{"无数据", nil, 0, 0, 0, 0},
+		{"单点", []float64{80}, 0, 80, 0, 80},
+		{"两点", []float64{60, 70}, 10, 60, 0, 80},
+		{"完全线性", []float64{50, 60, 70, 80}, 10, 50, 0, 90},
+		{"水平", []float64{75, 75, 75}, 0, 75, 0, 75},
+		{"有残差", []float64{60, 80, 70}, 5, 65, math.Sqrt(150), 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := fitLinear(tt.ys)
+			next := fit.predict(float64(len(tt.ys)))
+			if !approxEqual(fit.slope, tt.wantSlope) || !approxEqual(fit.intercept, tt.wantIntercept) ||
+				!approxEqual(fit.residual, tt.wantResidual) || !approxEqual(next, tt.wantNext) {
+				t.Errorf("fitLinear(%v) = slope %v intercept %v residual %v next %v, want %v %v %v %v",
+					tt.ys, fit.slope, fit.intercept, fit.residual, next,
+					tt.wantSlope, tt.wantIntercept, tt.wantResidual, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestClampPercentage(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{-5, 0},
+		{0, 0},
+		{66.666, 66.67},
+		{100, 100},
+		{120.5, 100},
+	}
+	for _, tt := range tests {
+		if got := clampPercentage(tt.in); got != tt.want {
+			t.Errorf("clampPercentage(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
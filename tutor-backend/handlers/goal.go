@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 目标状态
+const (
+	GoalAchieved = "achieved" // 截止前已有考试达到目标
+	GoalMissed   = "missed"   // 已过截止日期仍未达到
+	GoalOnTrack  = "on_track" // 按历次得分率趋势预计下次考试可达到
+	GoalAtRisk   = "at_risk"  // 按趋势预计下次考试仍达不到
+	GoalNoData   = "no_data"  // 尚无可统计的考试
+)
+
+type GoalHandler struct {
+	DB         *gorm.DB
+	ChartCache *ChartCache // 目标会绘制在趋势图上，变更时清除相关学生的图表缓存
+}
+
+func NewGoalHandler(db *gorm.DB, chartCache *ChartCache) *GoalHandler {
+	return &GoalHandler{DB: db, ChartCache: chartCache}
+}
+
+// CreateGoalRequest 创建学习目标请求
+type CreateGoalRequest struct {
+	StudentID   uint      `json:"student_id" binding:"required"`
+	CourseID    uint      `json:"course_id" binding:"required"`
+	TargetScore float64   `json:"target_score" binding:"required,gt=0,ltefield=FullScore"`
+	FullScore   float64   `json:"full_score" binding:"required,gt=0"`
+	ExamType    string    `json:"exam_type" binding:"omitempty,oneof=midterm final quiz"` // 为空表示统计全部考试
+	Deadline    time.Time `json:"deadline" binding:"required"`
+	Description string    `json:"description" binding:"max=500"`
+}
+
+// UpdateGoalRequest 更新学习目标请求，未提供的字段保持不变
+type UpdateGoalRequest struct {
+	TargetScore *float64   `json:"target_score" binding:"omitempty,gt=0"`
+	FullScore   *float64   `json:"full_score" binding:"omitempty,gt=0"`
+	ExamType    *string    `json:"exam_type" binding:"omitempty,oneof='' midterm final quiz"`
+	Deadline    *time.Time `json:"deadline"`
+	Description *string    `json:"description" binding:"omitempty,max=500"`
+}
+
+// GoalProgress 目标完成情况，分数均为得分率（%）
+type GoalProgress struct {
+	GoalID               uint       `json:"goal_id"`
+	CourseID             uint       `json:"course_id"`
+	CourseName           string     `json:"course_name"`
+	ExamType             string     `json:"exam_type"`
+	TargetScore          float64    `json:"target_score"`
+	FullScore            float64    `json:"full_score"`
+	TargetPercentage     float64    `json:"target_percentage"`
+	Deadline             time.Time  `json:"deadline"`
+	DaysLeft             int        `json:"days_left"` // 距截止日期的天数，已过期为负数
+	Status               string     `json:"status"`    // achieved, missed, on_track, at_risk, no_data
+	ExamCount            int        `json:"exam_count"`
+	Latest               *float64   `json:"latest"`    // 截止前最近一次考试的得分率
+	Best                 *float64   `json:"best"`      // 截止前最好的得分率
+	Gap                  *float64   `json:"gap"`       // 目标与最近一次的差距（百分点），已超过目标时为负数
+	Projected            *float64   `json:"projected"` // 按历次得分率线性趋势预测的下次考试得分率
+	AchievedAt           *time.Time `json:"achieved_at"`
+	AchievedExamResultID *uint      `json:"achieved_exam_result_id"`
+}
+
+// GoalResponse 学习目标信息
+type GoalResponse struct {
+	ID          uint             `json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Version     uint             `json:"version"`
+	StudentID   uint             `json:"student_id"`
+	Student     *StudentResponse `json:"student,omitempty"`
+	CourseID    uint             `json:"course_id"`
+	Course      *CourseResponse  `json:"course,omitempty"`
+	TargetScore float64          `json:"target_score"`
+	FullScore   float64          `json:"full_score"`
+	ExamType    string           `json:"exam_type"`
+	Deadline    time.Time        `json:"deadline"`
+	Description string           `json:"description"`
+	Progress    GoalProgress     `json:"progress"`
+}
+
+func (r CreateGoalRequest) toModel() models.Goal {
+	return models.Goal{
+		Version:     1,
+		StudentID:   r.StudentID,
+		CourseID:    r.CourseID,
+		TargetScore: r.TargetScore,
+		FullScore:   r.FullScore,
+		ExamType:    r.ExamType,
+		Deadline:    r.Deadline,
+		Description: r.Description,
+	}
+}
+
+func (r UpdateGoalRequest) apply(goal *models.Goal) {
+	if r.TargetScore != nil {
+		goal.TargetScore = *r.TargetScore
+	}
+	if r.FullScore != nil {
+		goal.FullScore = *r.FullScore
+	}
+	if r.ExamType != nil {
+		goal.ExamType = *r.ExamType
+	}
+	if r.Deadline != nil {
+		goal.Deadline = *r.Deadline
+	}
+	if r.Description != nil {
+		goal.Description = *r.Description
+	}
+}
+
+func newGoalResponse(goal models.Goal, progress GoalProgress) GoalResponse {
+	resp := GoalResponse{
+		ID:          goal.ID,
+		CreatedAt:   goal.CreatedAt,
+		UpdatedAt:   goal.UpdatedAt,
+		Version:     goal.Version,
+		StudentID:   goal.StudentID,
+		CourseID:    goal.CourseID,
+		TargetScore: goal.TargetScore,
+		FullScore:   goal.FullScore,
+		ExamType:    goal.ExamType,
+		Deadline:    goal.Deadline,
+		Description: goal.Description,
+		Progress:    progress,
+	}
+	if goal.Student != nil {
+		student := newStudentResponse(*goal.Student)
+		resp.Student = &student
+	}
+	if goal.Course != nil {
+		course := newCourseResponse(*goal.Course)
+		resp.Course = &course
+	}
+	return resp
+}
+
+// goalListSpec 学习目标列表允许的排序与筛选字段
+var goalListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":           "id",
+		"deadline":     "deadline",
+		"target_score": "target_score",
+		"created_at":   "created_at",
+	},
+	DefaultSort: "deadline ASC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"course_id":  "course_id",
+		"exam_type":  "exam_type",
+	},
+	DateRange: "deadline",
+	Preloads:  []string{"Student", "Course"},
+}
+
+// GetAll 获取学习目标列表
+// @Summary 获取学习目标列表
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组；每个目标附带完成情况
+// @Tags 学习目标
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param exam_type query string false "考试类型"
+// @Param start_date query string false "截止开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "截止结束日期 (yyyy-MM-dd)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, deadline, target_score, created_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Success 200 {array} GoalResponse
+// @Failure 400 {object} map[string]string
+// @Router /goals [get]
+func (h *GoalHandler) GetAll(c *gin.Context) {
+	now := time.Now()
+	respondListBatch(c, h.DB, goalListSpec, func(goals []models.Goal) ([]GoalResponse, error) {
+		progress, err := goalsProgress(h.DB, goals, now)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]GoalResponse, len(goals))
+		for i, goal := range goals {
+			data[i] = newGoalResponse(goal, progress[i])
+		}
+		return data, nil
+	})
+}
+
+// Create 创建学习目标
+// @Summary 创建学习目标
+// @Description 如“期末数学 110/150”：target_score=110，full_score=150，exam_type=final
+// @Tags 学习目标
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param goal body CreateGoalRequest true "目标信息"
+// @Success 201 {object} GoalResponse
+// @Failure 400 {object} map[string]string
+// @Router /goals [post]
+func (h *GoalHandler) Create(c *gin.Context) {
+	var req CreateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	if err := h.DB.First(&models.Student{}, req.StudentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("student_id", "学生不存在"))
+		return
+	}
+	if err := h.DB.First(&models.Course{}, req.CourseID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("course_id", "科目不存在"))
+		return
+	}
+	goal := req.toModel()
+	if err := h.DB.Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.ChartCache.InvalidateStudent(goal.StudentID)
+	h.respondGoal(c, http.StatusCreated, goal.ID)
+}
+
+// Get 获取学习目标详情
+// @Summary 获取学习目标详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 学习目标
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "目标ID"
+// @Success 200 {object} GoalResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /goals/{id} [get]
+func (h *GoalHandler) Get(c *gin.Context) {
+	var goal models.Goal
+	if err := h.DB.First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目标不存在"})
+		return
+	}
+	h.respondGoal(c, http.StatusOK, goal.ID)
+}
+
+// Update 更新学习目标
+// @Summary 更新学习目标
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；学生与科目不可修改。需携带 If-Match，版本不一致返回 412
+// @Tags 学习目标
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "目标ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param goal body UpdateGoalRequest true "目标信息"
+// @Success 200 {object} GoalResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /goals/{id} [put]
+// @Router /goals/{id} [patch]
+func (h *GoalHandler) Update(c *gin.Context) {
+	var goal models.Goal
+	if err := h.DB.First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目标不存在"})
+		return
+	}
+	if !checkIfMatch(c, goal.Version) {
+		return
+	}
+	var req UpdateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	req.apply(&goal)
+	if goal.TargetScore > goal.FullScore {
+		c.JSON(http.StatusBadRequest, fieldError("target_score", "不能超过满分"))
+		return
+	}
+	if err := saveVersioned(h.DB, &goal, &goal.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.ChartCache.InvalidateStudent(goal.StudentID)
+	h.respondGoal(c, http.StatusOK, goal.ID)
+}
+
+// Delete 删除学习目标
+// @Summary 删除学习目标
+// @Tags 学习目标
+// @Security BearerAuth
+// @Param id path int true "目标ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /goals/{id} [delete]
+func (h *GoalHandler) Delete(c *gin.Context) {
+	var goal models.Goal
+	if err := h.DB.First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目标不存在"})
+		return
+	}
+	if !checkIfMatch(c, goal.Version) {
+		return
+	}
+	if err := deleteVersioned(h.DB, &goal, goal.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.ChartCache.InvalidateStudent(goal.StudentID)
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// respondGoal 重新读取目标及其完成情况并输出
+func (h *GoalHandler) respondGoal(c *gin.Context, status int, id uint) {
+	var goal models.Goal
+	if err := h.DB.Preload("Student").Preload("Course").First(&goal, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目标不存在"})
+		return
+	}
+	progress, err := goalsProgress(h.DB, []models.Goal{goal}, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondVersioned(c, status, goal.Version, newGoalResponse(goal, progress[0]))
+}
+
+// goalsProgress 计算一组目标的完成情况，涉及的成绩一次读出后按学生分组
+func goalsProgress(db *gorm.DB, goals []models.Goal, now time.Time) ([]GoalProgress, error) {
+	progress := make([]GoalProgress, len(goals))
+	if len(goals) == 0 {
+		return progress, nil
+	}
+	studentIDs, courseIDs := map[uint]bool{}, map[uint]bool{}
+	for _, g := range goals {
+		studentIDs[g.StudentID] = true
+		courseIDs[g.CourseID] = true
+	}
+	var results []models.ExamResult
+	err := db.Where("student_id IN ? AND course_id IN ?", mapKeys(studentIDs), mapKeys(courseIDs)).
+		Order("exam_date ASC, id ASC").
+		Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	byStudent := make(map[uint][]models.ExamResult)
+	for _, r := range results {
+		byStudent[r.StudentID] = append(byStudent[r.StudentID], r)
+	}
+	for i, g := range goals {
+		progress[i] = buildGoalProgress(g, byStudent[g.StudentID], now)
+	}
+	return progress, nil
+}
+
+// studentGoals 读取学生的全部目标（可按科目、考试类型筛选），按截止日期升序
+// 按考试类型筛选时保留不限考试类型的目标，它们同样统计该类型的考试；科目已删除的目标不返回
+func studentGoals(db *gorm.DB, studentID uint, courseID, examType string) ([]models.Goal, error) {
+	query := db.InnerJoins("Course").Where("goals.student_id = ?", studentID)
+	if courseID != "" {
+		query = query.Where("goals.course_id = ?", courseID)
+	}
+	if examType != "" {
+		query = query.Where("goals.exam_type IN ?", []string{examType, ""})
+	}
+	var goals []models.Goal
+	err := query.Order("goals.deadline ASC, goals.id ASC").Find(&goals).Error
+	return goals, err
+}
+
+// studentGoalProgress 学生全部目标（可按科目、考试类型筛选）的完成情况，成绩一次读出
+func studentGoalProgress(db *gorm.DB, studentID uint, courseID, examType string, now time.Time) ([]GoalProgress, error) {
+	goals, err := studentGoals(db, studentID, courseID, examType)
+	if err != nil {
+		return nil, err
+	}
+	return goalsProgress(db, goals, now)
+}
+
+// buildGoalProgress 计算目标完成情况，results 为该学生的成绩，需按考试日期升序
+// 只统计截止日期（含当天）之前、且与目标考试类型一致的成绩
+func buildGoalProgress(goal models.Goal, results []models.ExamResult, now time.Time) GoalProgress {
+	progress := GoalProgress{
+		GoalID:           goal.ID,
+		CourseID:         goal.CourseID,
+		ExamType:         goal.ExamType,
+		TargetScore:      goal.TargetScore,
+		FullScore:        goal.FullScore,
+		TargetPercentage: goalPercentage(goal),
+		Deadline:         goal.Deadline,
+		DaysLeft:         goalDaysLeft(goal.Deadline, now),
+	}
+	if goal.Course != nil {
+		progress.CourseName = goal.Course.Name
+	}
+
+	deadlineEnd := goalDeadlineEnd(goal.Deadline)
+	var values []float64
+	for _, r := range results {
+		if r.CourseID != goal.CourseID || !r.ExamDate.Before(deadlineEnd) {
+			continue
+		}
+		if goal.ExamType != "" && r.ExamType != goal.ExamType {
+			continue
+		}
+		pct := scorePercentage(r)
+		values = append(values, pct)
+		if progress.AchievedAt == nil && pct >= progress.TargetPercentage {
+			achievedAt, id := r.ExamDate, r.ID
+			progress.AchievedAt = &achievedAt
+			progress.AchievedExamResultID = &id
+		}
+	}
+
+	progress.ExamCount = len(values)
+	if len(values) > 0 {
+		stats := scoreStats(values)
+		progress.Latest = floatPtr(stats.Latest)
+		progress.Best = floatPtr(stats.Best)
+		progress.Gap = floatPtr(math.Round((progress.TargetPercentage-stats.Latest)*100) / 100)
+		fit := fitLinear(values)
+		progress.Projected = floatPtr(clampPercentage(fit.predict(float64(len(values)))))
+	}
+
+	switch {
+	case progress.AchievedAt != nil:
+		progress.Status = GoalAchieved
+	case !now.Before(deadlineEnd):
+		progress.Status = GoalMissed
+	case len(values) == 0:
+		progress.Status = GoalNoData
+	case *progress.Projected >= progress.TargetPercentage:
+		progress.Status = GoalOnTrack
+	default:
+		progress.Status = GoalAtRisk
+	}
+	return progress
+}
+
+// goalPercentage 目标得分率（%），保留两位小数
+func goalPercentage(goal models.Goal) float64 {
+	return scorePercentage(models.ExamResult{Score: goal.TargetScore, FullScore: goal.FullScore})
+}
+
+// goalDeadlineEnd 截止日期当天结束的时刻，当天的考试也计入
+func goalDeadlineEnd(deadline time.Time) time.Time {
+	d := deadline.In(time.Local)
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+}
+
+func goalDaysLeft(deadline, now time.Time) int {
+	d := deadline.In(time.Local)
+	n := now.In(time.Local)
+	day := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, time.UTC)
+	return int(day.Sub(today).Hours() / 24)
+}
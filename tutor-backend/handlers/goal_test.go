@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestBuildGoalProgress(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.Local) }
+	result := func(id uint, date time.Time, examType string, score float64) models.ExamResult {
+		return models.ExamResult{ID: id, CourseID: 1, ExamType: examType, Score: score, FullScore: 150, ExamDate: date}
+	}
+	goal := models.Goal{ID: 1, CourseID: 1, TargetScore: 120, FullScore: 150, Deadline: day(20)}
+	finalGoal := goal
+	finalGoal.ExamType = "final"
+
+	tests := []struct {
+		name         string
+		goal         models.Goal
+		results      []models.ExamResult
+		now          time.Time
+		wantStatus   string
+		wantCount    int
+		wantAchieved *uint
+		wantDaysLeft int
+	}{
+		{"尚无成绩", goal, nil, day(1), GoalNoData, 0, nil, 19},
+		{"已达到目标", goal, []models.ExamResult{result(1, day(2), "quiz", 105), result(2, day(5), "quiz", 120)}, day(6), GoalAchieved, 2, uintPtr(2), 14},
+		{"截止当天的考试也计入", goal, []models.ExamResult{result(1, day(20).Add(15*time.Hour), "quiz", 130)}, day(21), GoalAchieved, 1, uintPtr(1), -1},
+		{"截止后的考试不计入", goal, []models.ExamResult{result(1, day(21), "quiz", 130)}, day(22), GoalMissed, 0, nil, -2},
+		{"已过截止日期未达到", goal, []models.ExamResult{result(1, day(2), "quiz", 90)}, day(21), GoalMissed, 1, nil, -1},
+		{"趋势向上预计达到", goal, []models.ExamResult{result(1, day(1), "quiz", 90), result(2, day(5), "quiz", 105), result(3, day(9), "quiz", 118)}, day(10), GoalOnTrack, 3, nil, 10},
+		{"趋势向下预计达不到", goal, []models.ExamResult{result(1, day(1), "quiz", 115), result(2, day(5), "quiz", 100)}, day(10), GoalAtRisk, 2, nil, 10},
+		{"只统计目标考试类型", finalGoal, []models.ExamResult{result(1, day(2), "quiz", 140), result(2, day(5), "final", 100)}, day(6), GoalAtRisk, 1, nil, 14},
+		{"忽略其他科目", goal, []models.ExamResult{{ID: 1, CourseID: 2, Score: 150, FullScore: 150, ExamDate: day(2)}}, day(6), GoalNoData, 0, nil, 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildGoalProgress(tt.goal, tt.results, tt.now)
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			if got.ExamCount != tt.wantCount {
+				t.Errorf("exam_count = %d, want %d", got.ExamCount, tt.wantCount)
+			}
+			if (got.AchievedExamResultID == nil) != (tt.wantAchieved == nil) ||
+				(got.AchievedExamResultID != nil && *got.AchievedExamResultID != *tt.wantAchieved) {
+				t.Errorf("achieved_exam_result_id = %v, want %v", got.AchievedExamResultID, tt.wantAchieved)
+			}
+			if got.DaysLeft != tt.wantDaysLeft {
+				t.Errorf("days_left = %d, want %d", got.DaysLeft, tt.wantDaysLeft)
+			}
+			if got.TargetPercentage != 80 {
+				t.Errorf("target_percentage = %v, want 80", got.TargetPercentage)
+			}
+		})
+	}
+}
+
+func TestGoalsProgressGroupsByStudent(t *testing.T) {
+	db := newTestDB(t, &models.ExamResult{})
+	deadline := time.Now().AddDate(0, 1, 0)
+	for _, r := range []models.ExamResult{
+		{StudentID: 1, CourseID: 1, ExamType: "quiz", Score: 95, FullScore: 100, ExamDate: time.Now().AddDate(0, 0, -3)},
+		{StudentID: 2, CourseID: 1, ExamType: "quiz", Score: 60, FullScore: 100, ExamDate: time.Now().AddDate(0, 0, -2)},
+	} {
+		if err := db.Omit("Student", "Course").Create(&r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	goals := []models.Goal{
+		{ID: 1, StudentID: 1, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: deadline},
+		{ID: 2, StudentID: 2, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: deadline},
+		{ID: 3, StudentID: 3, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: deadline},
+	}
+	progress, err := goalsProgress(db, goals, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{GoalAchieved, GoalAtRisk, GoalNoData}
+	for i, p := range progress {
+		if p.GoalID != goals[i].ID || p.Status != want[i] {
+			t.Errorf("progress[%d] = goal %d %q, want goal %d %q", i, p.GoalID, p.Status, goals[i].ID, want[i])
+		}
+	}
+}
+
+func TestGoalGetAllProgressError(t *testing.T) {
+	db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Goal{})
+	db.Create(&models.Goal{StudentID: 1, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: time.Now()})
+
+	r := gin.New()
+	r.GET("/goals", NewGoalHandler(db, nil).GetAll)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/goals", nil))
+	// 成绩表不存在，完成情况无法计算时不能返回缺少进度的目标
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("GET /goals = %d, want 500: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStudentGoalsFilter(t *testing.T) {
+	db := newTestDB(t, &models.Course{}, &models.Goal{})
+	db.Create(&[]models.Course{{Name: "数学"}, {Name: "英语"}})
+	deadline := time.Now().AddDate(0, 1, 0)
+	for _, g := range []models.Goal{
+		{StudentID: 1, CourseID: 1, ExamType: "final", TargetScore: 90, FullScore: 100, Deadline: deadline},
+		{StudentID: 1, CourseID: 1, ExamType: "quiz", TargetScore: 90, FullScore: 100, Deadline: deadline},
+		{StudentID: 1, CourseID: 2, TargetScore: 90, FullScore: 100, Deadline: deadline},
+		{StudentID: 1, CourseID: 3, TargetScore: 90, FullScore: 100, Deadline: deadline}, // 科目已删除
+		{StudentID: 2, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: deadline},
+	} {
+		if err := db.Omit("Student", "Course").Create(&g).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		courseID string
+		examType string
+		want     []uint
+	}{
+		{"全部目标", "", "", []uint{1, 2, 3}},
+		{"按科目筛选", "1", "", []uint{1, 2}},
+		{"按考试类型筛选保留不限类型的目标", "", "final", []uint{1, 3}},
+		{"科目与考试类型同时筛选", "2", "quiz", []uint{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goals, err := studentGoals(db, 1, tt.courseID, tt.examType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, g := range goals {
+				got = append(got, g.ID)
+				if g.Course == nil {
+					t.Errorf("goal %d: course not loaded", g.ID)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("goals = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteRemovesGoals(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		handle func(db *gorm.DB) gin.HandlerFunc
+		want   []uint
+	}{
+		{"删除学生", "/students/:id", func(db *gorm.DB) gin.HandlerFunc { return NewStudentHandler(db, nil).Delete }, []uint{3}},
+		{"删除科目", "/courses/:id", func(db *gorm.DB) gin.HandlerFunc { return NewCourseHandler(db, nil).Delete }, []uint{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Goal{}, &models.Attachment{})
+			db.Create(&[]models.Student{{Name: "张三"}, {Name: "李四"}})
+			db.Create(&[]models.Course{{Name: "数学"}, {Name: "英语"}})
+			deadline := time.Now().AddDate(0, 1, 0)
+			db.Create(&[]models.Goal{
+				{StudentID: 1, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: deadline},
+				{StudentID: 1, CourseID: 2, TargetScore: 90, FullScore: 100, Deadline: deadline},
+				{StudentID: 2, CourseID: 1, TargetScore: 90, FullScore: 100, Deadline: deadline},
+			})
+
+			r := gin.New()
+			r.DELETE(tt.path, tt.handle(db))
+			req := httptest.NewRequest(http.MethodDelete, strings.Replace(tt.path, ":id", "1", 1), nil)
+			req.Header.Set("If-Match", versionETag(1))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("DELETE = %d: %s", w.Code, w.Body.String())
+			}
+			var ids []uint
+			db.Model(&models.Goal{}).Order("id").Pluck("id", &ids)
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("remaining goals = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 作业状态
+const (
+	HomeworkAssigned  = "assigned"
+	HomeworkSubmitted = "submitted"
+	HomeworkChecked   = "checked"
+)
+
+// homeworkTransitions 允许的作业状态流转
+// 已提交的作业可退回重做，已批改的作业可重新批改
+var homeworkTransitions = map[string][]string{
+	HomeworkAssigned:  {HomeworkSubmitted, HomeworkChecked},
+	HomeworkSubmitted: {HomeworkAssigned, HomeworkChecked},
+	HomeworkChecked:   {HomeworkSubmitted},
+}
+
+type HomeworkHandler struct {
+	DB *gorm.DB
+}
+
+func NewHomeworkHandler(db *gorm.DB) *HomeworkHandler {
+	return &HomeworkHandler{DB: db}
+}
+
+// CreateHomeworkRequest 布置作业请求
+type CreateHomeworkRequest struct {
+	StudentID   uint       `json:"student_id" binding:"required"`
+	CourseID    uint       `json:"course_id" binding:"required"`
+	ScheduleID  *uint      `json:"schedule_id" binding:"omitempty,gt=0"` // 布置作业的课程
+	Title       string     `json:"title" binding:"required,notblank,max=100"`
+	Description string     `json:"description" binding:"max=2000"`
+	AssignedAt  *time.Time `json:"assigned_at"` // 默认当前时间
+	DueDate     time.Time  `json:"due_date" binding:"required"`
+}
+
+// UpdateHomeworkRequest 更新作业请求，未提供的字段保持不变；状态请通过 transition 接口变更
+// 得分与评语只能在作业已批改后修改
+type UpdateHomeworkRequest struct {
+	Title       *string    `json:"title" binding:"omitempty,notblank,max=100"`
+	Description *string    `json:"description" binding:"omitempty,max=2000"`
+	DueDate     *time.Time `json:"due_date"`
+	Score       *float64   `json:"score" binding:"omitempty,gte=0,lte=100"`
+	Feedback    *string    `json:"feedback" binding:"omitempty,max=2000"`
+}
+
+// HomeworkTransitionRequest 作业状态变更请求
+type HomeworkTransitionRequest struct {
+	Status   string   `json:"status" binding:"required,oneof=assigned submitted checked"`
+	Score    *float64 `json:"score" binding:"omitempty,gte=0,lte=100"` // 批改时填写
+	Feedback *string  `json:"feedback" binding:"omitempty,max=2000"`
+}
+
+// HomeworkResponse 作业信息
+type HomeworkResponse struct {
+	ID          uint             `json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Version     uint             `json:"version"`
+	StudentID   uint             `json:"student_id"`
+	Student     *StudentResponse `json:"student,omitempty"`
+	CourseID    uint             `json:"course_id"`
+	Course      *CourseResponse  `json:"course,omitempty"`
+	ScheduleID  *uint            `json:"schedule_id"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	AssignedAt  time.Time        `json:"assigned_at"`
+	DueDate     time.Time        `json:"due_date"`
+	Status      string           `json:"status"`
+	Overdue     bool             `json:"overdue"` // 已过截止时间仍未提交
+	SubmittedAt *time.Time       `json:"submitted_at"`
+	CheckedAt   *time.Time       `json:"checked_at"`
+	Score       *float64         `json:"score"`
+	Feedback    string           `json:"feedback"`
+}
+
+func (r CreateHomeworkRequest) toModel() models.Homework {
+	assignedAt := time.Now()
+	if r.AssignedAt != nil {
+		assignedAt = *r.AssignedAt
+	}
+	return models.Homework{
+		Version:     1,
+		StudentID:   r.StudentID,
+		CourseID:    r.CourseID,
+		ScheduleID:  r.ScheduleID,
+		Title:       r.Title,
+		Description: r.Description,
+		AssignedAt:  assignedAt,
+		DueDate:     r.DueDate,
+		Status:      HomeworkAssigned,
+	}
+}
+
+func (r UpdateHomeworkRequest) apply(hw *models.Homework) {
+	if r.Title != nil {
+		hw.Title = *r.Title
+	}
+	if r.Description != nil {
+		hw.Description = *r.Description
+	}
+	if r.DueDate != nil {
+		hw.DueDate = *r.DueDate
+	}
+	if r.Score != nil {
+		hw.Score = r.Score
+	}
+	if r.Feedback != nil {
+		hw.Feedback = *r.Feedback
+	}
+}
+
+func newHomeworkResponse(hw models.Homework) HomeworkResponse {
+	resp := HomeworkResponse{
+		ID:          hw.ID,
+		CreatedAt:   hw.CreatedAt,
+		UpdatedAt:   hw.UpdatedAt,
+		Version:     hw.Version,
+		StudentID:   hw.StudentID,
+		CourseID:    hw.CourseID,
+		ScheduleID:  hw.ScheduleID,
+		Title:       hw.Title,
+		Description: hw.Description,
+		AssignedAt:  hw.AssignedAt,
+		DueDate:     hw.DueDate,
+		Status:      hw.Status,
+		Overdue:     homeworkOverdue(hw, time.Now()),
+		SubmittedAt: hw.SubmittedAt,
+		CheckedAt:   hw.CheckedAt,
+		Score:       hw.Score,
+		Feedback:    hw.Feedback,
+	}
+	if hw.Student != nil {
+		student := newStudentResponse(*hw.Student)
+		resp.Student = &student
+	}
+	if hw.Course != nil {
+		course := newCourseResponse(*hw.Course)
+		resp.Course = &course
+	}
+	return resp
+}
+
+// homeworkListSpec 作业列表允许的排序与筛选字段
+var homeworkListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":          "id",
+		"due_date":    "due_date",
+		"assigned_at": "assigned_at",
+		"status":      "status",
+		"created_at":  "created_at",
+	},
+	DefaultSort: "due_date DESC",
+	Filters: map[string]string{
+		"student_id":  "student_id",
+		"course_id":   "course_id",
+		"schedule_id": "schedule_id",
+		"status":      "status",
+	},
+	LikeFilters: map[string]string{"title": "title"},
+	DateRange:   "due_date",
+	Preloads:    []string{"Student", "Course"},
+}
+
+// GetAll 获取作业列表
+// @Summary 获取作业列表
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 作业管理
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param schedule_id query int false "排课ID"
+// @Param status query string false "状态: assigned, submitted, checked"
+// @Param title query string false "标题（模糊匹配）"
+// @Param start_date query string false "截止开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "截止结束日期 (yyyy-MM-dd)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, due_date, assigned_at, status, created_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Success 200 {array} HomeworkResponse
+// @Failure 400 {object} map[string]string
+// @Router /homework [get]
+func (h *HomeworkHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, homeworkListSpec, newHomeworkResponse)
+}
+
+// GetOverdue 获取逾期作业
+// @Summary 获取逾期作业
+// @Description 返回已过截止时间仍未提交的作业，按截止时间升序
+// @Tags 作业管理
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Success 200 {array} HomeworkResponse
+// @Router /homework/overdue [get]
+func (h *HomeworkHandler) GetOverdue(c *gin.Context) {
+	query := overdueHomework(h.DB, time.Now()).Preload("Student").Preload("Course")
+	if studentID := c.Query("student_id"); studentID != "" {
+		query = query.Where("student_id = ?", studentID)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	var homework []models.Homework
+	if err := query.Order("due_date ASC, id ASC").Find(&homework).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]HomeworkResponse, 0, len(homework))
+	for _, hw := range homework {
+		result = append(result, newHomeworkResponse(hw))
+	}
+	respondWithETag(c, result)
+}
+
+// Create 布置作业
+// @Summary 布置作业
+// @Tags 作业管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param homework body CreateHomeworkRequest true "作业信息"
+// @Success 201 {object} HomeworkResponse
+// @Failure 400 {object} map[string]string
+// @Router /homework [post]
+func (h *HomeworkHandler) Create(c *gin.Context) {
+	var req CreateHomeworkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	if err := h.DB.First(&models.Student{}, req.StudentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("student_id", "学生不存在"))
+		return
+	}
+	if err := h.DB.First(&models.Course{}, req.CourseID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("course_id", "科目不存在"))
+		return
+	}
+	homework := req.toModel()
+	if !homework.DueDate.After(homework.AssignedAt) {
+		c.JSON(http.StatusBadRequest, fieldError("due_date", "必须晚于布置时间"))
+		return
+	}
+	if homework.ScheduleID != nil {
+		var schedule models.Schedule
+		if err := h.DB.First(&schedule, *homework.ScheduleID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, fieldError("schedule_id", "排课不存在"))
+			return
+		}
+		if schedule.StudentID != homework.StudentID || schedule.CourseID != homework.CourseID {
+			c.JSON(http.StatusBadRequest, fieldError("schedule_id", "排课与作业的学生或科目不一致"))
+			return
+		}
+	}
+	if err := h.DB.Create(&homework).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.DB.Preload("Student").Preload("Course").First(&homework, homework.ID)
+	respondVersioned(c, http.StatusCreated, homework.Version, newHomeworkResponse(homework))
+}
+
+// Get 获取作业详情
+// @Summary 获取作业详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 作业管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "作业ID"
+// @Success 200 {object} HomeworkResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /homework/{id} [get]
+func (h *HomeworkHandler) Get(c *gin.Context) {
+	var homework models.Homework
+	if err := h.DB.Preload("Student").Preload("Course").First(&homework, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "作业不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, homework.Version, newHomeworkResponse(homework))
+}
+
+// Update 更新作业
+// @Summary 更新作业
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412。得分与评语仅在已批改（checked）时可以修改
+// @Tags 作业管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "作业ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param homework body UpdateHomeworkRequest true "作业信息"
+// @Success 200 {object} HomeworkResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /homework/{id} [put]
+// @Router /homework/{id} [patch]
+func (h *HomeworkHandler) Update(c *gin.Context) {
+	var homework models.Homework
+	if err := h.DB.First(&homework, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "作业不存在"})
+		return
+	}
+	if !checkIfMatch(c, homework.Version) {
+		return
+	}
+	var req UpdateHomeworkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	if homework.Status != HomeworkChecked && (req.Score != nil || req.Feedback != nil) {
+		c.JSON(http.StatusBadRequest, fieldError("score", "仅批改后可以填写得分与评语"))
+		return
+	}
+	req.apply(&homework)
+	if !homework.DueDate.After(homework.AssignedAt) {
+		c.JSON(http.StatusBadRequest, fieldError("due_date", "必须晚于布置时间"))
+		return
+	}
+	if err := saveVersioned(h.DB, &homework, &homework.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Student").Preload("Course").First(&homework, homework.ID)
+	respondVersioned(c, http.StatusOK, homework.Version, newHomeworkResponse(homework))
+}
+
+// Transition 变更作业状态
+// @Summary 变更作业状态
+// @Description 允许的流转：assigned → submitted/checked，submitted → assigned（退回）/checked，checked → submitted（重新批改）。批改时可同时填写得分与评语
+// @Tags 作业管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "作业ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param request body HomeworkTransitionRequest true "目标状态"
+// @Success 200 {object} HomeworkResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /homework/{id}/transition [post]
+func (h *HomeworkHandler) Transition(c *gin.Context) {
+	var homework models.Homework
+	if err := h.DB.First(&homework, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "作业不存在"})
+		return
+	}
+	if !checkIfMatch(c, homework.Version) {
+		return
+	}
+	var req HomeworkTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	if !homeworkTransitionAllowed(homework.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("作业不能从 %s 变更为 %s", homework.Status, req.Status)})
+		return
+	}
+	if req.Status != HomeworkChecked && (req.Score != nil || req.Feedback != nil) {
+		c.JSON(http.StatusBadRequest, fieldError("score", "仅批改时可以填写得分与评语"))
+		return
+	}
+
+	now := time.Now()
+	switch req.Status {
+	case HomeworkAssigned:
+		homework.SubmittedAt = nil
+		homework.CheckedAt = nil
+	case HomeworkSubmitted:
+		if homework.SubmittedAt == nil {
+			homework.SubmittedAt = &now
+		}
+		homework.CheckedAt = nil
+	case HomeworkChecked:
+		if homework.SubmittedAt == nil {
+			homework.SubmittedAt = &now
+		}
+		homework.CheckedAt = &now
+		if req.Score != nil {
+			homework.Score = req.Score
+		}
+		if req.Feedback != nil {
+			homework.Feedback = *req.Feedback
+		}
+	}
+	homework.Status = req.Status
+	if err := saveVersioned(h.DB, &homework, &homework.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Student").Preload("Course").First(&homework, homework.ID)
+	respondVersioned(c, http.StatusOK, homework.Version, newHomeworkResponse(homework))
+}
+
+// Delete 删除作业
+// @Summary 删除作业
+// @Tags 作业管理
+// @Security BearerAuth
+// @Param id path int true "作业ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /homework/{id} [delete]
+func (h *HomeworkHandler) Delete(c *gin.Context) {
+	var homework models.Homework
+	if err := h.DB.First(&homework, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "作业不存在"})
+		return
+	}
+	if !checkIfMatch(c, homework.Version) {
+		return
+	}
+	if err := deleteVersioned(h.DB, &homework, homework.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// DashboardHomework App 看板中的待交作业
+type DashboardHomework struct {
+	ID          uint   `json:"id"`
+	StudentName string `json:"student_name"`
+	Subject     string `json:"subject"`
+	Title       string `json:"title"`
+	DueDate     string `json:"due_date"` // yyyy-MM-dd HH:mm 格式
+	Overdue     bool   `json:"overdue"`
+}
+
+// GetDashboard App专用 - 获取待交作业
+// @Summary 获取逾期及今日到期的作业（App专用）
+// @Tags App接口
+// @Produce json
+// @Success 200 {array} DashboardHomework
+// @Router /dashboard/homework [get]
+func (h *HomeworkHandler) GetDashboard(c *gin.Context) {
+	now := time.Now()
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(24 * time.Hour)
+
+	var homework []models.Homework
+	err := h.DB.Preload("Student").Preload("Course").
+		Where("status = ? AND due_date < ?", HomeworkAssigned, endOfDay).
+		Order("due_date ASC, id ASC").
+		Find(&homework).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := make([]DashboardHomework, 0, len(homework))
+	for _, hw := range homework {
+		item := DashboardHomework{
+			ID:      hw.ID,
+			Title:   hw.Title,
+			DueDate: hw.DueDate.In(time.Local).Format("2006-01-02 15:04"),
+			Overdue: homeworkOverdue(hw, now),
+		}
+		if hw.Student != nil {
+			item.StudentName = hw.Student.Name
+		}
+		if hw.Course != nil {
+			item.Subject = hw.Course.Name
+		}
+		result = append(result, item)
+	}
+	respondWithETag(c, result)
+}
+
+// pendingHomeworkCounts 按学生和科目统计未提交的作业数与其中逾期的数量
+func pendingHomeworkCounts(db *gorm.DB, now time.Time) (pending, overdue map[[2]uint]int, err error) {
+	var homework []models.Homework
+	if err := db.Select("student_id, course_id, status, due_date").Where("status = ?", HomeworkAssigned).Find(&homework).Error; err != nil {
+		return nil, nil, err
+	}
+	pending = make(map[[2]uint]int)
+	overdue = make(map[[2]uint]int)
+	for _, hw := range homework {
+		key := [2]uint{hw.StudentID, hw.CourseID}
+		pending[key]++
+		if homeworkOverdue(hw, now) {
+			overdue[key]++
+		}
+	}
+	return pending, overdue, nil
+}
+
+// detachHomework 排课删除时解除作业与该排课的关联
+func detachHomework(tx *gorm.DB, scheduleID uint) error {
+	return tx.Model(&models.Homework{}).Where("schedule_id = ?", scheduleID).Update("schedule_id", nil).Error
+}
+
+// overdueHomework 已过截止时间仍未提交的作业查询
+func overdueHomework(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Model(&models.Homework{}).Where("status = ? AND due_date < ?", HomeworkAssigned, now)
+}
+
+// homeworkOverdue 作业是否逾期
+func homeworkOverdue(hw models.Homework, now time.Time) bool {
+	return hw.Status == HomeworkAssigned && hw.DueDate.Before(now)
+}
+
+func homeworkTransitionAllowed(from, to string) bool {
+	for _, next := range homeworkTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHomeworkTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{HomeworkAssigned, HomeworkSubmitted, true},
+		{HomeworkAssigned, HomeworkChecked, true},
+		{HomeworkSubmitted, HomeworkAssigned, true},
+		{HomeworkSubmitted, HomeworkChecked, true},
+		{HomeworkChecked, HomeworkSubmitted, true},
+		{HomeworkChecked, HomeworkAssigned, false},
+		{HomeworkAssigned, HomeworkAssigned, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := homeworkTransitionAllowed(tt.from, tt.to); got != tt.want {
+				t.Errorf("homeworkTransitionAllowed(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHomeworkWrites(t *testing.T) {
+	due := time.Now().AddDate(0, 0, 7).Format(time.RFC3339)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status string // 已有作业的状态
+		want   int
+	}{
+		{"布置作业", http.MethodPost, "/homework", `{"student_id":1,"course_id":1,"title":"练习","due_date":"` + due + `"}`, "", http.StatusCreated},
+		{"学生不存在", http.MethodPost, "/homework", `{"student_id":9,"course_id":1,"title":"练习","due_date":"` + due + `"}`, "", http.StatusBadRequest},
+		{"科目不存在", http.MethodPost, "/homework", `{"student_id":1,"course_id":9,"title":"练习","due_date":"` + due + `"}`, "", http.StatusBadRequest},
+		{"未批改时修改标题", http.MethodPatch, "/homework/1", `{"title":"新标题"}`, HomeworkAssigned, http.StatusOK},
+		{"未批改时填写得分", http.MethodPatch, "/homework/1", `{"score":90}`, HomeworkSubmitted, http.StatusBadRequest},
+		{"未批改时填写评语", http.MethodPatch, "/homework/1", `{"feedback":"好"}`, HomeworkAssigned, http.StatusBadRequest},
+		{"批改后修改得分", http.MethodPatch, "/homework/1", `{"score":90,"feedback":"好"}`, HomeworkChecked, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Schedule{}, &models.Homework{})
+			db.Create(&models.Student{Name: "张三"})
+			db.Create(&models.Course{Name: "数学"})
+			if tt.status != "" {
+				hw := models.Homework{StudentID: 1, CourseID: 1, Title: "练习", AssignedAt: time.Now(),
+					DueDate: time.Now().AddDate(0, 0, 7), Status: tt.status}
+				if err := db.Omit("Student", "Course").Create(&hw).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h := NewHomeworkHandler(db)
+			r := gin.New()
+			r.POST("/homework", h.Create)
+			r.PATCH("/homework/:id", h.Update)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", versionETag(1))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,651 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// 导入文件限制
+const (
+	maxImportSize = 10 << 20
+	maxImportRows = 5000
+)
+
+// 导入行的处理结果
+const (
+	ImportCreate = "create" // 将创建（试运行）或已创建
+	ImportSkip   = "skip"   // 与已有记录或文件中前面的行重复，跳过
+	ImportError  = "error"  // 数据有误
+)
+
+// importDateLayouts 导入时接受的日期写法
+var importDateLayouts = []string{"2006-01-02", "2006-1-2", "2006/1/2", "2006.1.2", "2006年1月2日"}
+
+// examTypeNames 考试类型的中文写法
+var examTypeNames = map[string]string{
+	"期中": "midterm", "期中考试": "midterm",
+	"期末": "final", "期末考试": "final",
+	"小测": "quiz", "测验": "quiz", "小测验": "quiz",
+}
+
+// importColumn 可导入的字段及默认匹配的表头
+type importColumn struct {
+	Field    string
+	Headers  []string
+	Required bool
+}
+
+var studentImportColumns = []importColumn{
+	{Field: "name", Headers: []string{"姓名", "学生姓名", "学生", "name"}, Required: true},
+	{Field: "parent_phone", Headers: []string{"家长电话", "家长手机", "联系电话", "电话", "手机", "parent_phone"}},
+	{Field: "grade", Headers: []string{"年级", "grade"}},
+	{Field: "notes", Headers: []string{"备注", "notes"}},
+}
+
+var examResultImportColumns = []importColumn{
+	{Field: "student", Headers: []string{"姓名", "学生姓名", "学生", "student"}, Required: true},
+	{Field: "course", Headers: []string{"科目", "课程", "course"}, Required: true},
+	{Field: "exam_type", Headers: []string{"考试类型", "类型", "exam_type"}, Required: true},
+	{Field: "exam_name", Headers: []string{"考试名称", "考试", "exam_name"}, Required: true},
+	{Field: "score", Headers: []string{"分数", "成绩", "得分", "score"}, Required: true},
+	{Field: "full_score", Headers: []string{"满分", "full_score"}},
+	{Field: "exam_date", Headers: []string{"考试日期", "日期", "exam_date"}, Required: true},
+	{Field: "comment", Headers: []string{"评语", "备注", "comment"}},
+}
+
+// ImportHandler 批量导入处理器
+type ImportHandler struct {
+	DB         *gorm.DB
+	ChartCache *ChartCache // 导入成绩后清除相关学生的图表缓存
+}
+
+// NewImportHandler 创建批量导入处理器
+func NewImportHandler(db *gorm.DB, chartCache *ChartCache) *ImportHandler {
+	return &ImportHandler{DB: db, ChartCache: chartCache}
+}
+
+// ImportRequest 导入表单，文件字段为 file
+type ImportRequest struct {
+	Mapping  string `form:"mapping"`  // 字段到表头的映射（JSON），如 {"score":"数学"}；未指定的字段按常见表头自动匹配
+	Defaults string `form:"defaults"` // 整表统一的字段值（JSON），如 {"exam_name":"期中考试"}，对应列为空时使用
+	Sheet    string `form:"sheet"`    // XLSX 工作表名，默认第一个
+	DryRun   bool   `form:"dry_run"`  // 只校验并预览，不写入
+}
+
+// ImportRowResult 单行的导入结果
+type ImportRowResult struct {
+	Row     int               `json:"row"`               // 表格中的行号，表头为第 1 行
+	Status  string            `json:"status"`            // create, skip, error
+	ID      *uint             `json:"id,omitempty"`      // 实际导入后创建的记录ID
+	Message string            `json:"message,omitempty"` // 跳过原因
+	Errors  map[string]string `json:"errors,omitempty"`  // 字段 → 错误信息
+	Data    interface{}       `json:"data,omitempty"`    // 解析后的数据
+}
+
+// ImportResult 导入结果；存在错误行时不会写入任何数据
+type ImportResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+func (r *ImportResult) add(row ImportRowResult) {
+	r.Total++
+	switch row.Status {
+	case ImportCreate:
+		r.Created++
+	case ImportSkip:
+		r.Skipped++
+	case ImportError:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// importSheet 已按列映射解析的表格
+type importSheet struct {
+	columns  map[string]int // 字段 → 列下标
+	defaults map[string]string
+	rows     [][]string
+	first    int // rows[0] 在表格中的行号
+}
+
+// value 读取字段值：单元格为空时取整表默认值
+func (s *importSheet) value(row []string, field string) string {
+	if i, ok := s.columns[field]; ok && i < len(row) {
+		if v := strings.TrimSpace(row[i]); v != "" {
+			return v
+		}
+	}
+	return strings.TrimSpace(s.defaults[field])
+}
+
+// ImportStudents 批量导入学生
+// @Summary 批量导入学生
+// @Description 上传 CSV 或 XLSX，首行为表头，默认识别 姓名、家长电话、年级、备注 等列，可用 mapping 指定；与已有学生同名的行跳过。dry_run=true 时只返回逐行预览与错误；正式导入时存在错误行则返回 422 且不写入任何数据
+// @Tags 数据导入
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV 或 XLSX 文件"
+// @Param mapping formData string false "字段到表头的映射（JSON），字段: name, parent_phone, grade, notes"
+// @Param defaults formData string false "整表统一的字段值（JSON）"
+// @Param sheet formData string false "XLSX 工作表名"
+// @Param dry_run formData bool false "只校验并预览"
+// @Success 200 {object} ImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} ImportResult
+// @Router /import/students [post]
+func (h *ImportHandler) ImportStudents(c *gin.Context) {
+	req, sheet, ok := h.readImport(c, studentImportColumns)
+	if !ok {
+		return
+	}
+
+	var existing []models.Student
+	if err := h.DB.Select("id", "name").Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	existingNames := make(map[string]bool, len(existing))
+	for _, s := range existing {
+		existingNames[strings.TrimSpace(s.Name)] = true
+	}
+
+	result := ImportResult{DryRun: req.DryRun, Rows: []ImportRowResult{}}
+	var pending []models.Student
+	var pendingRows []int // pending 中每条记录在 result.Rows 中的下标
+	seen := map[string]int{}
+	for i, row := range sheet.rows {
+		if blankRow(row) {
+			continue
+		}
+		line := sheet.first + i
+		data := CreateStudentRequest{
+			Name:        sheet.value(row, "name"),
+			ParentPhone: sheet.value(row, "parent_phone"),
+			Grade:       sheet.value(row, "grade"),
+			Notes:       sheet.value(row, "notes"),
+		}
+		rowResult := ImportRowResult{Row: line, Status: ImportCreate, Data: data}
+		if errs := importValidate(&data); errs != nil {
+			rowResult.Status, rowResult.Errors = ImportError, errs
+		} else if existingNames[data.Name] {
+			rowResult.Status, rowResult.Message = ImportSkip, "已存在同名学生"
+		} else if prev, dup := seen[data.Name]; dup {
+			rowResult.Status, rowResult.Message = ImportSkip, fmt.Sprintf("与第 %d 行重复", prev)
+		} else {
+			seen[data.Name] = line
+			pending = append(pending, data.toModel())
+			pendingRows = append(pendingRows, len(result.Rows))
+		}
+		result.add(rowResult)
+	}
+
+	if !h.commitImport(c, &result, func(tx *gorm.DB) error {
+		for i := range pending {
+			if err := tx.Create(&pending[i]).Error; err != nil {
+				return err
+			}
+			result.Rows[pendingRows[i]].ID = &pending[i].ID
+		}
+		return nil
+	}) {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ImportExamResults 批量导入考试成绩
+// @Summary 批量导入考试成绩
+// @Description 上传 CSV 或 XLSX，首行为表头，按姓名匹配学生、按名称匹配科目；考试类型可写 midterm/final/quiz 或 期中/期末/小测，满分默认 100。同一学生、科目、考试名称与日期已有成绩的行跳过。整表相同的字段可通过 defaults 统一指定。dry_run=true 时只返回逐行预览与错误；正式导入时存在错误行则返回 422 且不写入任何数据
+// @Tags 数据导入
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV 或 XLSX 文件"
+// @Param mapping formData string false "字段到表头的映射（JSON），字段: student, course, exam_type, exam_name, score, full_score, exam_date, comment"
+// @Param defaults formData string false "整表统一的字段值（JSON），如 {\"course\":\"数学\",\"exam_date\":\"2024-04-20\"}"
+// @Param sheet formData string false "XLSX 工作表名"
+// @Param dry_run formData bool false "只校验并预览"
+// @Success 200 {object} ImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} ImportResult
+// @Router /import/exam-results [post]
+func (h *ImportHandler) ImportExamResults(c *gin.Context) {
+	req, sheet, ok := h.readImport(c, examResultImportColumns)
+	if !ok {
+		return
+	}
+
+	var students []models.Student
+	if err := h.DB.Select("id", "name").Find(&students).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var courses []models.Course
+	if err := h.DB.Select("id", "name").Find(&courses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	studentIDs := map[string][]uint{}
+	for _, s := range students {
+		name := strings.TrimSpace(s.Name)
+		studentIDs[name] = append(studentIDs[name], s.ID)
+	}
+	courseIDs := map[string][]uint{}
+	for _, co := range courses {
+		name := strings.TrimSpace(co.Name)
+		courseIDs[name] = append(courseIDs[name], co.ID)
+	}
+
+	result := ImportResult{DryRun: req.DryRun, Rows: []ImportRowResult{}}
+	var pending []models.ExamResult
+	var pendingRows []int
+	type resultKey struct {
+		studentID, courseID uint
+		name, date          string
+	}
+	seen := map[resultKey]int{}
+	for i, row := range sheet.rows {
+		if blankRow(row) {
+			continue
+		}
+		line := sheet.first + i
+		rowResult := ImportRowResult{Row: line, Status: ImportCreate}
+		errs := map[string]string{}
+
+		var data CreateExamResultRequest
+		data.StudentID = matchImportName(studentIDs, sheet.value(row, "student"), "student", "学生", errs)
+		data.CourseID = matchImportName(courseIDs, sheet.value(row, "course"), "course", "科目", errs)
+		data.ExamType = sheet.value(row, "exam_type")
+		if t, ok := examTypeNames[data.ExamType]; ok {
+			data.ExamType = t
+		} else {
+			data.ExamType = strings.ToLower(data.ExamType)
+		}
+		data.ExamName = sheet.value(row, "exam_name")
+		data.Comment = sheet.value(row, "comment")
+		if raw := sheet.value(row, "score"); raw != "" {
+			score, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				errs["score"] = "必须是数字"
+			}
+			data.Score = score
+		} else {
+			errs["score"] = "不能为空"
+		}
+		data.FullScore = 100
+		if raw := sheet.value(row, "full_score"); raw != "" {
+			full, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				errs["full_score"] = "必须是数字"
+			}
+			data.FullScore = full
+		}
+		if raw := sheet.value(row, "exam_date"); raw != "" {
+			date, err := parseImportDate(raw)
+			if err != nil {
+				errs["exam_date"] = "日期格式无效，使用 yyyy-MM-dd"
+			}
+			data.ExamDate = date
+		} else {
+			errs["exam_date"] = "不能为空"
+		}
+		// 学生、科目未匹配时已在 student、course 上给出错误
+		for field, msg := range importValidate(&data) {
+			if _, ok := errs[field]; !ok && field != "student_id" && field != "course_id" {
+				errs[field] = msg
+			}
+		}
+		rowResult.Data = data
+
+		key := resultKey{data.StudentID, data.CourseID, data.ExamName, data.ExamDate.Format("2006-01-02")}
+		if len(errs) > 0 {
+			rowResult.Status, rowResult.Errors = ImportError, errs
+		} else if prev, dup := seen[key]; dup {
+			rowResult.Status, rowResult.Message = ImportSkip, fmt.Sprintf("与第 %d 行重复", prev)
+		} else {
+			seen[key] = line
+			pending = append(pending, data.toModel())
+			pendingRows = append(pendingRows, len(result.Rows))
+		}
+		result.add(rowResult)
+	}
+
+	// 与已有成绩重复的行跳过
+	if len(pending) > 0 {
+		ids := map[uint]bool{}
+		for _, r := range pending {
+			ids[r.StudentID] = true
+		}
+		var existing []models.ExamResult
+		err := h.DB.Select("student_id", "course_id", "exam_name", "exam_date").
+			Where("student_id IN ?", mapKeys(ids)).Find(&existing).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		exists := map[resultKey]bool{}
+		for _, r := range existing {
+			exists[resultKey{r.StudentID, r.CourseID, r.ExamName, r.ExamDate.Format("2006-01-02")}] = true
+		}
+		kept, keptRows := pending[:0], pendingRows[:0]
+		for i, r := range pending {
+			if exists[resultKey{r.StudentID, r.CourseID, r.ExamName, r.ExamDate.Format("2006-01-02")}] {
+				row := &result.Rows[pendingRows[i]]
+				row.Status, row.Message = ImportSkip, "已存在相同考试的成绩"
+				result.Created--
+				result.Skipped++
+				continue
+			}
+			kept, keptRows = append(kept, r), append(keptRows, pendingRows[i])
+		}
+		pending, pendingRows = kept, keptRows
+	}
+
+	if !h.commitImport(c, &result, func(tx *gorm.DB) error {
+		for i := range pending {
+			if err := tx.Create(&pending[i]).Error; err != nil {
+				return err
+			}
+			result.Rows[pendingRows[i]].ID = &pending[i].ID
+		}
+		return nil
+	}) {
+		return
+	}
+	if !result.DryRun {
+		invalidated := map[uint]bool{}
+		for _, r := range pending {
+			if !invalidated[r.StudentID] {
+				invalidated[r.StudentID] = true
+				h.ChartCache.InvalidateStudent(r.StudentID)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// commitImport 非试运行时在事务中写入；存在错误行时返回 422 且不写入。返回 false 表示已写出响应
+func (h *ImportHandler) commitImport(c *gin.Context, result *ImportResult, write func(tx *gorm.DB) error) bool {
+	if result.DryRun || result.Created == 0 && result.Failed == 0 {
+		return true
+	}
+	if result.Failed > 0 {
+		result.Created = 0
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return false
+	}
+	if err := h.DB.Transaction(write); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败，未写入任何数据: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// readImport 解析导入表单与文件，并按 mapping 与默认表头确定各字段所在列；失败时已写出响应
+func (h *ImportHandler) readImport(c *gin.Context, columns []importColumn) (ImportRequest, *importSheet, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize+1<<20)
+
+	var req ImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", maxImportSize>>20)})
+			return req, nil, false
+		}
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return req, nil, false
+	}
+	mapping := map[string]string{}
+	if req.Mapping != "" {
+		if err := json.Unmarshal([]byte(req.Mapping), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, fieldError("mapping", "必须是字段到表头的 JSON 对象"))
+			return req, nil, false
+		}
+	}
+	sheet := &importSheet{defaults: map[string]string{}, columns: map[string]int{}, first: 2}
+	if req.Defaults != "" {
+		if err := json.Unmarshal([]byte(req.Defaults), &sheet.defaults); err != nil {
+			c.JSON(http.StatusBadRequest, fieldError("defaults", "必须是字段到取值的 JSON 对象"))
+			return req, nil, false
+		}
+	}
+	known := map[string]bool{}
+	for _, col := range columns {
+		known[col.Field] = true
+	}
+	for field := range mapping {
+		if !known[field] {
+			c.JSON(http.StatusBadRequest, fieldError("mapping", "未知字段: "+field))
+			return req, nil, false
+		}
+	}
+	for field := range sheet.defaults {
+		if !known[field] {
+			c.JSON(http.StatusBadRequest, fieldError("defaults", "未知字段: "+field))
+			return req, nil, false
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("file", "不能为空"))
+		return req, nil, false
+	}
+	if header.Size > maxImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", maxImportSize>>20)})
+		return req, nil, false
+	}
+	rows, err := readImportTable(header, req.Sheet)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("file", err.Error()))
+		return req, nil, false
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, fieldError("file", "文件为空"))
+		return req, nil, false
+	}
+
+	headers := make(map[string]int, len(rows[0]))
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, dup := headers[name]; !dup && name != "" {
+			headers[name] = i
+		}
+	}
+	for _, col := range columns {
+		candidates := col.Headers
+		if name, ok := mapping[col.Field]; ok {
+			candidates = []string{name}
+		}
+		for _, name := range candidates {
+			if i, ok := headers[strings.ToLower(strings.TrimSpace(name))]; ok {
+				sheet.columns[col.Field] = i
+				break
+			}
+		}
+		if _, ok := sheet.columns[col.Field]; ok {
+			continue
+		}
+		if name, ok := mapping[col.Field]; ok {
+			c.JSON(http.StatusBadRequest, fieldError("mapping", fmt.Sprintf("找不到表头: %s", name)))
+			return req, nil, false
+		}
+		if col.Required && strings.TrimSpace(sheet.defaults[col.Field]) == "" {
+			c.JSON(http.StatusBadRequest, fieldError("file", fmt.Sprintf("缺少 %s 列（可用 mapping 指定表头或 defaults 指定统一取值）", col.Headers[0])))
+			return req, nil, false
+		}
+	}
+
+	sheet.rows = rows[1:]
+	return req, sheet, true
+}
+
+// errTooManyImportRows 数据行超过单次导入上限
+var errTooManyImportRows = fmt.Errorf("一次最多导入 %d 行", maxImportRows)
+
+// readImportTable 按扩展名逐行读取 CSV 或 XLSX，数据行超过上限时立即停止并返回 errTooManyImportRows
+func readImportTable(header *multipart.FileHeader, sheetName string) ([][]string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".csv":
+		r := csv.NewReader(file)
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("CSV 解析失败: %v", err)
+			}
+			// csv 会跳过空行，补齐以保持行号与文件一致
+			line, _ := r.FieldPos(0)
+			for len(rows) < line-1 && len(rows) <= maxImportRows {
+				rows = append(rows, nil)
+			}
+			if rows, err = appendImportRow(rows, record); err != nil {
+				return nil, err
+			}
+		}
+		if len(rows) > 0 && len(rows[0]) > 0 {
+			rows[0][0] = strings.TrimPrefix(rows[0][0], utf8BOM)
+		}
+		return rows, nil
+	case ".xlsx":
+		f, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("XLSX 解析失败: %v", err)
+		}
+		defer f.Close()
+		if sheetName == "" {
+			sheetName = f.GetSheetName(0)
+		} else if idx, _ := f.GetSheetIndex(sheetName); idx < 0 {
+			return nil, fmt.Errorf("工作表不存在: %s", sheetName)
+		}
+		sheetRows, err := f.Rows(sheetName)
+		if err != nil {
+			return nil, err
+		}
+		defer sheetRows.Close()
+		for sheetRows.Next() {
+			// 读取原始值，日期为 Excel 序列号，避免受单元格格式影响
+			record, err := sheetRows.Columns(excelize.Options{RawCellValue: true})
+			if err != nil {
+				return nil, fmt.Errorf("XLSX 解析失败: %v", err)
+			}
+			if rows, err = appendImportRow(rows, record); err != nil {
+				return nil, err
+			}
+		}
+		return trimEmptyRows(rows), sheetRows.Error()
+	default:
+		return nil, errors.New("仅支持 CSV 或 XLSX 文件")
+	}
+}
+
+// appendImportRow 追加一行；超出上限后的空行忽略，出现非空行时返回 errTooManyImportRows
+func appendImportRow(rows [][]string, record []string) ([][]string, error) {
+	switch {
+	case len(rows) <= maxImportRows:
+		return append(rows, record), nil
+	case blankRow(record):
+		return rows, nil
+	default:
+		return nil, errTooManyImportRows
+	}
+}
+
+// trimEmptyRows 去掉末尾的空行（带格式但没有内容的行）
+func trimEmptyRows(rows [][]string) [][]string {
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+// importValidate 按请求结构的 binding 规则校验一行数据，返回 字段 → 错误信息
+func importValidate(data interface{}) map[string]string {
+	err := binding.Validator.ValidateStruct(data)
+	if err == nil {
+		return nil
+	}
+	if fields, ok := validationFields(err); ok {
+		return fields
+	}
+	return map[string]string{"row": err.Error()}
+}
+
+// matchImportName 按名称匹配记录ID，未找到或有多条同名记录时写入 errs
+func matchImportName(ids map[string][]uint, name, field, label string, errs map[string]string) uint {
+	if name == "" {
+		errs[field] = "不能为空"
+		return 0
+	}
+	switch matched := ids[name]; len(matched) {
+	case 0:
+		errs[field] = fmt.Sprintf("%s不存在: %s", label, name)
+	case 1:
+		return matched[0]
+	default:
+		errs[field] = fmt.Sprintf("存在多个名为 %s 的%s", name, label)
+	}
+	return 0
+}
+
+// parseImportDate 解析常见日期写法或 Excel 日期序列号
+func parseImportDate(raw string) (time.Time, error) {
+	for _, layout := range importDateLayouts {
+		if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	serial, err := strconv.ParseFloat(raw, 64)
+	if err != nil || serial < 1 {
+		return time.Time{}, fmt.Errorf("invalid date: %s", raw)
+	}
+	t, err := excelize.ExcelDateToTime(serial, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local), nil
+}
+
+func blankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// importFileHeader 构造上传文件，用于直接调用 readImportTable
+func importFileHeader(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	body, contentType := importForm(t, name, data, nil)
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", contentType)
+	if err := req.ParseMultipartForm(maxImportSize); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+func importForm(t *testing.T, name string, data []byte, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	w.Close()
+	return &buf, w.FormDataContentType()
+}
+
+func importCSV(header string, rows int, extra ...string) []byte {
+	var b strings.Builder
+	b.WriteString(utf8BOM + header + "\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "学生%d,初一\n", i)
+	}
+	for _, line := range extra {
+		b.WriteString(line + "\n")
+	}
+	return []byte(b.String())
+}
+
+func importXLSX(t *testing.T, rows int, trailingBlank bool) []byte {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	f.SetSheetRow("Sheet1", "A1", &[]interface{}{"姓名", "年级"})
+	for i := 0; i < rows; i++ {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow("Sheet1", cell, &[]interface{}{fmt.Sprintf("学生%d", i), "初一"})
+	}
+	if trailingBlank {
+		// 设置了格式但没有内容的行
+		cell, _ := excelize.CoordinatesToCellName(1, rows+3)
+		style, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+		f.SetCellStyle("Sheet1", cell, cell, style)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadImportTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     []byte
+		wantRows int
+		wantErr  error
+	}{
+		{"CSV 去掉 BOM", "a.csv", importCSV("姓名,年级", 2), 3, nil},
+		{"CSV 空行保留行号", "a.csv", importCSV("姓名,年级", 1, "", "李四,初二"), 4, nil},
+		{"CSV 恰好达到上限", "a.csv", importCSV("姓名,年级", maxImportRows), maxImportRows + 1, nil},
+		{"CSV 上限后的空行忽略", "a.csv", importCSV("姓名,年级", maxImportRows, ",", ""), maxImportRows + 1, nil},
+		{"CSV 超出上限", "a.csv", importCSV("姓名,年级", maxImportRows+1), 0, errTooManyImportRows},
+		{"XLSX", "a.xlsx", importXLSX(t, 2, false), 3, nil},
+		{"XLSX 末尾带格式的空行", "a.xlsx", importXLSX(t, 2, true), 3, nil},
+		{"XLSX 超出上限", "a.xlsx", importXLSX(t, maxImportRows+1, false), 0, errTooManyImportRows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readImportTable(importFileHeader(t, tt.file, tt.data), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(rows) != tt.wantRows {
+				t.Errorf("rows = %d, want %d", len(rows), tt.wantRows)
+			}
+			if len(rows) > 0 && rows[0][0] != "姓名" {
+				t.Errorf("header = %q, want 姓名", rows[0][0])
+			}
+		})
+	}
+}
+
+func TestReadImportTableErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		data  []byte
+		sheet string
+		want  string
+	}{
+		{"不支持的格式", "a.txt", []byte("姓名"), "", "仅支持 CSV 或 XLSX 文件"},
+		{"工作表不存在", "a.xlsx", importXLSX(t, 1, false), "成绩", "工作表不存在: 成绩"},
+		{"XLSX 损坏", "a.xlsx", []byte("not a zip"), "", "XLSX 解析失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readImportTable(importFileHeader(t, tt.file, tt.data), tt.sheet)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportDate(t *testing.T) {
+	want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"2024-03-05", false},
+		{"2024-3-5", false},
+		{"2024/3/5", false},
+		{"2024.3.5", false},
+		{"2024年3月5日", false},
+		{"45356", false}, // Excel 日期序列号
+		{"0", true},
+		{"下周一", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseImportDate(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(want) {
+				t.Errorf("date = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestImportStudentsRowLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		wantCode int
+	}{
+		{"达到上限", importCSV("姓名,年级", maxImportRows), http.StatusOK},
+		{"超出上限", importCSV("姓名,年级", maxImportRows+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{})
+			r := gin.New()
+			r.POST("/import/students", NewImportHandler(db, nil).ImportStudents)
+			body, contentType := importForm(t, "students.csv", tt.data, map[string]string{"dry_run": "true"})
+			req := httptest.NewRequest(http.MethodPost, "/import/students", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("POST = %d, want %d: %.200s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var result ImportResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != maxImportRows || result.Created != maxImportRows {
+				t.Errorf("total = %d, created = %d, want %d", result.Total, result.Created, maxImportRows)
+			}
+		})
+	}
+}
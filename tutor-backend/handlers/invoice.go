@@ -0,0 +1,614 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 账单状态
+const (
+	InvoiceDraft  = "draft"
+	InvoiceIssued = "issued"
+	InvoicePaid   = "paid"
+	InvoiceVoid   = "void"
+)
+
+// invoiceTransitions 允许的账单状态流转
+var invoiceTransitions = map[string][]string{
+	InvoiceDraft:  {InvoiceIssued, InvoiceVoid},
+	InvoiceIssued: {InvoicePaid, InvoiceVoid},
+}
+
+type InvoiceHandler struct {
+	DB      *gorm.DB
+	Billing config.BillingConfig
+}
+
+func NewInvoiceHandler(db *gorm.DB, billing config.BillingConfig) *InvoiceHandler {
+	return &InvoiceHandler{DB: db, Billing: billing}
+}
+
+// GenerateInvoicesRequest 生成账单请求
+type GenerateInvoicesRequest struct {
+	StudentID uint   `json:"student_id"`
+	StartDate string `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" binding:"required,datetime=2006-01-02"`
+	Notes     string `json:"notes" binding:"max=500"`
+}
+
+// InvoiceTransitionRequest 账单状态变更请求
+type InvoiceTransitionRequest struct {
+	Status string `json:"status" binding:"required,oneof=issued paid void"`
+}
+
+// InvoiceResponse 账单信息
+type InvoiceResponse struct {
+	ID          uint                  `json:"id"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+	Version     uint                  `json:"version"`
+	Number      string                `json:"number"`
+	StudentID   uint                  `json:"student_id"`
+	Student     *StudentResponse      `json:"student,omitempty"`
+	PeriodStart string                `json:"period_start"` // yyyy-MM-dd
+	PeriodEnd   string                `json:"period_end"`   // yyyy-MM-dd，包含当天
+	Status      string                `json:"status"`
+	Amount      float64               `json:"amount"`
+	Paid        float64               `json:"paid"`
+	Due         float64               `json:"due"`
+	IssuedAt    *time.Time            `json:"issued_at"`
+	PaidAt      *time.Time            `json:"paid_at"`
+	VoidedAt    *time.Time            `json:"voided_at"`
+	Notes       string                `json:"notes"`
+	Items       []InvoiceItemResponse `json:"items,omitempty"`
+}
+
+// InvoiceItemResponse 账单明细，每节课一条
+type InvoiceItemResponse struct {
+	ID          uint    `json:"id"`
+	InvoiceID   uint    `json:"invoice_id"`
+	ScheduleID  uint    `json:"schedule_id"`
+	CourseID    uint    `json:"course_id"`
+	Description string  `json:"description"`
+	Hours       float64 `json:"hours"`
+	Rate        float64 `json:"rate"`
+	Amount      float64 `json:"amount"`
+}
+
+// SkippedSchedule 生成账单时跳过的排课
+type SkippedSchedule struct {
+	ScheduleID uint   `json:"schedule_id"`
+	StudentID  uint   `json:"student_id"`
+	CourseID   uint   `json:"course_id"`
+	Reason     string `json:"reason"`
+}
+
+// GenerateInvoicesResponse 生成账单结果
+type GenerateInvoicesResponse struct {
+	Invoices []InvoiceResponse `json:"invoices"`
+	Skipped  []SkippedSchedule `json:"skipped"`
+}
+
+// FamilyStudentBalance 家庭中单个学生的应收情况
+type FamilyStudentBalance struct {
+	StudentID   uint    `json:"student_id"`
+	StudentName string  `json:"student_name"`
+	Invoiced    float64 `json:"invoiced"`
+	Paid        float64 `json:"paid"`
+	Outstanding float64 `json:"outstanding"`
+}
+
+// FamilyBalance 家庭应收汇总，按家长电话归并学生，未填写电话的学生单独成户
+type FamilyBalance struct {
+	ParentPhone string                 `json:"parent_phone"`
+	Students    []FamilyStudentBalance `json:"students"`
+	Invoiced    float64                `json:"invoiced"`
+	Paid        float64                `json:"paid"`
+	Outstanding float64                `json:"outstanding"`
+}
+
+func newInvoiceResponse(inv models.Invoice) InvoiceResponse {
+	resp := InvoiceResponse{
+		ID:          inv.ID,
+		CreatedAt:   inv.CreatedAt,
+		UpdatedAt:   inv.UpdatedAt,
+		Version:     inv.Version,
+		Number:      inv.Number,
+		StudentID:   inv.StudentID,
+		PeriodStart: inv.PeriodStart.Format("2006-01-02"),
+		PeriodEnd:   inv.PeriodEnd.Format("2006-01-02"),
+		Status:      inv.Status,
+		Amount:      inv.Amount,
+		Paid:        inv.Paid,
+		Due:         roundAmount(inv.Amount - inv.Paid),
+		IssuedAt:    inv.IssuedAt,
+		PaidAt:      inv.PaidAt,
+		VoidedAt:    inv.VoidedAt,
+		Notes:       inv.Notes,
+	}
+	if inv.Student != nil {
+		student := newStudentResponse(*inv.Student)
+		resp.Student = &student
+	}
+	for _, item := range inv.Items {
+		resp.Items = append(resp.Items, newInvoiceItemResponse(item))
+	}
+	return resp
+}
+
+func newInvoiceItemResponse(item models.InvoiceItem) InvoiceItemResponse {
+	return InvoiceItemResponse{
+		ID:          item.ID,
+		InvoiceID:   item.InvoiceID,
+		ScheduleID:  item.ScheduleID,
+		CourseID:    item.CourseID,
+		Description: item.Description,
+		Hours:       item.Hours,
+		Rate:        item.Rate,
+		Amount:      item.Amount,
+	}
+}
+
+// invoiceListSpec 账单列表允许的排序与筛选字段
+var invoiceListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":           "id",
+		"period_start": "period_start",
+		"amount":       "amount",
+		"status":       "status",
+		"created_at":   "created_at",
+	},
+	DefaultSort: "id DESC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"status":     "status",
+	},
+	LikeFilters: map[string]string{"number": "number"},
+	DateRange:   "period_start",
+	Preloads:    []string{"Student"},
+}
+
+// GetAll 获取账单列表
+// @Summary 获取账单列表
+// @Description 列表不包含明细；携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param status query string false "状态: draft, issued, paid, void"
+// @Param number query string false "账单号（模糊匹配）"
+// @Param start_date query string false "账期开始日期不早于 (yyyy-MM-dd)"
+// @Param end_date query string false "账期开始日期不晚于 (yyyy-MM-dd)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Success 200 {array} InvoiceResponse
+// @Failure 400 {object} map[string]string
+// @Router /invoices [get]
+func (h *InvoiceHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, invoiceListSpec, newInvoiceResponse)
+}
+
+// Get 获取账单详情
+// @Summary 获取账单详情（含明细）
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "账单ID"
+// @Success 200 {object} InvoiceResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /invoices/{id} [get]
+func (h *InvoiceHandler) Get(c *gin.Context) {
+	var inv models.Invoice
+	if err := h.DB.Preload("Student").Preload("Items").First(&inv, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账单不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, inv.Version, newInvoiceResponse(inv))
+}
+
+// Generate 按账期生成账单
+// @Summary 按账期生成草稿账单
+// @Description 汇总账期内已完成或迟取消、尚未计入有效账单且未从课时包扣减的排课，每个学生生成一张草稿账单；迟取消按取消政策比例计费，缺少单价的排课会被跳过并在结果中列出
+// @Tags 收费管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body GenerateInvoicesRequest true "账期与学生"
+// @Success 201 {object} GenerateInvoicesResponse
+// @Failure 400 {object} map[string]string
+// @Router /invoices/generate [post]
+func (h *InvoiceHandler) Generate(c *gin.Context) {
+	var req GenerateInvoicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	start, _ := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	end, _ := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, fieldError("end_date", "不能早于 start_date"))
+		return
+	}
+
+	result := GenerateInvoicesResponse{Invoices: []InvoiceResponse{}, Skipped: []SkippedSchedule{}}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		schedules, err := billableSchedules(tx, req.StudentID, start, end.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+
+		byStudent := make(map[uint][]models.Schedule)
+		order := make([]uint, 0)
+		for _, s := range schedules {
+			if _, ok := byStudent[s.StudentID]; !ok {
+				order = append(order, s.StudentID)
+			}
+			byStudent[s.StudentID] = append(byStudent[s.StudentID], s)
+		}
+
+		for _, studentID := range order {
+			inv := models.Invoice{
+				Version:     1,
+				StudentID:   studentID,
+				PeriodStart: start,
+				PeriodEnd:   end,
+				Status:      InvoiceDraft,
+				Notes:       req.Notes,
+			}
+			for _, s := range byStudent[studentID] {
+				rate, err := resolveRate(tx, s.Student, s.CourseID)
+				if err != nil {
+					return err
+				}
+				if rate == nil {
+					result.Skipped = append(result.Skipped, SkippedSchedule{
+						ScheduleID: s.ID,
+						StudentID:  s.StudentID,
+						CourseID:   s.CourseID,
+						Reason:     "未设置课时单价",
+					})
+					continue
+				}
+				hours := scheduleHours(s)
+				fraction := billableFraction(s, h.Billing)
+				description := fmt.Sprintf("%s %s-%s", s.Course.Name, s.StartTime.Format("2006-01-02 15:04"), s.EndTime.Format("15:04"))
+				if s.Status == ScheduleCancelled {
+					description += fmt.Sprintf("（迟取消，按 %s%% 计费）", formatAmount(fraction*100))
+				}
+				item := models.InvoiceItem{
+					ScheduleID:  s.ID,
+					CourseID:    s.CourseID,
+					Description: description,
+					Hours:       hours,
+					Rate:        rate.HourlyRate,
+					Amount:      roundAmount(hours * rate.HourlyRate * fraction),
+				}
+				inv.Items = append(inv.Items, item)
+				inv.Amount += item.Amount
+			}
+			if len(inv.Items) == 0 {
+				continue
+			}
+			inv.Amount = roundAmount(inv.Amount)
+			if err := tx.Create(&inv).Error; err != nil {
+				return err
+			}
+			inv.Number = fmt.Sprintf("INV%s-%05d", inv.CreatedAt.Format("200601"), inv.ID)
+			if err := tx.Model(&inv).Update("number", inv.Number).Error; err != nil {
+				return err
+			}
+			if err := tx.Preload("Student").Preload("Items").First(&inv, inv.ID).Error; err != nil {
+				return err
+			}
+			result.Invoices = append(result.Invoices, newInvoiceResponse(inv))
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	utils.Info("Invoices generated",
+		zap.String("start_date", req.StartDate),
+		zap.String("end_date", req.EndDate),
+		zap.Int("invoices", len(result.Invoices)),
+		zap.Int("skipped", len(result.Skipped)),
+	)
+	c.JSON(http.StatusCreated, result)
+}
+
+// Transition 变更账单状态
+// @Summary 变更账单状态
+// @Description 允许的流转：draft → issued/void，issued → paid/void。标记为 paid 需收款已覆盖应收金额（登记收款后会自动标记）；已有收款的账单不能作废
+// @Tags 收费管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "账单ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param request body InvoiceTransitionRequest true "目标状态"
+// @Success 200 {object} InvoiceResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /invoices/{id}/transition [post]
+func (h *InvoiceHandler) Transition(c *gin.Context) {
+	var inv models.Invoice
+	if err := h.DB.First(&inv, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账单不存在"})
+		return
+	}
+	if !checkIfMatch(c, inv.Version) {
+		return
+	}
+	var req InvoiceTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	if !invoiceTransitionAllowed(inv.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("账单不能从 %s 变更为 %s", inv.Status, req.Status)})
+		return
+	}
+
+	now := time.Now()
+	switch req.Status {
+	case InvoiceIssued:
+		inv.IssuedAt = &now
+	case InvoicePaid:
+		if inv.Paid < inv.Amount {
+			c.JSON(http.StatusConflict, gin.H{"error": "收款金额不足，不能标记为已支付"})
+			return
+		}
+		inv.PaidAt = &now
+	case InvoiceVoid:
+		if inv.Paid > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "账单已有收款记录，请先删除收款后再作废"})
+			return
+		}
+		inv.VoidedAt = &now
+	}
+	from := inv.Status
+	inv.Status = req.Status
+	if err := saveVersioned(h.DB, &inv, &inv.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	utils.Info("Invoice status changed",
+		zap.Uint("invoice_id", inv.ID),
+		zap.String("from", from),
+		zap.String("to", inv.Status),
+	)
+	h.DB.Preload("Student").Preload("Items").First(&inv, inv.ID)
+	respondVersioned(c, http.StatusOK, inv.Version, newInvoiceResponse(inv))
+}
+
+// Delete 删除草稿账单
+// @Summary 删除草稿账单
+// @Description 仅草稿状态的账单可以删除，其他状态请作废
+// @Tags 收费管理
+// @Security BearerAuth
+// @Param id path int true "账单ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /invoices/{id} [delete]
+func (h *InvoiceHandler) Delete(c *gin.Context) {
+	var inv models.Invoice
+	if err := h.DB.First(&inv, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账单不存在"})
+		return
+	}
+	if !checkIfMatch(c, inv.Version) {
+		return
+	}
+	if inv.Status != InvoiceDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "只能删除草稿账单"})
+		return
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteVersioned(tx, &inv, inv.Version); err != nil {
+			return err
+		}
+		return tx.Where("invoice_id = ?", inv.ID).Delete(&models.InvoiceItem{}).Error
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetOutstanding 获取家庭应收汇总
+// @Summary 获取家庭应收汇总
+// @Description 应收 = 已开具（issued/paid）账单金额 - 全部收款（含预收款），按家长电话归并为家庭
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "仅返回该学生所在家庭"
+// @Success 200 {array} FamilyBalance
+// @Failure 404 {object} map[string]string
+// @Router /billing/outstanding [get]
+func (h *InvoiceHandler) GetOutstanding(c *gin.Context) {
+	var students []models.Student
+	query := h.DB.Order("id ASC")
+	if id := c.Query("student_id"); id != "" {
+		var student models.Student
+		if err := h.DB.First(&student, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+			return
+		}
+		if student.ParentPhone != "" {
+			query = query.Where("parent_phone = ?", student.ParentPhone)
+		} else {
+			query = query.Where("id = ?", student.ID)
+		}
+	}
+	if err := query.Find(&students).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	invoiced, err := sumByStudent(h.DB.Model(&models.Invoice{}).Where("status IN ?", []string{InvoiceIssued, InvoicePaid}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	paid, err := sumByStudent(h.DB.Model(&models.Payment{}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	index := make(map[string]*FamilyBalance)
+	order := make([]string, 0)
+	for _, s := range students {
+		if invoiced[s.ID] == 0 && paid[s.ID] == 0 {
+			continue
+		}
+		key := s.ParentPhone
+		if key == "" {
+			key = fmt.Sprintf("student:%d", s.ID)
+		}
+		family, ok := index[key]
+		if !ok {
+			family = &FamilyBalance{ParentPhone: s.ParentPhone, Students: []FamilyStudentBalance{}}
+			index[key] = family
+			order = append(order, key)
+		}
+		family.Students = append(family.Students, FamilyStudentBalance{
+			StudentID:   s.ID,
+			StudentName: s.Name,
+			Invoiced:    invoiced[s.ID],
+			Paid:        paid[s.ID],
+			Outstanding: roundAmount(invoiced[s.ID] - paid[s.ID]),
+		})
+		family.Invoiced += invoiced[s.ID]
+		family.Paid += paid[s.ID]
+	}
+
+	result := make([]FamilyBalance, 0, len(order))
+	for _, key := range order {
+		family := index[key]
+		family.Invoiced = roundAmount(family.Invoiced)
+		family.Paid = roundAmount(family.Paid)
+		family.Outstanding = roundAmount(family.Invoiced - family.Paid)
+		result = append(result, *family)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Outstanding > result[j].Outstanding })
+	respondWithETag(c, result)
+}
+
+// checkScheduleInvoiced 已计入未作废账单的排课不能删除，也不能改变计费（学生、科目、计费比例、时长），需先作废账单；after 为 nil 表示删除
+func checkScheduleInvoiced(tx *gorm.DB, billing config.BillingConfig, before, after *models.Schedule) error {
+	if after != nil && after.StudentID == before.StudentID && after.CourseID == before.CourseID &&
+		billableFraction(*after, billing) == billableFraction(*before, billing) &&
+		scheduleHours(*after) == scheduleHours(*before) {
+		return nil
+	}
+	var count int64
+	err := tx.Model(&models.InvoiceItem{}).
+		Joins("JOIN invoices ON invoices.id = invoice_items.invoice_id").
+		Where("invoice_items.schedule_id = ? AND invoices.status <> ?", before.ID, InvoiceVoid).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return conflictError{"该课程已计入账单，请先作废账单"}
+	}
+	return nil
+}
+
+// billableSchedules 查询 [start, end) 内可计费的排课（已完成或迟取消）：
+// 未计入草稿/已开具/已支付账单，且未从课时包扣减
+func billableSchedules(db *gorm.DB, studentID uint, start, end time.Time) ([]models.Schedule, error) {
+	query := db.Preload("Student").Preload("Course").
+		Where("status = ? OR (status = ? AND late_cancel = ?)", ScheduleCompleted, ScheduleCancelled, true).
+		Where("start_time >= ? AND start_time < ?", start, end).
+		Where("id NOT IN (?)", db.Model(&models.InvoiceItem{}).
+			Select("invoice_items.schedule_id").
+			Joins("JOIN invoices ON invoices.id = invoice_items.invoice_id").
+			Where("invoices.status <> ?", InvoiceVoid)).
+		Where("id NOT IN (?)", db.Model(&models.PackageDeduction{}).
+			Select("schedule_id").
+			Where("reversed_at IS NULL")).
+		Order("student_id ASC, start_time ASC")
+	if studentID != 0 {
+		query = query.Where("student_id = ?", studentID)
+	}
+	var schedules []models.Schedule
+	err := query.Find(&schedules).Error
+	return schedules, err
+}
+
+// sumByStudent 按学生汇总 amount 列
+func sumByStudent(query *gorm.DB) (map[uint]float64, error) {
+	type row struct {
+		StudentID uint
+		Total     float64
+	}
+	var rows []row
+	if err := query.Select("student_id, SUM(amount) AS total").Group("student_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	sums := make(map[uint]float64, len(rows))
+	for _, r := range rows {
+		sums[r.StudentID] = roundAmount(r.Total)
+	}
+	return sums, nil
+}
+
+// refreshInvoicePaid 重新汇总账单的已收金额，收款覆盖应收时自动标记为已支付，不足时退回已开具
+func refreshInvoicePaid(tx *gorm.DB, invoiceID uint) error {
+	var inv models.Invoice
+	if err := tx.First(&inv, invoiceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	var paid float64
+	if err := tx.Model(&models.Payment{}).Where("invoice_id = ?", invoiceID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&paid).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"paid":    roundAmount(paid),
+		"version": gorm.Expr("version + 1"),
+	}
+	switch {
+	case inv.Status == InvoiceIssued && paid >= inv.Amount:
+		updates["status"] = InvoicePaid
+		updates["paid_at"] = time.Now()
+	case inv.Status == InvoicePaid && paid < inv.Amount:
+		updates["status"] = InvoiceIssued
+		updates["paid_at"] = nil
+	}
+	return tx.Model(&models.Invoice{}).Where("id = ?", invoiceID).Updates(updates).Error
+}
+
+// invoiceTransitionAllowed 判断账单状态流转是否合法
+func invoiceTransitionAllowed(from, to string) bool {
+	for _, next := range invoiceTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
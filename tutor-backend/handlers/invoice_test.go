@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewInvoiceResponse(t *testing.T) {
+	base := models.Invoice{
+		ID:          1,
+		Number:      "INV-202403-0001",
+		StudentID:   1,
+		PeriodStart: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+		PeriodEnd:   time.Date(2024, 3, 31, 0, 0, 0, 0, time.Local),
+		Status:      "issued",
+		Amount:      450,
+		Paid:        200.1,
+	}
+	withItems := base
+	withItems.Items = []models.InvoiceItem{
+		{ID: 1, InvoiceID: 1, ScheduleID: 3, CourseID: 2, Description: "数学 03-05", Hours: 2, Rate: 150, Amount: 300},
+		{ID: 2, InvoiceID: 1, ScheduleID: 4, CourseID: 2, Description: "数学 03-12（迟取消）", Hours: 1, Rate: 150, Amount: 150},
+	}
+
+	tests := []struct {
+		name      string
+		invoice   models.Invoice
+		wantItems []InvoiceItemResponse
+		wantJSON  string
+	}{
+		{"无明细时省略 items", base, nil, ""},
+		{"明细逐条转换", withItems, []InvoiceItemResponse{
+			{ID: 1, InvoiceID: 1, ScheduleID: 3, CourseID: 2, Description: "数学 03-05", Hours: 2, Rate: 150, Amount: 300},
+			{ID: 2, InvoiceID: 1, ScheduleID: 4, CourseID: 2, Description: "数学 03-12（迟取消）", Hours: 1, Rate: 150, Amount: 150},
+		}, `"items":[{"id":1,"invoice_id":1,"schedule_id":3,"course_id":2,"description":"数学 03-05","hours":2,"rate":150,"amount":300}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newInvoiceResponse(tt.invoice)
+			if resp.Due != 249.9 {
+				t.Errorf("due = %v, want 249.9", resp.Due)
+			}
+			if resp.PeriodStart != "2024-03-01" || resp.PeriodEnd != "2024-03-31" {
+				t.Errorf("period = %s ~ %s", resp.PeriodStart, resp.PeriodEnd)
+			}
+			if len(resp.Items) != len(tt.wantItems) {
+				t.Fatalf("items = %d, want %d", len(resp.Items), len(tt.wantItems))
+			}
+			for i, item := range resp.Items {
+				if item != tt.wantItems[i] {
+					t.Errorf("items[%d] = %+v, want %+v", i, item, tt.wantItems[i])
+				}
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantJSON == "" && strings.Contains(string(data), `"items"`) {
+				t.Errorf("json contains items: %s", data)
+			}
+			if !strings.Contains(string(data), tt.wantJSON) {
+				t.Errorf("json = %s, want to contain %s", data, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestInvoicedScheduleChanges(t *testing.T) {
+	billing := config.BillingConfig{CancelNoticeHours: 24, LateCancelChargeRate: 1}
+	start := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name          string
+		invoiceStatus string
+		method        string
+		body          string
+		wantCode      int
+	}{
+		{"已开具账单不能删除", InvoiceIssued, http.MethodDelete, "", http.StatusConflict},
+		{"草稿账单不能删除", InvoiceDraft, http.MethodDelete, "", http.StatusConflict},
+		{"账单作废后可以删除", InvoiceVoid, http.MethodDelete, "", http.StatusOK},
+		{"已开具账单不能恢复为已排课", InvoiceIssued, http.MethodPatch, `{"status":"scheduled"}`, http.StatusConflict},
+		{"已开具账单不能改时长", InvoiceIssued, http.MethodPatch, `{"end_time":"` + start.Add(2*time.Hour).Format(time.RFC3339) + `"}`, http.StatusConflict},
+		{"已开具账单不能改学生", InvoiceIssued, http.MethodPatch, `{"student_id":2}`, http.StatusConflict},
+		{"不影响计费的修改允许", InvoiceIssued, http.MethodPatch, `{"start_time":"` + start.Add(-time.Hour).Format(time.RFC3339) + `","end_time":"` + start.Format(time.RFC3339) + `"}`, http.StatusOK},
+		{"账单作废后可以恢复", InvoiceVoid, http.MethodPatch, `{"status":"scheduled"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Schedule{}, &models.Attachment{}, &models.Homework{},
+				&models.LessonPackage{}, &models.PackageDeduction{}, &models.LessonRecord{}, &models.MakeupCredit{},
+				&models.Invoice{}, &models.InvoiceItem{})
+			db.Create(&[]models.Student{{Name: "张三"}, {Name: "李四"}})
+			db.Create(&models.Course{Name: "数学"})
+			s := models.Schedule{Version: 1, StudentID: 1, CourseID: 1, StartTime: start, EndTime: start.Add(time.Hour), Status: ScheduleCompleted}
+			if err := db.Omit("Student", "Course").Create(&s).Error; err != nil {
+				t.Fatal(err)
+			}
+			inv := models.Invoice{Version: 1, Number: "INV-1", StudentID: 1, PeriodStart: start, PeriodEnd: start, Status: tt.invoiceStatus, Amount: 200,
+				Items: []models.InvoiceItem{{ScheduleID: s.ID, CourseID: 1, Hours: 1, Rate: 200, Amount: 200}}}
+			if err := db.Omit("Student").Create(&inv).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewScheduleHandler(db, billing, config.MakeupConfig{}, config.AttendanceConfig{}, config.ScheduleConfig{}, nil)
+			r := gin.New()
+			r.PATCH("/schedules/:id", h.Update)
+			r.DELETE("/schedules/:id", h.Delete)
+			req := httptest.NewRequest(tt.method, "/schedules/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", versionETag(1))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("%s = %d, want %d: %s", tt.method, w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode == http.StatusConflict && !strings.Contains(w.Body.String(), "请先作废账单") {
+				t.Errorf("body = %s, want hint to void the invoice", w.Body.String())
+			}
+		})
+	}
+}
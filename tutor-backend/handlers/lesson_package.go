@@ -0,0 +1,561 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 课时包计量单位
+const (
+	PackageUnitHour   = "hour"
+	PackageUnitLesson = "lesson"
+)
+
+type PackageHandler struct {
+	DB      *gorm.DB
+	Billing config.BillingConfig
+}
+
+func NewPackageHandler(db *gorm.DB, billing config.BillingConfig) *PackageHandler {
+	return &PackageHandler{DB: db, Billing: billing}
+}
+
+// CreatePackageRequest 创建课时包请求
+type CreatePackageRequest struct {
+	StudentID   uint       `json:"student_id" binding:"required"`
+	CourseID    uint       `json:"course_id" binding:"required"`
+	Unit        string     `json:"unit" binding:"required,oneof=hour lesson"`
+	Quantity    float64    `json:"quantity" binding:"required,gt=0"`
+	Price       float64    `json:"price" binding:"gte=0"`
+	PurchasedAt *time.Time `json:"purchased_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Notes       string     `json:"notes" binding:"max=500"`
+}
+
+// UpdatePackageRequest 更新课时包请求，未提供的字段保持不变；已消耗数量只能由排课完成自动维护
+type UpdatePackageRequest struct {
+	Quantity    *float64     `json:"quantity" binding:"omitempty,gt=0"`
+	Price       *float64     `json:"price" binding:"omitempty,gte=0"`
+	PurchasedAt *time.Time   `json:"purchased_at"`
+	ExpiresAt   NullableTime `json:"expires_at" swaggertype:"string" format:"date-time"` // 传 null 取消有效期
+	Notes       *string      `json:"notes" binding:"omitempty,max=500"`
+}
+
+// PackageResponse 课时包信息
+type PackageResponse struct {
+	ID          uint             `json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Version     uint             `json:"version"`
+	StudentID   uint             `json:"student_id"`
+	Student     *StudentResponse `json:"student,omitempty"`
+	CourseID    uint             `json:"course_id"`
+	Course      *CourseResponse  `json:"course,omitempty"`
+	Unit        string           `json:"unit"`
+	Quantity    float64          `json:"quantity"`
+	Used        float64          `json:"used"`
+	Balance     float64          `json:"balance"`
+	Price       float64          `json:"price"`
+	PurchasedAt time.Time        `json:"purchased_at"`
+	ExpiresAt   *time.Time       `json:"expires_at"`
+	Expired     bool             `json:"expired"`
+	LowBalance  bool             `json:"low_balance"`
+	Notes       string           `json:"notes"`
+}
+
+// BalanceSummary 学生某科目的课时余额汇总（仅统计未过期的课时包）
+type BalanceSummary struct {
+	StudentID   uint              `json:"student_id"`
+	StudentName string            `json:"student_name"`
+	CourseID    uint              `json:"course_id"`
+	CourseName  string            `json:"course_name"`
+	Unit        string            `json:"unit"`
+	Balance     float64           `json:"balance"`
+	LowBalance  bool              `json:"low_balance"`
+	Packages    []PackageResponse `json:"packages"`
+}
+
+func (r CreatePackageRequest) toModel() models.LessonPackage {
+	purchasedAt := time.Now()
+	if r.PurchasedAt != nil {
+		purchasedAt = *r.PurchasedAt
+	}
+	return models.LessonPackage{
+		Version:     1,
+		StudentID:   r.StudentID,
+		CourseID:    r.CourseID,
+		Unit:        r.Unit,
+		Quantity:    r.Quantity,
+		Price:       r.Price,
+		PurchasedAt: purchasedAt,
+		ExpiresAt:   r.ExpiresAt,
+		Notes:       r.Notes,
+	}
+}
+
+func (r UpdatePackageRequest) apply(p *models.LessonPackage) {
+	if r.Quantity != nil {
+		p.Quantity = *r.Quantity
+	}
+	if r.Price != nil {
+		p.Price = *r.Price
+	}
+	if r.PurchasedAt != nil {
+		p.PurchasedAt = *r.PurchasedAt
+	}
+	if r.ExpiresAt.Set {
+		p.ExpiresAt = r.ExpiresAt.Value
+	}
+	if r.Notes != nil {
+		p.Notes = *r.Notes
+	}
+}
+
+func (h *PackageHandler) newPackageResponse(p models.LessonPackage) PackageResponse {
+	resp := PackageResponse{
+		ID:          p.ID,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		Version:     p.Version,
+		StudentID:   p.StudentID,
+		CourseID:    p.CourseID,
+		Unit:        p.Unit,
+		Quantity:    p.Quantity,
+		Used:        p.Used,
+		Balance:     p.Balance(),
+		Price:       p.Price,
+		PurchasedAt: p.PurchasedAt,
+		ExpiresAt:   p.ExpiresAt,
+		Expired:     packageExpired(p, time.Now()),
+		Notes:       p.Notes,
+	}
+	resp.LowBalance = !resp.Expired && resp.Balance <= h.Billing.LowBalanceThreshold
+	if p.Student != nil {
+		student := newStudentResponse(*p.Student)
+		resp.Student = &student
+	}
+	if p.Course != nil {
+		course := newCourseResponse(*p.Course)
+		resp.Course = &course
+	}
+	return resp
+}
+
+// packageListSpec 课时包列表允许的排序与筛选字段
+var packageListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":           "id",
+		"purchased_at": "purchased_at",
+		"expires_at":   "expires_at",
+		"created_at":   "created_at",
+	},
+	DefaultSort: "purchased_at DESC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"course_id":  "course_id",
+		"unit":       "unit",
+	},
+	Preloads: []string{"Student", "Course"},
+}
+
+// GetAll 获取课时包列表
+// @Summary 获取课时包列表
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 课时包
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param unit query string false "计量单位: hour, lesson"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Success 200 {array} PackageResponse
+// @Failure 400 {object} map[string]string
+// @Router /packages [get]
+func (h *PackageHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, packageListSpec, h.newPackageResponse)
+}
+
+// Get 获取课时包详情
+// @Summary 获取课时包详情
+// @Tags 课时包
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "课时包ID"
+// @Success 200 {object} PackageResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /packages/{id} [get]
+func (h *PackageHandler) Get(c *gin.Context) {
+	var pkg models.LessonPackage
+	if err := h.DB.Preload("Student").Preload("Course").First(&pkg, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课时包不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, pkg.Version, h.newPackageResponse(pkg))
+}
+
+// Create 创建课时包
+// @Summary 购买课时包
+// @Tags 课时包
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param package body CreatePackageRequest true "课时包信息"
+// @Success 201 {object} PackageResponse
+// @Failure 400 {object} map[string]string
+// @Router /packages [post]
+func (h *PackageHandler) Create(c *gin.Context) {
+	var req CreatePackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	pkg := req.toModel()
+	if pkg.ExpiresAt != nil && !pkg.ExpiresAt.After(pkg.PurchasedAt) {
+		c.JSON(http.StatusBadRequest, fieldError("expires_at", "必须晚于 purchased_at"))
+		return
+	}
+	if err := h.DB.Create(&pkg).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	utils.Info("Lesson package created",
+		zap.Uint("package_id", pkg.ID),
+		zap.Uint("student_id", pkg.StudentID),
+		zap.Float64("quantity", pkg.Quantity),
+	)
+	h.DB.Preload("Student").Preload("Course").First(&pkg, pkg.ID)
+	respondVersioned(c, http.StatusCreated, pkg.Version, h.newPackageResponse(pkg))
+}
+
+// Update 更新课时包
+// @Summary 更新课时包
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；expires_at 传 null 表示不再过期；需携带 If-Match，版本不一致返回 412
+// @Tags 课时包
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "课时包ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param package body UpdatePackageRequest true "课时包信息"
+// @Success 200 {object} PackageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /packages/{id} [put]
+// @Router /packages/{id} [patch]
+func (h *PackageHandler) Update(c *gin.Context) {
+	var pkg models.LessonPackage
+	if err := h.DB.First(&pkg, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课时包不存在"})
+		return
+	}
+	if !checkIfMatch(c, pkg.Version) {
+		return
+	}
+	var req UpdatePackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	req.apply(&pkg)
+	if pkg.ExpiresAt != nil && !pkg.ExpiresAt.After(pkg.PurchasedAt) {
+		c.JSON(http.StatusBadRequest, fieldError("expires_at", "必须晚于 purchased_at"))
+		return
+	}
+	if err := saveVersioned(h.DB, &pkg, &pkg.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Student").Preload("Course").First(&pkg, pkg.ID)
+	respondVersioned(c, http.StatusOK, pkg.Version, h.newPackageResponse(pkg))
+}
+
+// Delete 删除课时包
+// @Summary 删除课时包
+// @Description 已有扣减记录的课时包不能删除
+// @Tags 课时包
+// @Security BearerAuth
+// @Param id path int true "课时包ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /packages/{id} [delete]
+func (h *PackageHandler) Delete(c *gin.Context) {
+	var pkg models.LessonPackage
+	if err := h.DB.First(&pkg, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课时包不存在"})
+		return
+	}
+	if !checkIfMatch(c, pkg.Version) {
+		return
+	}
+	var count int64
+	h.DB.Model(&models.PackageDeduction{}).Where("package_id = ? AND reversed_at IS NULL", pkg.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "课时包已有扣减记录，不能删除"})
+		return
+	}
+	if err := deleteVersioned(h.DB, &pkg, pkg.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetDeductions 获取课时包扣减记录
+// @Summary 获取课时包扣减记录
+// @Tags 课时包
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "课时包ID"
+// @Success 200 {array} models.PackageDeduction
+// @Router /packages/{id}/deductions [get]
+func (h *PackageHandler) GetDeductions(c *gin.Context) {
+	var deductions []models.PackageDeduction
+	if err := h.DB.Where("package_id = ?", c.Param("id")).Order("created_at DESC").Find(&deductions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondWithETag(c, deductions)
+}
+
+// GetStudentBalances 获取学生课时余额
+// @Summary 获取学生各科目课时余额
+// @Tags 课时包
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "学生ID"
+// @Success 200 {array} BalanceSummary
+// @Router /students/{id}/balances [get]
+func (h *PackageHandler) GetStudentBalances(c *gin.Context) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+	summaries, err := h.balanceSummaries(&student.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondWithETag(c, summaries)
+}
+
+// GetLowBalances 获取余额不足的课时包汇总
+// @Summary 获取课时余额预警（看板）
+// @Tags App接口
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} BalanceSummary
+// @Router /dashboard/low-balances [get]
+func (h *PackageHandler) GetLowBalances(c *gin.Context) {
+	summaries, err := h.balanceSummaries(nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	low := make([]BalanceSummary, 0)
+	for _, s := range summaries {
+		if s.LowBalance {
+			low = append(low, s)
+		}
+	}
+	respondWithETag(c, low)
+}
+
+// balanceSummaries 按学生、科目、计量单位汇总未过期课时包的余额
+func (h *PackageHandler) balanceSummaries(studentID *uint) ([]BalanceSummary, error) {
+	now := time.Now()
+	query := h.DB.Preload("Student").Preload("Course").
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("purchased_at ASC")
+	if studentID != nil {
+		query = query.Where("student_id = ?", *studentID)
+	}
+	var packages []models.LessonPackage
+	if err := query.Find(&packages).Error; err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		student, course uint
+		unit            string
+	}
+	index := make(map[key]*BalanceSummary)
+	order := make([]key, 0)
+	for _, p := range packages {
+		k := key{p.StudentID, p.CourseID, p.Unit}
+		s, ok := index[k]
+		if !ok {
+			s = &BalanceSummary{StudentID: p.StudentID, CourseID: p.CourseID, Unit: p.Unit, Packages: []PackageResponse{}}
+			if p.Student != nil {
+				s.StudentName = p.Student.Name
+			}
+			if p.Course != nil {
+				s.CourseName = p.Course.Name
+			}
+			index[k] = s
+			order = append(order, k)
+		}
+		s.Balance += p.Balance()
+		s.Packages = append(s.Packages, h.newPackageResponse(p))
+	}
+
+	result := make([]BalanceSummary, 0, len(order))
+	for _, k := range order {
+		s := index[k]
+		s.Balance = roundAmount(s.Balance)
+		s.LowBalance = s.Balance <= h.Billing.LowBalanceThreshold
+		result = append(result, *s)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Balance < result[j].Balance })
+	return result, nil
+}
+
+// lowBalanceCourses 返回余额不足的 (学生, 科目) 组合，用于看板标记
+// 按课时与节数分别汇总余额，不同单位不能相加；扣课时会使用任一单位的课时包，
+// 因此只有每种单位的余额都不超过阈值时才标记
+func lowBalanceCourses(db *gorm.DB, threshold float64) (map[[2]uint]bool, error) {
+	type row struct {
+		StudentID uint
+		CourseID  uint
+		Unit      string
+		Balance   float64
+	}
+	var rows []row
+	err := db.Model(&models.LessonPackage{}).
+		Select("student_id, course_id, unit, SUM(quantity - used) AS balance").
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Group("student_id, course_id, unit").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	low := make(map[[2]uint]bool)
+	for _, r := range rows {
+		k := [2]uint{r.StudentID, r.CourseID}
+		isLow := roundAmount(r.Balance) <= threshold
+		if prev, ok := low[k]; ok {
+			isLow = isLow && prev
+		}
+		low[k] = isLow
+	}
+	for k, isLow := range low {
+		if !isLow {
+			delete(low, k)
+		}
+	}
+	return low, nil
+}
+
+// syncScheduleDeduction 根据排课计费状态（已完成或迟取消）的变化扣减或冲回课时
+// before 为修改前的排课（新建时为 nil），after 为修改后的排课（删除时为 nil）
+func syncScheduleDeduction(tx *gorm.DB, billing config.BillingConfig, before, after *models.Schedule) error {
+	wasBillable := before != nil && billableFraction(*before, billing) > 0
+	isBillable := after != nil && billableFraction(*after, billing) > 0
+
+	changed := wasBillable && (!isBillable ||
+		before.Status != after.Status ||
+		before.LateCancel != after.LateCancel ||
+		before.StudentID != after.StudentID ||
+		before.CourseID != after.CourseID ||
+		!before.StartTime.Equal(after.StartTime) ||
+		!before.EndTime.Equal(after.EndTime) ||
+		scheduleHours(*before) != scheduleHours(*after))
+
+	if changed {
+		if err := reverseDeductions(tx, before.ID); err != nil {
+			return err
+		}
+	}
+	if isBillable && (!wasBillable || changed) {
+		return deductLesson(tx, after, billableFraction(*after, billing))
+	}
+	return nil
+}
+
+// deductLesson 从学生该科目的课时包中按比例扣减一次课
+// 优先使用有余额、最早过期、最早购买的课时包；没有可用课时包时不扣减
+func deductLesson(tx *gorm.DB, schedule *models.Schedule, fraction float64) error {
+	var pkg models.LessonPackage
+	err := tx.Where("student_id = ? AND course_id = ?", schedule.StudentID, schedule.CourseID).
+		Where("purchased_at <= ?", schedule.StartTime).
+		Where("expires_at IS NULL OR expires_at >= ?", schedule.StartTime).
+		Order("CASE WHEN quantity - used > 0 THEN 0 ELSE 1 END").
+		Order("CASE WHEN expires_at IS NULL THEN 1 ELSE 0 END").
+		Order("expires_at ASC").
+		Order("purchased_at ASC").
+		Order("id ASC").
+		First(&pkg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	amount := roundAmount(lessonAmount(pkg.Unit, schedule) * fraction)
+	deduction := models.PackageDeduction{PackageID: pkg.ID, ScheduleID: schedule.ID, Amount: amount}
+	if err := tx.Create(&deduction).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.LessonPackage{}).Where("id = ?", pkg.ID).Updates(map[string]interface{}{
+		"used":    gorm.Expr("used + ?", amount),
+		"version": gorm.Expr("version + 1"),
+	}).Error
+}
+
+// reverseDeductions 冲回某次排课的全部扣减
+func reverseDeductions(tx *gorm.DB, scheduleID uint) error {
+	var deductions []models.PackageDeduction
+	if err := tx.Where("schedule_id = ? AND reversed_at IS NULL", scheduleID).Find(&deductions).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, d := range deductions {
+		if err := tx.Model(&d).Update("reversed_at", now).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.LessonPackage{}).Where("id = ?", d.PackageID).Updates(map[string]interface{}{
+			"used":    gorm.Expr("used - ?", d.Amount),
+			"version": gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lessonAmount 计算一次课消耗的数量：按节数计为 1，按课时计为时长（小时）
+func lessonAmount(unit string, schedule *models.Schedule) float64 {
+	if unit == PackageUnitLesson {
+		return 1
+	}
+	return scheduleHours(*schedule)
+}
+
+// packageExpired 判断课时包在指定时间是否已过期
+func packageExpired(p models.LessonPackage, at time.Time) bool {
+	return p.ExpiresAt != nil && !p.ExpiresAt.After(at)
+}
+
+// roundAmount 保留两位小数
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
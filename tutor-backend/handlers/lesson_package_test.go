@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLowBalanceCourses(t *testing.T) {
+	past := time.Now().AddDate(0, 0, -1)
+	pkg := func(studentID, courseID uint, unit string, quantity, used float64) models.LessonPackage {
+		return models.LessonPackage{StudentID: studentID, CourseID: courseID, Unit: unit, Quantity: quantity, Used: used, PurchasedAt: past}
+	}
+	expired := pkg(1, 1, PackageUnitHour, 10, 0)
+	expired.ExpiresAt = &past
+
+	tests := []struct {
+		name     string
+		packages []models.LessonPackage
+		want     map[[2]uint]bool
+	}{
+		{"无课时包", nil, map[[2]uint]bool{}},
+		{"余额充足", []models.LessonPackage{pkg(1, 1, PackageUnitHour, 10, 5)}, map[[2]uint]bool{}},
+		{"等于阈值", []models.LessonPackage{pkg(1, 1, PackageUnitHour, 10, 8)}, map[[2]uint]bool{{1, 1}: true}},
+		{"同单位多个课时包相加", []models.LessonPackage{pkg(1, 1, PackageUnitHour, 10, 9), pkg(1, 1, PackageUnitHour, 2, 0)}, map[[2]uint]bool{}},
+		{"不同单位不相加", []models.LessonPackage{pkg(1, 1, PackageUnitHour, 2, 1), pkg(1, 1, PackageUnitLesson, 2, 0)}, map[[2]uint]bool{{1, 1}: true}},
+		{"任一单位充足即不预警", []models.LessonPackage{pkg(1, 1, PackageUnitHour, 10, 10), pkg(1, 1, PackageUnitLesson, 8, 0)}, map[[2]uint]bool{}},
+		{"过期课时包不计入", []models.LessonPackage{expired, pkg(1, 1, PackageUnitHour, 1, 0)}, map[[2]uint]bool{{1, 1}: true}},
+		{"按学生与科目分别判断", []models.LessonPackage{pkg(1, 1, PackageUnitHour, 1, 0), pkg(1, 2, PackageUnitHour, 10, 0), pkg(2, 1, PackageUnitLesson, 2, 1)},
+			map[[2]uint]bool{{1, 1}: true, {2, 1}: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.LessonPackage{})
+			for _, p := range tt.packages {
+				if err := db.Omit("Student", "Course").Create(&p).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := lowBalanceCourses(db, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lowBalanceCourses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePackageExpiresAt(t *testing.T) {
+	purchased := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	expires := purchased.AddDate(0, 6, 0)
+	later := purchased.AddDate(1, 0, 0)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		want     *time.Time
+	}{
+		{"未提供时保持不变", `{"notes":"续费"}`, http.StatusOK, &expires},
+		{"null 取消有效期", `{"expires_at":null}`, http.StatusOK, nil},
+		{"修改有效期", `{"expires_at":"` + later.Format(time.RFC3339) + `"}`, http.StatusOK, &later},
+		{"早于购买时间", `{"expires_at":"` + purchased.AddDate(0, 0, -1).Format(time.RFC3339) + `"}`, http.StatusBadRequest, &expires},
+		{"格式错误", `{"expires_at":"明年"}`, http.StatusBadRequest, &expires},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{}, &models.Course{}, &models.LessonPackage{})
+			pkg := models.LessonPackage{Version: 1, StudentID: 1, CourseID: 1, Unit: PackageUnitHour, Quantity: 10, PurchasedAt: purchased, ExpiresAt: &expires}
+			if err := db.Omit("Student", "Course").Create(&pkg).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			r := gin.New()
+			r.PATCH("/packages/:id", NewPackageHandler(db, config.BillingConfig{}).Update)
+			req := httptest.NewRequest(http.MethodPatch, "/packages/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", versionETag(1))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("PATCH = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			var got models.LessonPackage
+			db.First(&got, 1)
+			if (got.ExpiresAt == nil) != (tt.want == nil) || (got.ExpiresAt != nil && !got.ExpiresAt.Equal(*tt.want)) {
+				t.Errorf("expires_at = %v, want %v", got.ExpiresAt, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+	"tutor-management/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LessonRecordHandler struct {
+	DB      *gorm.DB
+	Storage storage.Storage // 删除课堂记录时一并删除其附件文件
+}
+
+func NewLessonRecordHandler(db *gorm.DB, store storage.Storage) *LessonRecordHandler {
+	return &LessonRecordHandler{DB: db, Storage: store}
+}
+
+// CreateLessonRecordRequest 创建课堂记录请求
+type CreateLessonRecordRequest struct {
+	ScheduleID    uint   `json:"schedule_id" binding:"required"`
+	Topics        string `json:"topics" binding:"max=2000"`
+	Homework      string `json:"homework" binding:"max=2000"`
+	TutorNotes    string `json:"tutor_notes" binding:"max=2000"`
+	Rating        int    `json:"rating" binding:"min=0,max=5"` // 0 表示未评分
+	ParentVisible bool   `json:"parent_visible"`
+}
+
+// UpdateLessonRecordRequest 更新课堂记录请求，未提供的字段保持不变
+type UpdateLessonRecordRequest struct {
+	Topics        *string `json:"topics" binding:"omitempty,max=2000"`
+	Homework      *string `json:"homework" binding:"omitempty,max=2000"`
+	TutorNotes    *string `json:"tutor_notes" binding:"omitempty,max=2000"`
+	Rating        *int    `json:"rating" binding:"omitempty,min=0,max=5"`
+	ParentVisible *bool   `json:"parent_visible"`
+}
+
+// LessonRecordResponse 课堂记录信息
+type LessonRecordResponse struct {
+	ID            uint              `json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Version       uint              `json:"version"`
+	ScheduleID    uint              `json:"schedule_id"`
+	Schedule      *ScheduleResponse `json:"schedule,omitempty"`
+	StudentID     uint              `json:"student_id"`
+	CourseID      uint              `json:"course_id"`
+	Topics        string            `json:"topics"`
+	Homework      string            `json:"homework"`
+	TutorNotes    string            `json:"tutor_notes"`
+	Rating        int               `json:"rating"`
+	ParentVisible bool              `json:"parent_visible"`
+}
+
+func (r CreateLessonRecordRequest) toModel(s models.Schedule) models.LessonRecord {
+	return models.LessonRecord{
+		Version:       1,
+		ScheduleID:    s.ID,
+		StudentID:     s.StudentID,
+		CourseID:      s.CourseID,
+		Topics:        r.Topics,
+		Homework:      r.Homework,
+		TutorNotes:    r.TutorNotes,
+		Rating:        r.Rating,
+		ParentVisible: r.ParentVisible,
+	}
+}
+
+func (r UpdateLessonRecordRequest) apply(rec *models.LessonRecord) {
+	if r.Topics != nil {
+		rec.Topics = *r.Topics
+	}
+	if r.Homework != nil {
+		rec.Homework = *r.Homework
+	}
+	if r.TutorNotes != nil {
+		rec.TutorNotes = *r.TutorNotes
+	}
+	if r.Rating != nil {
+		rec.Rating = *r.Rating
+	}
+	if r.ParentVisible != nil {
+		rec.ParentVisible = *r.ParentVisible
+	}
+}
+
+func newLessonRecordResponse(rec models.LessonRecord) LessonRecordResponse {
+	resp := LessonRecordResponse{
+		ID:            rec.ID,
+		CreatedAt:     rec.CreatedAt,
+		UpdatedAt:     rec.UpdatedAt,
+		Version:       rec.Version,
+		ScheduleID:    rec.ScheduleID,
+		StudentID:     rec.StudentID,
+		CourseID:      rec.CourseID,
+		Topics:        rec.Topics,
+		Homework:      rec.Homework,
+		TutorNotes:    rec.TutorNotes,
+		Rating:        rec.Rating,
+		ParentVisible: rec.ParentVisible,
+	}
+	if rec.Schedule != nil {
+		schedule := newScheduleResponse(*rec.Schedule)
+		resp.Schedule = &schedule
+	}
+	return resp
+}
+
+// lessonRecordListSpec 课堂记录列表允许的排序与筛选字段
+var lessonRecordListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"rating":     "rating",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "id DESC",
+	Filters: map[string]string{
+		"schedule_id": "schedule_id",
+		"student_id":  "student_id",
+		"course_id":   "course_id",
+		"rating":      "rating",
+	},
+	LikeFilters: map[string]string{"topics": "topics"},
+	Preloads:    []string{"Schedule.Student", "Schedule.Course"},
+}
+
+// GetAll 获取课堂记录列表
+// @Summary 获取课堂记录列表
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 课堂记录
+// @Security BearerAuth
+// @Produce json
+// @Param schedule_id query int false "排课ID"
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param rating query int false "评分"
+// @Param topics query string false "讲授内容（模糊匹配）"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, rating, created_at, updated_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Success 200 {array} LessonRecordResponse
+// @Failure 400 {object} map[string]string
+// @Router /lesson-records [get]
+func (h *LessonRecordHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, lessonRecordListSpec, newLessonRecordResponse)
+}
+
+// Create 创建课堂记录
+// @Summary 创建课堂记录
+// @Description 每次排课只能有一条课堂记录，已取消的课程不能填写
+// @Tags 课堂记录
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param record body CreateLessonRecordRequest true "课堂记录"
+// @Success 201 {object} LessonRecordResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /lesson-records [post]
+func (h *LessonRecordHandler) Create(c *gin.Context) {
+	var req CreateLessonRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	var schedule models.Schedule
+	if err := h.DB.First(&schedule, req.ScheduleID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("schedule_id", "排课不存在"))
+		return
+	}
+	if schedule.Status == ScheduleCancelled {
+		c.JSON(http.StatusBadRequest, fieldError("schedule_id", "已取消的课程不能填写课堂记录"))
+		return
+	}
+	var existing models.LessonRecord
+	err := h.DB.Where("schedule_id = ?", schedule.ID).First(&existing).Error
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "该课程已有课堂记录", "id": existing.ID})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	record := req.toModel(schedule)
+	if err := h.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.DB.Preload("Schedule.Student").Preload("Schedule.Course").First(&record, record.ID)
+	respondVersioned(c, http.StatusCreated, record.Version, newLessonRecordResponse(record))
+}
+
+// Get 获取课堂记录详情
+// @Summary 获取课堂记录详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 课堂记录
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "课堂记录ID"
+// @Success 200 {object} LessonRecordResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /lesson-records/{id} [get]
+func (h *LessonRecordHandler) Get(c *gin.Context) {
+	var record models.LessonRecord
+	if err := h.DB.Preload("Schedule.Student").Preload("Schedule.Course").First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课堂记录不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, record.Version, newLessonRecordResponse(record))
+}
+
+// Update 更新课堂记录
+// @Summary 更新课堂记录
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412
+// @Tags 课堂记录
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "课堂记录ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param record body UpdateLessonRecordRequest true "课堂记录"
+// @Success 200 {object} LessonRecordResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /lesson-records/{id} [put]
+// @Router /lesson-records/{id} [patch]
+func (h *LessonRecordHandler) Update(c *gin.Context) {
+	var record models.LessonRecord
+	if err := h.DB.First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课堂记录不存在"})
+		return
+	}
+	if !checkIfMatch(c, record.Version) {
+		return
+	}
+	var req UpdateLessonRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	req.apply(&record)
+	if err := saveVersioned(h.DB, &record, &record.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Schedule.Student").Preload("Schedule.Course").First(&record, record.ID)
+	respondVersioned(c, http.StatusOK, record.Version, newLessonRecordResponse(record))
+}
+
+// Delete 删除课堂记录
+// @Summary 删除课堂记录
+// @Tags 课堂记录
+// @Security BearerAuth
+// @Param id path int true "课堂记录ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Router /lesson-records/{id} [delete]
+func (h *LessonRecordHandler) Delete(c *gin.Context) {
+	var record models.LessonRecord
+	if err := h.DB.First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课堂记录不存在"})
+		return
+	}
+	if !checkIfMatch(c, record.Version) {
+		return
+	}
+	var attachments []models.Attachment
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if attachments, err = deleteOwnerAttachments(tx, AttachmentOwnerLessonRecord, []uint{record.ID}); err != nil {
+			return err
+		}
+		return deleteVersioned(tx, &record, record.Version)
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	removeAttachmentObjects(h.Storage, attachments)
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetStudentTimeline 获取学生课堂记录时间线
+// @Summary 获取学生课堂记录时间线
+// @Description 按上课时间先后返回学生的课堂记录，parent_visible=true 时仅返回对家长可见的记录
+// @Tags 课堂记录
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)"
+// @Param parent_visible query bool false "仅返回对家长可见的记录"
+// @Param order query string false "排序方向: asc（默认）, desc"
+// @Success 200 {array} LessonRecordResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /students/{id}/timeline [get]
+func (h *LessonRecordHandler) GetStudentTimeline(c *gin.Context) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+
+	query := h.DB.Preload("Schedule.Course").
+		Joins("JOIN schedules ON schedules.id = lesson_records.schedule_id").
+		Where("lesson_records.student_id = ?", student.ID)
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("lesson_records.course_id = ?", courseID)
+	}
+	if c.Query("parent_visible") == "true" {
+		query = query.Where("lesson_records.parent_visible = ?", true)
+	}
+	if raw := c.Query("start_date"); raw != "" {
+		start, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use yyyy-MM-dd"})
+			return
+		}
+		query = query.Where("schedules.start_time >= ?", start)
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		end, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use yyyy-MM-dd"})
+			return
+		}
+		query = query.Where("schedules.start_time < ?", end.AddDate(0, 0, 1))
+	}
+	order := "schedules.start_time ASC"
+	if c.Query("order") == "desc" {
+		order = "schedules.start_time DESC"
+	}
+
+	var records []models.LessonRecord
+	if err := query.Order(order).Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]LessonRecordResponse, 0, len(records))
+	for _, rec := range records {
+		result = append(result, newLessonRecordResponse(rec))
+	}
+	respondWithETag(c, result)
+}
+
+// syncLessonRecord 排课的学生或科目变更时同步课堂记录，排课删除时一并删除
+// 删除时课堂记录的附件需由调用方先用 deleteScheduleAttachments 删除
+// deleteScheduleAttachments 删除排课对应课堂记录的附件，在删除排课的事务中调用
+func deleteScheduleAttachments(tx *gorm.DB, scheduleID uint) ([]models.Attachment, error) {
+	var recordIDs []uint
+	if err := tx.Model(&models.LessonRecord{}).Where("schedule_id = ?", scheduleID).Pluck("id", &recordIDs).Error; err != nil {
+		return nil, err
+	}
+	return deleteOwnerAttachments(tx, AttachmentOwnerLessonRecord, recordIDs)
+}
+
+func syncLessonRecord(tx *gorm.DB, before, after *models.Schedule) error {
+	if before == nil {
+		return nil
+	}
+	if after == nil {
+		return tx.Where("schedule_id = ?", before.ID).Delete(&models.LessonRecord{}).Error
+	}
+	if before.StudentID == after.StudentID && before.CourseID == after.CourseID {
+		return nil
+	}
+	return tx.Model(&models.LessonRecord{}).Where("schedule_id = ?", after.ID).Updates(map[string]interface{}{
+		"student_id": after.StudentID,
+		"course_id":  after.CourseID,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+}
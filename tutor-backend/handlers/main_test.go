@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	utils.Logger = zap.NewNop()
+	if err := RegisterValidators(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestDB 创建内存数据库并迁移给定的模型，每个测试独立
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	// 内存数据库按连接隔离，限制为单个连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 补课权益状态
+const (
+	MakeupPending  = "pending"
+	MakeupRedeemed = "redeemed"
+	MakeupExpired  = "expired"
+)
+
+type MakeupHandler struct {
+	DB     *gorm.DB
+	Config config.MakeupConfig
+}
+
+func NewMakeupHandler(db *gorm.DB, cfg config.MakeupConfig) *MakeupHandler {
+	return &MakeupHandler{DB: db, Config: cfg}
+}
+
+// UpdateMakeupRequest 更新补课权益请求，可延长有效期或填写备注
+type UpdateMakeupRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Notes     *string    `json:"notes" binding:"omitempty,max=500"`
+}
+
+// MakeupResponse 补课权益信息
+type MakeupResponse struct {
+	ID                 uint              `json:"id"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+	Version            uint              `json:"version"`
+	StudentID          uint              `json:"student_id"`
+	Student            *StudentResponse  `json:"student,omitempty"`
+	CourseID           uint              `json:"course_id"`
+	Course             *CourseResponse   `json:"course,omitempty"`
+	SourceScheduleID   uint              `json:"source_schedule_id"`
+	SourceSchedule     *ScheduleResponse `json:"source_schedule,omitempty"`
+	Hours              float64           `json:"hours"`
+	Status             string            `json:"status"`
+	ExpiresAt          *time.Time        `json:"expires_at"`
+	RedeemedScheduleID *uint             `json:"redeemed_schedule_id"`
+	RedeemedAt         *time.Time        `json:"redeemed_at"`
+	Notes              string            `json:"notes"`
+}
+
+func (r UpdateMakeupRequest) apply(m *models.MakeupCredit) {
+	if r.ExpiresAt != nil {
+		m.ExpiresAt = r.ExpiresAt
+	}
+	if r.Notes != nil {
+		m.Notes = *r.Notes
+	}
+}
+
+// newMakeupResponse 已过有效期但后台任务尚未处理的权益按已失效返回
+func newMakeupResponse(m models.MakeupCredit) MakeupResponse {
+	if m.Status == MakeupPending && makeupCreditExpired(m, time.Now()) {
+		m.Status = MakeupExpired
+	}
+	resp := MakeupResponse{
+		ID:                 m.ID,
+		CreatedAt:          m.CreatedAt,
+		UpdatedAt:          m.UpdatedAt,
+		Version:            m.Version,
+		StudentID:          m.StudentID,
+		CourseID:           m.CourseID,
+		SourceScheduleID:   m.SourceScheduleID,
+		Hours:              m.Hours,
+		Status:             m.Status,
+		ExpiresAt:          m.ExpiresAt,
+		RedeemedScheduleID: m.RedeemedScheduleID,
+		RedeemedAt:         m.RedeemedAt,
+		Notes:              m.Notes,
+	}
+	if m.Student != nil {
+		student := newStudentResponse(*m.Student)
+		resp.Student = &student
+	}
+	if m.Course != nil {
+		course := newCourseResponse(*m.Course)
+		resp.Course = &course
+	}
+	return resp
+}
+
+// makeupListSpec 补课权益列表允许的排序与筛选字段
+var makeupListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"expires_at": "expires_at",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id DESC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"course_id":  "course_id",
+	},
+	Preloads: []string{"Student", "Course"},
+}
+
+// GetAll 获取补课权益列表
+// @Summary 获取补课权益列表
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组；已过有效期的待补课权益按已失效返回
+// @Tags 补课管理
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param status query string false "状态: pending, redeemed, expired"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Success 200 {array} MakeupResponse
+// @Failure 400 {object} map[string]string
+// @Router /makeups [get]
+func (h *MakeupHandler) GetAll(c *gin.Context) {
+	db := h.DB
+	if status := c.Query("status"); status != "" {
+		db = whereMakeupStatus(db, status, time.Now())
+	}
+	respondList(c, db, makeupListSpec, newMakeupResponse)
+}
+
+// GetStudentMakeups 获取学生待补课列表
+// @Summary 获取学生待补课列表
+// @Description 返回学生尚未安排补课且未失效的补课权益，按失效时间升序
+// @Tags 补课管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "学生ID"
+// @Success 200 {array} MakeupResponse
+// @Failure 404 {object} map[string]string
+// @Router /students/{id}/makeups [get]
+func (h *MakeupHandler) GetStudentMakeups(c *gin.Context) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+
+	var credits []models.MakeupCredit
+	err := whereMakeupStatus(h.DB.Preload("Course"), MakeupPending, time.Now()).
+		Where("student_id = ?", student.ID).
+		Order("CASE WHEN expires_at IS NULL THEN 1 ELSE 0 END, expires_at ASC, id ASC").
+		Find(&credits).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sourceIDs := make([]uint, 0, len(credits))
+	for _, m := range credits {
+		sourceIDs = append(sourceIDs, m.SourceScheduleID)
+	}
+	sources := make(map[uint]models.Schedule)
+	if len(sourceIDs) > 0 {
+		var schedules []models.Schedule
+		if err := h.DB.Preload("Student").Preload("Course").Where("id IN ?", sourceIDs).Find(&schedules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, s := range schedules {
+			sources[s.ID] = s
+		}
+	}
+
+	result := make([]MakeupResponse, 0, len(credits))
+	for _, m := range credits {
+		resp := newMakeupResponse(m)
+		if s, ok := sources[m.SourceScheduleID]; ok {
+			source := newScheduleResponse(s)
+			resp.SourceSchedule = &source
+		}
+		result = append(result, resp)
+	}
+	respondWithETag(c, result)
+}
+
+// Update 更新补课权益
+// @Summary 更新补课权益（延期、备注）
+// @Description 延长已失效权益的有效期会使其恢复为待补课；需携带 If-Match
+// @Tags 补课管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "补课权益ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param makeup body UpdateMakeupRequest true "补课权益信息"
+// @Success 200 {object} MakeupResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /makeups/{id} [patch]
+func (h *MakeupHandler) Update(c *gin.Context) {
+	var credit models.MakeupCredit
+	if err := h.DB.First(&credit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "补课权益不存在"})
+		return
+	}
+	if !checkIfMatch(c, credit.Version) {
+		return
+	}
+	var req UpdateMakeupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	req.apply(&credit)
+	if credit.Status == MakeupExpired && (credit.ExpiresAt == nil || credit.ExpiresAt.After(time.Now())) {
+		credit.Status = MakeupPending
+	}
+	if err := saveVersioned(h.DB, &credit, &credit.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Student").Preload("Course").First(&credit, credit.ID)
+	respondVersioned(c, http.StatusOK, credit.Version, newMakeupResponse(credit))
+}
+
+// Delete 放弃补课权益
+// @Summary 放弃补课权益
+// @Description 已补课的权益不能删除
+// @Tags 补课管理
+// @Security BearerAuth
+// @Param id path int true "补课权益ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /makeups/{id} [delete]
+func (h *MakeupHandler) Delete(c *gin.Context) {
+	var credit models.MakeupCredit
+	if err := h.DB.First(&credit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "补课权益不存在"})
+		return
+	}
+	if !checkIfMatch(c, credit.Version) {
+		return
+	}
+	if credit.Status == MakeupRedeemed {
+		c.JSON(http.StatusConflict, gin.H{"error": "补课权益已使用，不能删除"})
+		return
+	}
+	if err := deleteVersioned(h.DB, &credit, credit.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// checkMakeupCredit 校验新建补课时指定的补课权益；失败时已写出 400 响应
+func checkMakeupCredit(c *gin.Context, db *gorm.DB, s models.Schedule) bool {
+	var credit models.MakeupCredit
+	if err := db.First(&credit, *s.MakeupCreditID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, fieldError("makeup_credit_id", "补课权益不存在"))
+		return false
+	}
+	switch {
+	case credit.StudentID != s.StudentID:
+		c.JSON(http.StatusBadRequest, fieldError("makeup_credit_id", "补课权益不属于该学生"))
+	case credit.CourseID != s.CourseID:
+		c.JSON(http.StatusBadRequest, fieldError("makeup_credit_id", "补课权益与课程不一致"))
+	case credit.Status != MakeupPending || makeupCreditExpired(credit, time.Now()):
+		c.JSON(http.StatusBadRequest, fieldError("makeup_credit_id", "补课权益已使用或已失效"))
+	default:
+		return true
+	}
+	return false
+}
+
+// syncMakeupCredit 根据排课的取消状态维护补课权益
+// 未计费的取消生成补课权益；补课本身被取消（未计费）或删除时归还所用权益
+func syncMakeupCredit(tx *gorm.DB, cfg config.MakeupConfig, before, after *models.Schedule) error {
+	if err := syncMakeupRedemption(tx, before, after); err != nil {
+		return err
+	}
+
+	wasOwed := before != nil && makeupOwed(*before)
+	isOwed := after != nil && makeupOwed(*after)
+	switch {
+	case isOwed && !wasOwed:
+		credit := models.MakeupCredit{
+			Version:          1,
+			StudentID:        after.StudentID,
+			CourseID:         after.CourseID,
+			SourceScheduleID: after.ID,
+			Hours:            scheduleHours(*after),
+			Status:           MakeupPending,
+		}
+		if cfg.ExpiryDays > 0 {
+			expiresAt := after.StartTime.AddDate(0, 0, cfg.ExpiryDays)
+			credit.ExpiresAt = &expiresAt
+		}
+		if err := tx.Create(&credit).Error; err != nil {
+			return err
+		}
+		utils.Info("Makeup credit created",
+			zap.Uint("credit_id", credit.ID),
+			zap.Uint("schedule_id", after.ID),
+			zap.Uint("student_id", after.StudentID),
+		)
+	case wasOwed && !isOwed:
+		var credit models.MakeupCredit
+		err := tx.Where("source_schedule_id = ?", before.ID).First(&credit).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if credit.Status == MakeupRedeemed {
+			return conflictError{"该课程的补课已安排，不能撤销取消或删除"}
+		}
+		return tx.Delete(&credit).Error
+	}
+	return nil
+}
+
+// syncMakeupRedemption 核销或归还补课排课使用的补课权益
+func syncMakeupRedemption(tx *gorm.DB, before, after *models.Schedule) error {
+	var beforeID, afterID *uint
+	if before != nil && before.MakeupCreditID != nil && !makeupReleased(*before) {
+		beforeID = before.MakeupCreditID
+	}
+	if after != nil && after.MakeupCreditID != nil && !makeupReleased(*after) {
+		afterID = after.MakeupCreditID
+	}
+	if beforeID != nil && afterID != nil && *beforeID == *afterID {
+		return nil
+	}
+
+	if beforeID != nil {
+		err := tx.Model(&models.MakeupCredit{}).
+			Where("id = ? AND status = ?", *beforeID, MakeupRedeemed).
+			Updates(map[string]interface{}{
+				"status":               MakeupPending,
+				"redeemed_schedule_id": nil,
+				"redeemed_at":          nil,
+				"version":              gorm.Expr("version + 1"),
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if afterID != nil {
+		res := tx.Model(&models.MakeupCredit{}).
+			Where("id = ? AND status = ?", *afterID, MakeupPending).
+			Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+			Updates(map[string]interface{}{
+				"status":               MakeupRedeemed,
+				"redeemed_schedule_id": after.ID,
+				"redeemed_at":          time.Now(),
+				"version":              gorm.Expr("version + 1"),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return conflictError{"补课权益已使用或已失效"}
+		}
+	}
+	return nil
+}
+
+// RunExpiry 定期将超过有效期仍未使用的补课权益标记为已失效，ctx 取消后退出
+func (h *MakeupHandler) RunExpiry(ctx context.Context) {
+	interval := time.Duration(h.Config.JobIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := expireMakeupCredits(h.DB, time.Now())
+		if err != nil {
+			utils.Error("Failed to expire makeup credits", zap.Error(err))
+		} else if count > 0 {
+			utils.Info("Makeup credits expired", zap.Int64("count", count))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireMakeupCredits 将截至 now 已过有效期仍未使用的补课权益标记为已失效，返回处理数量
+func expireMakeupCredits(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Model(&models.MakeupCredit{}).
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", MakeupPending, now).
+		Updates(map[string]interface{}{
+			"status":  MakeupExpired,
+			"version": gorm.Expr("version + 1"),
+		})
+	return res.RowsAffected, res.Error
+}
+
+// whereMakeupStatus 按状态筛选补课权益，已过有效期但尚未被后台任务处理的待补课权益算作已失效
+func whereMakeupStatus(db *gorm.DB, status string, now time.Time) *gorm.DB {
+	switch status {
+	case MakeupPending:
+		return db.Where("status = ? AND (expires_at IS NULL OR expires_at > ?)", MakeupPending, now)
+	case MakeupExpired:
+		return db.Where("status = ? OR (status = ? AND expires_at <= ?)", MakeupExpired, MakeupPending, now)
+	default:
+		return db.Where("status = ?", status)
+	}
+}
+
+// makeupOwed 排课被取消且未计费（迟取消已收费，不再补课），补课本身被取消时归还原权益而不是新增
+func makeupOwed(s models.Schedule) bool {
+	return makeupReleased(s) && s.MakeupCreditID == nil
+}
+
+// makeupReleased 排课处于未计费的取消状态
+func makeupReleased(s models.Schedule) bool {
+	return s.Status == ScheduleCancelled && !s.LateCancel
+}
+
+// makeupCreditExpired 判断补课权益在指定时间是否已过期
+func makeupCreditExpired(m models.MakeupCredit, at time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(at)
+}
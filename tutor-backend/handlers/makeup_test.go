@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMakeupExpiry(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	credits := []models.MakeupCredit{
+		{ID: 1, Status: MakeupPending, ExpiresAt: &future},
+		{ID: 2, Status: MakeupPending, ExpiresAt: &past}, // 已过期，后台任务尚未处理
+		{ID: 3, Status: MakeupPending},
+		{ID: 4, Status: MakeupExpired, ExpiresAt: &past},
+		{ID: 5, Status: MakeupRedeemed, ExpiresAt: &past},
+	}
+	newDB := func(t *testing.T) *MakeupHandler {
+		db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Schedule{}, &models.MakeupCredit{})
+		db.Create(&models.Student{Name: "张三"})
+		for _, m := range credits {
+			m.Version, m.StudentID, m.CourseID, m.SourceScheduleID, m.Hours = 1, 1, 1, m.ID, 2
+			if err := db.Omit("Student", "Course").Create(&m).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		return NewMakeupHandler(db, config.MakeupConfig{})
+	}
+
+	t.Run("查询不写库", func(t *testing.T) {
+		tests := []struct {
+			name string
+			path string
+			want map[uint]string
+		}{
+			{"全部", "/makeups", map[uint]string{1: MakeupPending, 2: MakeupExpired, 3: MakeupPending, 4: MakeupExpired, 5: MakeupRedeemed}},
+			{"待补课", "/makeups?status=pending", map[uint]string{1: MakeupPending, 3: MakeupPending}},
+			{"已失效", "/makeups?status=expired", map[uint]string{2: MakeupExpired, 4: MakeupExpired}},
+			{"已补课", "/makeups?status=redeemed", map[uint]string{5: MakeupRedeemed}},
+			{"学生待补课", "/students/1/makeups", map[uint]string{1: MakeupPending, 3: MakeupPending}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				h := newDB(t)
+				r := gin.New()
+				r.GET("/makeups", h.GetAll)
+				r.GET("/students/:id/makeups", h.GetStudentMakeups)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("GET %s = %d: %s", tt.path, w.Code, w.Body.String())
+				}
+				var got []MakeupResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != len(tt.want) {
+					t.Errorf("got %d credits, want %d", len(got), len(tt.want))
+				}
+				for _, m := range got {
+					if want, ok := tt.want[m.ID]; !ok || m.Status != want {
+						t.Errorf("credit %d status = %q, want %q", m.ID, m.Status, want)
+					}
+				}
+				var stored models.MakeupCredit
+				h.DB.First(&stored, 2)
+				if stored.Status != MakeupPending || stored.Version != 1 {
+					t.Errorf("GET modified credit 2: status %q version %d", stored.Status, stored.Version)
+				}
+			})
+		}
+	})
+
+	t.Run("后台任务标记失效", func(t *testing.T) {
+		h := newDB(t)
+		count, err := expireMakeupCredits(h.DB, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("expired %d credits, want 1", count)
+		}
+		var stored models.MakeupCredit
+		h.DB.First(&stored, 2)
+		if stored.Status != MakeupExpired || stored.Version != 2 {
+			t.Errorf("credit 2: status %q version %d, want expired version 2", stored.Status, stored.Version)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NullableTime 可清空的时间字段，用于 PATCH 请求区分“未提供”与显式的 null：
+// 未提供时 Set 为 false；提供时 Set 为 true，值为 null 时 Value 为 nil
+type NullableTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (n *NullableTime) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var t time.Time
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	n.Value = &t
+	return nil
+}
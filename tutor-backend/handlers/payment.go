@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type PaymentHandler struct {
+	DB *gorm.DB
+}
+
+func NewPaymentHandler(db *gorm.DB) *PaymentHandler {
+	return &PaymentHandler{DB: db}
+}
+
+// CreatePaymentRequest 登记收款请求
+type CreatePaymentRequest struct {
+	StudentID uint       `json:"student_id" binding:"required"`
+	InvoiceID *uint      `json:"invoice_id" binding:"omitempty,gt=0"`
+	Amount    float64    `json:"amount" binding:"required,gt=0"`
+	Method    string     `json:"method" binding:"required,oneof=cash wechat alipay"`
+	PaidAt    *time.Time `json:"paid_at"`
+	Reference string     `json:"reference" binding:"max=100"`
+	Notes     string     `json:"notes" binding:"max=500"`
+}
+
+// PaymentResponse 收款信息
+type PaymentResponse struct {
+	ID        uint             `json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Version   uint             `json:"version"`
+	StudentID uint             `json:"student_id"`
+	Student   *StudentResponse `json:"student,omitempty"`
+	InvoiceID *uint            `json:"invoice_id"`
+	Amount    float64          `json:"amount"`
+	Method    string           `json:"method"`
+	PaidAt    time.Time        `json:"paid_at"`
+	Reference string           `json:"reference"`
+	Notes     string           `json:"notes"`
+}
+
+func (r CreatePaymentRequest) toModel() models.Payment {
+	paidAt := time.Now()
+	if r.PaidAt != nil {
+		paidAt = *r.PaidAt
+	}
+	return models.Payment{
+		Version:   1,
+		StudentID: r.StudentID,
+		InvoiceID: r.InvoiceID,
+		Amount:    roundAmount(r.Amount),
+		Method:    r.Method,
+		PaidAt:    paidAt,
+		Reference: r.Reference,
+		Notes:     r.Notes,
+	}
+}
+
+func newPaymentResponse(p models.Payment) PaymentResponse {
+	resp := PaymentResponse{
+		ID:        p.ID,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Version:   p.Version,
+		StudentID: p.StudentID,
+		InvoiceID: p.InvoiceID,
+		Amount:    p.Amount,
+		Method:    p.Method,
+		PaidAt:    p.PaidAt,
+		Reference: p.Reference,
+		Notes:     p.Notes,
+	}
+	if p.Student != nil {
+		student := newStudentResponse(*p.Student)
+		resp.Student = &student
+	}
+	return resp
+}
+
+// paymentListSpec 收款列表允许的排序与筛选字段
+var paymentListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":      "id",
+		"paid_at": "paid_at",
+		"amount":  "amount",
+	},
+	DefaultSort: "paid_at DESC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"invoice_id": "invoice_id",
+		"method":     "method",
+	},
+	DateRange: "paid_at",
+	Preloads:  []string{"Student"},
+}
+
+// GetAll 获取收款流水
+// @Summary 获取收款流水
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce json
+// @Param student_id query int false "学生ID"
+// @Param invoice_id query int false "账单ID"
+// @Param method query string false "收款方式: cash, wechat, alipay"
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Success 200 {array} PaymentResponse
+// @Failure 400 {object} map[string]string
+// @Router /payments [get]
+func (h *PaymentHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, paymentListSpec, newPaymentResponse)
+}
+
+// Create 登记收款
+// @Summary 登记收款（现金、微信、支付宝）
+// @Description 指定 invoice_id 时计入该账单，账单须为 issued 或 paid 状态且属于同一学生；收款覆盖应收后账单自动标记为 paid。不指定时作为预收款计入家庭余额
+// @Tags 收费管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payment body CreatePaymentRequest true "收款信息"
+// @Success 201 {object} PaymentResponse
+// @Failure 400 {object} map[string]string
+// @Router /payments [post]
+func (h *PaymentHandler) Create(c *gin.Context) {
+	var req CreatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	payment := req.toModel()
+	if payment.InvoiceID != nil {
+		var inv models.Invoice
+		if err := h.DB.First(&inv, *payment.InvoiceID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, fieldError("invoice_id", "账单不存在"))
+			return
+		}
+		if inv.StudentID != payment.StudentID {
+			c.JSON(http.StatusBadRequest, fieldError("invoice_id", "账单不属于该学生"))
+			return
+		}
+		if inv.Status != InvoiceIssued && inv.Status != InvoicePaid {
+			c.JSON(http.StatusBadRequest, fieldError("invoice_id", "只能为已开具的账单登记收款"))
+			return
+		}
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		if payment.InvoiceID != nil {
+			return refreshInvoicePaid(tx, *payment.InvoiceID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	utils.Info("Payment recorded",
+		zap.Uint("payment_id", payment.ID),
+		zap.Uint("student_id", payment.StudentID),
+		zap.Float64("amount", payment.Amount),
+		zap.String("method", payment.Method),
+	)
+	h.DB.Preload("Student").First(&payment, payment.ID)
+	respondVersioned(c, http.StatusCreated, payment.Version, newPaymentResponse(payment))
+}
+
+// Delete 删除收款记录
+// @Summary 删除收款记录（冲正）
+// @Description 关联账单的已收金额随之更新，不足应收时账单退回 issued
+// @Tags 收费管理
+// @Security BearerAuth
+// @Param id path int true "收款ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /payments/{id} [delete]
+func (h *PaymentHandler) Delete(c *gin.Context) {
+	var payment models.Payment
+	if err := h.DB.First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "收款记录不存在"})
+		return
+	}
+	if !checkIfMatch(c, payment.Version) {
+		return
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteVersioned(tx, &payment, payment.Version); err != nil {
+			return err
+		}
+		if payment.InvoiceID != nil {
+			return refreshInvoicePaid(tx, *payment.InvoiceID)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	utils.Info("Payment deleted", zap.Uint("payment_id", payment.ID))
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+// ListSpec 描述列表接口允许的排序字段与筛选字段
+type ListSpec struct {
+	// SortFields 排序白名单：查询参数名 -> 数据库列
+	SortFields map[string]string
+	// DefaultSort 未指定排序时使用的 ORDER BY
+	DefaultSort string
+	// Filters 精确匹配筛选：查询参数名 -> 数据库列
+	Filters map[string]string
+	// LikeFilters 模糊匹配筛选：查询参数名 -> 数据库列
+	LikeFilters map[string]string
+	// DateRange 按日期范围筛选的列（start_date / end_date，yyyy-MM-dd）
+	DateRange string
+	// Preloads 需要预加载的关联
+	Preloads []string
+}
+
+// ListQuery 解析后的分页与排序参数
+type ListQuery struct {
+	Paged     bool
+	UseCursor bool
+	Page      int
+	PageSize  int
+	Cursor    uint
+	Sort      string
+}
+
+// PageResult 分页响应，字段与 ProTable 的 request 返回值保持一致
+type PageResult struct {
+	Data       interface{} `json:"data"`
+	Total      int64       `json:"total"`
+	Success    bool        `json:"success"`
+	Current    int         `json:"current,omitempty"`
+	PageSize   int         `json:"pageSize"`
+	NextCursor *uint       `json:"next_cursor,omitempty"`
+}
+
+// ParseListQuery 解析分页、游标与排序参数
+// 同时兼容 page/page_size 与 ProTable 默认的 current/pageSize
+func ParseListQuery(c *gin.Context, spec ListSpec) (ListQuery, error) {
+	q := ListQuery{Page: 1, PageSize: defaultPageSize, Sort: spec.DefaultSort}
+
+	page := firstQuery(c, "page", "current")
+	size := firstQuery(c, "page_size", "pageSize")
+	cursor := c.Query("cursor")
+	q.Paged = page != "" || size != "" || cursor != ""
+
+	if page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("无效的页码: %s", page)
+		}
+		q.Page = n
+	}
+	if size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("无效的每页条数: %s", size)
+		}
+		if n > maxPageSize {
+			n = maxPageSize
+		}
+		q.PageSize = n
+	}
+	if cursor != "" {
+		n, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("无效的游标: %s", cursor)
+		}
+		q.Cursor = uint(n)
+		q.UseCursor = true
+	}
+
+	// sort=name&order=desc 或 sort=-name
+	if sort := c.Query("sort"); sort != "" {
+		desc := strings.HasPrefix(sort, "-")
+		sort = strings.TrimPrefix(sort, "-")
+		column, ok := spec.SortFields[sort]
+		if !ok {
+			return q, fmt.Errorf("不支持的排序字段: %s", sort)
+		}
+		switch strings.ToLower(c.Query("order")) {
+		case "", "asc", "ascend":
+		case "desc", "descend":
+			desc = true
+		default:
+			return q, fmt.Errorf("无效的排序方向: %s", c.Query("order"))
+		}
+		q.Sort = column + " ASC"
+		if desc {
+			q.Sort = column + " DESC"
+		}
+	}
+
+	return q, nil
+}
+
+// ApplyFilters 根据 ListSpec 将查询参数中的筛选条件应用到查询上
+func (spec ListSpec) ApplyFilters(c *gin.Context, db *gorm.DB) (*gorm.DB, error) {
+	for param, column := range spec.Filters {
+		if v := c.Query(param); v != "" {
+			db = db.Where(column+" = ?", v)
+		}
+	}
+	for param, column := range spec.LikeFilters {
+		if v := c.Query(param); v != "" {
+			db = db.Where(column+" LIKE ? ESCAPE '!'", "%"+escapeLike(v)+"%")
+		}
+	}
+	if spec.DateRange != "" {
+		if v := c.Query("start_date"); v != "" {
+			t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("无效的开始日期: %s", v)
+			}
+			db = db.Where(spec.DateRange+" >= ?", t)
+		}
+		if v := c.Query("end_date"); v != "" {
+			t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("无效的结束日期: %s", v)
+			}
+			// 结束日期加一天，包含当天
+			db = db.Where(spec.DateRange+" < ?", t.Add(24*time.Hour))
+		}
+	}
+	return db, nil
+}
+
+// escapeLike 转义 LIKE 通配符，关键词中的 % 与 _ 按字面匹配（配合 ESCAPE '!'）
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// respondList 执行列表查询，经 toResponse 转换后写出响应
+// 未携带分页参数时返回完整数组（兼容旧客户端），否则返回 PageResult
+// 返回本次写出的条数；查询失败写出 500 时同时返回错误，便于调用方记录日志，参数错误写出 400 时返回 nil
+func respondList[T any, R any](c *gin.Context, db *gorm.DB, spec ListSpec, toResponse func(T) R) (int, error) {
+	return respondListBatch(c, db, spec, func(items []T) ([]R, error) {
+		data := make([]R, 0, len(items))
+		for _, item := range items {
+			data = append(data, toResponse(item))
+		}
+		return data, nil
+	})
+}
+
+// respondListBatch 与 respondList 相同，但整页一起转换，便于批量读取关联数据；转换出错时返回 500
+func respondListBatch[T any, R any](c *gin.Context, db *gorm.DB, spec ListSpec, toResponses func([]T) ([]R, error)) (int, error) {
+	q, err := ParseListQuery(c, spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, nil
+	}
+
+	var model T
+	query, err := spec.ApplyFilters(c, db.Model(&model))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, nil
+	}
+
+	var total int64
+	if q.Paged {
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return 0, err
+		}
+	}
+
+	for _, p := range spec.Preloads {
+		query = query.Preload(p)
+	}
+
+	switch {
+	case q.UseCursor:
+		// 游标分页按 ID 倒序，忽略自定义排序
+		query = query.Order("id DESC").Limit(q.PageSize)
+		if q.Cursor > 0 {
+			query = query.Where("id < ?", q.Cursor)
+		}
+	case q.Paged:
+		query = query.Order(q.Sort).Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize)
+	default:
+		query = query.Order(q.Sort)
+	}
+
+	items := make([]T, 0)
+	if err := query.Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, err
+	}
+
+	data, err := toResponses(items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, err
+	}
+
+	if !q.Paged {
+		respondWithETag(c, data)
+		return len(data), nil
+	}
+
+	result := PageResult{
+		Data:     data,
+		Total:    total,
+		Success:  true,
+		PageSize: q.PageSize,
+	}
+	if q.UseCursor {
+		if len(items) == q.PageSize {
+			next := itemID(items[len(items)-1])
+			result.NextCursor = &next
+		}
+	} else {
+		result.Current = q.Page
+	}
+	respondWithETag(c, result)
+	return len(data), nil
+}
+
+// firstQuery 返回第一个非空的查询参数
+func firstQuery(c *gin.Context, keys ...string) string {
+	for _, k := range keys {
+		if v := c.Query(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// itemID 读取模型的 ID 字段，用于生成下一页游标
+func itemID(item interface{}) uint {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	if f := v.FieldByName("ID"); f.IsValid() && f.CanUint() {
+		return uint(f.Uint())
+	}
+	return 0
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 报表分组维度
+const (
+	GroupByMonth   = "month"
+	GroupByStudent = "student"
+	GroupByCourse  = "course"
+	GroupByWeekday = "weekday"
+)
+
+var weekdayLabels = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+type ReportHandler struct {
+	DB *gorm.DB
+}
+
+func NewReportHandler(db *gorm.DB) *ReportHandler {
+	return &ReportHandler{DB: db}
+}
+
+// ReportRow 报表中的一行，Prev* 为上一年同期数据
+type ReportRow struct {
+	Key          string   `json:"key"`
+	Label        string   `json:"label"`
+	Lessons      int      `json:"lessons"`
+	Hours        float64  `json:"hours"`
+	Amount       float64  `json:"amount"`
+	PrevLessons  int      `json:"prev_lessons"`
+	PrevHours    float64  `json:"prev_hours"`
+	PrevAmount   float64  `json:"prev_amount"`
+	HoursChange  *float64 `json:"hours_change"`  // 课时同比变化（%），上年同期为 0 时为空
+	AmountChange *float64 `json:"amount_change"` // 收入同比变化（%），上年同期为 0 时为空
+	sortKey      int
+}
+
+// Report 报表结果
+type Report struct {
+	StartDate string      `json:"start_date"`
+	EndDate   string      `json:"end_date"`
+	GroupBy   string      `json:"group_by"`
+	Rows      []ReportRow `json:"rows"`
+	Total     ReportRow   `json:"total"`
+}
+
+// reportPeriod 报表统计区间 [start, end)
+type reportPeriod struct {
+	start, end time.Time
+}
+
+// GetRevenue 收入报表
+// @Summary 收入报表
+// @Description 按月份、学生、课程或星期汇总已开具（issued/paid）账单中的课程金额（含迟取消费用），金额计入对应上课日期，并与上一年同期对比
+// @Tags 统计报表
+// @Security BearerAuth
+// @Produce json
+// @Param group_by query string false "分组维度: month, student, course, weekday，默认 month"
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)，默认今年 1 月 1 日"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)，默认今天"
+// @Param format query string false "输出格式: json, csv, xlsx"
+// @Success 200 {object} Report
+// @Failure 400 {object} map[string]string
+// @Router /reports/revenue [get]
+func (h *ReportHandler) GetRevenue(c *gin.Context) {
+	h.respondReport(c, "收入报表", []string{"收入", "上年同期收入", "同比(%)"}, func(r ReportRow) []interface{} {
+		return []interface{}{r.Amount, r.PrevAmount, r.AmountChange}
+	})
+}
+
+// GetWorkload 工作量报表
+// @Summary 工作量报表
+// @Description 按月份、学生、课程或星期汇总已完成排课的节数与时长，并与上一年同期对比
+// @Tags 统计报表
+// @Security BearerAuth
+// @Produce json
+// @Param group_by query string false "分组维度: month, student, course, weekday，默认 month"
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)，默认今年 1 月 1 日"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)，默认今天"
+// @Param format query string false "输出格式: json, csv, xlsx"
+// @Success 200 {object} Report
+// @Failure 400 {object} map[string]string
+// @Router /reports/workload [get]
+func (h *ReportHandler) GetWorkload(c *gin.Context) {
+	h.respondReport(c, "工作量报表", []string{"节数", "课时", "上年同期节数", "上年同期课时", "同比(%)"}, func(r ReportRow) []interface{} {
+		return []interface{}{r.Lessons, r.Hours, r.PrevLessons, r.PrevHours, r.HoursChange}
+	})
+}
+
+// CancellationRow 学生在统计区间内的取消情况
+type CancellationRow struct {
+	StudentID   uint     `json:"student_id"`
+	StudentName string   `json:"student_name"`
+	Lessons     int      `json:"lessons"`   // 排课总数（含取消）
+	Cancelled   int      `json:"cancelled"` // 取消次数
+	ByStudent   int      `json:"by_student"`
+	ByParent    int      `json:"by_parent"`
+	ByTutor     int      `json:"by_tutor"`
+	LateCancels int      `json:"late_cancels"` // 按政策计费的迟取消次数
+	CancelRate  *float64 `json:"cancel_rate"`  // 取消率（%）
+}
+
+// CancellationReport 取消统计结果
+type CancellationReport struct {
+	StartDate string            `json:"start_date"`
+	EndDate   string            `json:"end_date"`
+	Rows      []CancellationRow `json:"rows"`
+	Total     CancellationRow   `json:"total"`
+}
+
+// GetCancellations 取消统计
+// @Summary 按学生统计课程取消
+// @Description 统计区间内每个学生的排课数、取消次数（按取消方区分）、迟取消次数与取消率，按取消次数倒序
+// @Tags 统计报表
+// @Security BearerAuth
+// @Produce json
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)，默认今年 1 月 1 日"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)，默认今天"
+// @Param student_id query int false "学生ID"
+// @Param format query string false "输出格式: json, csv, xlsx"
+// @Success 200 {object} CancellationReport
+// @Failure 400 {object} map[string]string
+// @Router /reports/cancellations [get]
+func (h *ReportHandler) GetCancellations(c *gin.Context) {
+	format, ok := parseExportFormat(c)
+	if !ok {
+		return
+	}
+	period, err := parseReportPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.DB.Preload("Student").
+		Where("start_time >= ? AND start_time < ?", period.start, period.end).
+		Order("student_id ASC")
+	if id := c.Query("student_id"); id != "" {
+		query = query.Where("student_id = ?", id)
+	}
+	var schedules []models.Schedule
+	if err := query.Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := CancellationReport{
+		StartDate: period.start.Format("2006-01-02"),
+		EndDate:   period.end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Rows:      make([]CancellationRow, 0),
+		Total:     CancellationRow{StudentName: "合计"},
+	}
+	index := make(map[uint]int)
+	for _, s := range schedules {
+		i, ok := index[s.StudentID]
+		if !ok {
+			i = len(report.Rows)
+			index[s.StudentID] = i
+			report.Rows = append(report.Rows, CancellationRow{StudentID: s.StudentID, StudentName: s.Student.Name})
+		}
+		for _, row := range []*CancellationRow{&report.Rows[i], &report.Total} {
+			row.Lessons++
+			if s.Status != ScheduleCancelled {
+				continue
+			}
+			row.Cancelled++
+			switch s.CancelledBy {
+			case "student":
+				row.ByStudent++
+			case "parent":
+				row.ByParent++
+			case "tutor":
+				row.ByTutor++
+			}
+			if s.LateCancel {
+				row.LateCancels++
+			}
+		}
+	}
+	for i := range report.Rows {
+		report.Rows[i].CancelRate = percentOf(report.Rows[i].Cancelled, report.Rows[i].Lessons)
+	}
+	report.Total.CancelRate = percentOf(report.Total.Cancelled, report.Total.Lessons)
+	sort.SliceStable(report.Rows, func(i, j int) bool { return report.Rows[i].Cancelled > report.Rows[j].Cancelled })
+
+	if format == FormatJSON {
+		respondWithETag(c, report)
+		return
+	}
+	table := tableExport{
+		Filename: fmt.Sprintf("取消统计-%s-%s", report.StartDate, report.EndDate),
+		Sheet:    "取消统计",
+		Headers:  []string{"学生", "排课数", "取消次数", "学生取消", "家长取消", "老师取消", "迟取消", "取消率(%)"},
+	}
+	for _, r := range append(report.Rows, report.Total) {
+		table.Rows = append(table.Rows, []interface{}{
+			r.StudentName, r.Lessons, r.Cancelled, r.ByStudent, r.ByParent, r.ByTutor, r.LateCancels, r.CancelRate,
+		})
+	}
+	respondTable(c, format, table)
+}
+
+// respondReport 解析参数、生成报表并按 format 写出；columns/values 决定 CSV/XLSX 的指标列
+func (h *ReportHandler) respondReport(c *gin.Context, title string, columns []string, values func(ReportRow) []interface{}) {
+	format, ok := parseExportFormat(c)
+	if !ok {
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", GroupByMonth)
+	switch groupBy {
+	case GroupByMonth, GroupByStudent, GroupByCourse, GroupByWeekday:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的分组维度: " + groupBy})
+		return
+	}
+	period, err := parseReportPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.buildReport(groupBy, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if format == FormatJSON {
+		respondWithETag(c, report)
+		return
+	}
+
+	table := tableExport{
+		Filename: fmt.Sprintf("%s-%s-%s", title, report.StartDate, report.EndDate),
+		Sheet:    title,
+		Headers:  append([]string{"分组"}, columns...),
+	}
+	for _, r := range append(report.Rows, report.Total) {
+		table.Rows = append(table.Rows, append([]interface{}{r.Label}, values(r)...))
+	}
+	respondTable(c, format, table)
+}
+
+// buildReport 汇总当期与上一年同期的课时和收入
+func (h *ReportHandler) buildReport(groupBy string, period reportPeriod) (Report, error) {
+	previous := reportPeriod{start: period.start.AddDate(-1, 0, 0), end: period.end.AddDate(-1, 0, 0)}
+	rows := make(map[string]*ReportRow)
+
+	for _, p := range []struct {
+		period   reportPeriod
+		current  bool
+		shiftYrs int
+	}{{period, true, 0}, {previous, false, 1}} {
+		var schedules []models.Schedule
+		err := h.DB.Preload("Student").Preload("Course").
+			Where("status = ? OR (status = ? AND late_cancel = ?)", ScheduleCompleted, ScheduleCancelled, true).
+			Where("start_time >= ? AND start_time < ?", p.period.start, p.period.end).
+			Find(&schedules).Error
+		if err != nil {
+			return Report{}, err
+		}
+
+		// 收入按排课日期归属，仅统计已开具或已支付的账单
+		type itemRow struct {
+			ScheduleID uint
+			Amount     float64
+		}
+		var items []itemRow
+		err = h.DB.Model(&models.InvoiceItem{}).
+			Select("invoice_items.schedule_id, invoice_items.amount").
+			Joins("JOIN invoices ON invoices.id = invoice_items.invoice_id").
+			Joins("JOIN schedules ON schedules.id = invoice_items.schedule_id").
+			Where("invoices.status IN ?", []string{InvoiceIssued, InvoicePaid}).
+			Where("schedules.start_time >= ? AND schedules.start_time < ?", p.period.start, p.period.end).
+			Scan(&items).Error
+		if err != nil {
+			return Report{}, err
+		}
+		amounts := make(map[uint]float64, len(items))
+		for _, item := range items {
+			amounts[item.ScheduleID] += item.Amount
+		}
+
+		for _, s := range schedules {
+			key, label, order := reportKey(groupBy, s, p.shiftYrs)
+			row, ok := rows[key]
+			if !ok {
+				row = &ReportRow{Key: key, Label: label, sortKey: order}
+				rows[key] = row
+			}
+			// 迟取消只计入收入，不计入节数与课时
+			completed := s.Status == ScheduleCompleted
+			if p.current {
+				row.Amount += amounts[s.ID]
+				if completed {
+					row.Lessons++
+					row.Hours += scheduleHours(s)
+				}
+			} else {
+				row.PrevAmount += amounts[s.ID]
+				if completed {
+					row.PrevLessons++
+					row.PrevHours += scheduleHours(s)
+				}
+			}
+		}
+	}
+
+	report := Report{
+		StartDate: period.start.Format("2006-01-02"),
+		EndDate:   period.end.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy:   groupBy,
+		Rows:      make([]ReportRow, 0, len(rows)),
+		Total:     ReportRow{Key: "total", Label: "合计"},
+	}
+	for _, row := range rows {
+		report.Total.Lessons += row.Lessons
+		report.Total.Hours += row.Hours
+		report.Total.Amount += row.Amount
+		report.Total.PrevLessons += row.PrevLessons
+		report.Total.PrevHours += row.PrevHours
+		report.Total.PrevAmount += row.PrevAmount
+		finishReportRow(row)
+		report.Rows = append(report.Rows, *row)
+	}
+	finishReportRow(&report.Total)
+
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		switch groupBy {
+		case GroupByStudent, GroupByCourse:
+			if a.Amount != b.Amount {
+				return a.Amount > b.Amount
+			}
+			if a.Hours != b.Hours {
+				return a.Hours > b.Hours
+			}
+		}
+		return a.sortKey < b.sortKey
+	})
+	return report, nil
+}
+
+// reportKey 返回排课在指定维度下的分组键、显示名称和排序值
+// 按月分组时，上一年的数据平移 shiftYears 年，使月份键与当期对齐
+func reportKey(groupBy string, s models.Schedule, shiftYears int) (string, string, int) {
+	switch groupBy {
+	case GroupByStudent:
+		return strconv.FormatUint(uint64(s.StudentID), 10), s.Student.Name, int(s.StudentID)
+	case GroupByCourse:
+		return strconv.FormatUint(uint64(s.CourseID), 10), s.Course.Name, int(s.CourseID)
+	case GroupByWeekday:
+		// 周一排在最前
+		weekday := int(s.StartTime.Weekday())
+		order := (weekday + 6) % 7
+		return strconv.Itoa(order + 1), weekdayLabels[weekday], order
+	default:
+		at := s.StartTime.AddDate(shiftYears, 0, 0)
+		return at.Format("2006-01"), at.Format("2006年01月"), at.Year()*100 + int(at.Month())
+	}
+}
+
+// finishReportRow 保留两位小数并计算同比变化
+func finishReportRow(row *ReportRow) {
+	row.Hours = roundAmount(row.Hours)
+	row.Amount = roundAmount(row.Amount)
+	row.PrevHours = roundAmount(row.PrevHours)
+	row.PrevAmount = roundAmount(row.PrevAmount)
+	row.HoursChange = percentChange(row.Hours, row.PrevHours)
+	row.AmountChange = percentChange(row.Amount, row.PrevAmount)
+}
+
+// percentChange 计算同比变化百分比，基数为 0 时返回 nil
+func percentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := roundAmount((current - previous) / previous * 100)
+	return &change
+}
+
+// percentOf 计算占比百分比，总数为 0 时返回 nil
+func percentOf(part, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	rate := roundAmount(float64(part) / float64(total) * 100)
+	return &rate
+}
+
+// parseReportPeriod 解析 start_date / end_date，默认今年 1 月 1 日至今天
+func parseReportPeriod(c *gin.Context) (reportPeriod, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if v := c.Query("start_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return reportPeriod{}, fmt.Errorf("无效的开始日期: %s", v)
+		}
+		start = t
+	}
+	if v := c.Query("end_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return reportPeriod{}, fmt.Errorf("无效的结束日期: %s", v)
+		}
+		end = t
+	}
+	if end.Before(start) {
+		return reportPeriod{}, fmt.Errorf("结束日期不能早于开始日期")
+	}
+	if end.Sub(start) > 366*24*time.Hour {
+		return reportPeriod{}, fmt.Errorf("统计区间不能超过一年")
+	}
+	// 结束日期加一天，包含当天
+	return reportPeriod{start: start, end: end.AddDate(0, 0, 1)}, nil
+}
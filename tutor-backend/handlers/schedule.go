@@ -1,41 +1,199 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
+	"tutor-management/config"
 	"tutor-management/models"
+	"tutor-management/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type ScheduleHandler struct {
-	DB *gorm.DB
+	DB         *gorm.DB
+	Billing    config.BillingConfig
+	Makeup     config.MakeupConfig
+	Attendance config.AttendanceConfig
+	Schedule   config.ScheduleConfig
+	Storage    storage.Storage // 删除排课时一并删除课堂记录的附件文件
 }
 
-func NewScheduleHandler(db *gorm.DB) *ScheduleHandler {
-	return &ScheduleHandler{DB: db}
+func NewScheduleHandler(db *gorm.DB, billing config.BillingConfig, makeup config.MakeupConfig,
+	attendance config.AttendanceConfig, schedule config.ScheduleConfig, store storage.Storage) *ScheduleHandler {
+	return &ScheduleHandler{DB: db, Billing: billing, Makeup: makeup, Attendance: attendance, Schedule: schedule, Storage: store}
+}
+
+// CreateScheduleRequest 创建排课请求
+type CreateScheduleRequest struct {
+	StudentID uint      `json:"student_id" binding:"required"`
+	CourseID  uint      `json:"course_id" binding:"required"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required,gtfield=StartTime"`
+	Status    string    `json:"status" binding:"omitempty,oneof=scheduled completed cancelled"`
+
+	CancelledBy  string `json:"cancelled_by" binding:"omitempty,oneof=student parent tutor"`
+	CancelReason string `json:"cancel_reason" binding:"max=500"`
+
+	MakeupCreditID *uint `json:"makeup_credit_id" binding:"omitempty,gt=0"` // 安排补课时使用的补课权益
+}
+
+// UpdateScheduleRequest 更新排课请求，未提供的字段保持不变
+type UpdateScheduleRequest struct {
+	StudentID *uint      `json:"student_id" binding:"omitempty,gt=0"`
+	CourseID  *uint      `json:"course_id" binding:"omitempty,gt=0"`
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Status    *string    `json:"status" binding:"omitempty,oneof=scheduled in_progress completed cancelled"` // 须为允许的状态流转
+
+	CancelledBy  *string `json:"cancelled_by" binding:"omitempty,oneof=student parent tutor"`
+	CancelReason *string `json:"cancel_reason" binding:"omitempty,max=500"`
+}
+
+// ScheduleResponse 排课信息
+type ScheduleResponse struct {
+	ID        uint            `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Version   uint            `json:"version"`
+	StudentID uint            `json:"student_id"`
+	Student   StudentResponse `json:"student"`
+	CourseID  uint            `json:"course_id"`
+	Course    CourseResponse  `json:"course"`
+	StartTime time.Time       `json:"start_time"`
+	EndTime   time.Time       `json:"end_time"`
+	Status    string          `json:"status"`
+
+	CancelledBy  string     `json:"cancelled_by"`
+	CancelReason string     `json:"cancel_reason"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	LateCancel   bool       `json:"late_cancel"`
+
+	Attendance string     `json:"attendance"`
+	CheckInAt  *time.Time `json:"check_in_at"`
+	CheckOutAt *time.Time `json:"check_out_at"`
+	Hours      float64    `json:"hours"` // 计费时长，已签到签退时按实际时间计算
+
+	MakeupCreditID *uint `json:"makeup_credit_id"`
+}
+
+func (r CreateScheduleRequest) toModel() models.Schedule {
+	status := r.Status
+	if status == "" {
+		status = ScheduleScheduled
+	}
+	return models.Schedule{
+		Version:   1,
+		StudentID: r.StudentID,
+		CourseID:  r.CourseID,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		Status:    status,
+
+		CancelledBy:  r.CancelledBy,
+		CancelReason: r.CancelReason,
+
+		MakeupCreditID: r.MakeupCreditID,
+	}
+}
+
+func (r UpdateScheduleRequest) apply(s *models.Schedule) {
+	if r.StudentID != nil {
+		s.StudentID = *r.StudentID
+	}
+	if r.CourseID != nil {
+		s.CourseID = *r.CourseID
+	}
+	if r.StartTime != nil {
+		s.StartTime = *r.StartTime
+	}
+	if r.EndTime != nil {
+		s.EndTime = *r.EndTime
+	}
+	if r.CancelledBy != nil {
+		s.CancelledBy = *r.CancelledBy
+	}
+	if r.CancelReason != nil {
+		s.CancelReason = *r.CancelReason
+	}
+}
+
+func newScheduleResponse(s models.Schedule) ScheduleResponse {
+	return ScheduleResponse{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Version:   s.Version,
+		StudentID: s.StudentID,
+		Student:   newStudentResponse(s.Student),
+		CourseID:  s.CourseID,
+		Course:    newCourseResponse(s.Course),
+		StartTime: s.StartTime,
+		EndTime:   s.EndTime,
+		Status:    s.Status,
+
+		CancelledBy:  s.CancelledBy,
+		CancelReason: s.CancelReason,
+		CancelledAt:  s.CancelledAt,
+		LateCancel:   s.LateCancel,
+
+		Attendance: s.Attendance,
+		CheckInAt:  s.CheckInAt,
+		CheckOutAt: s.CheckOutAt,
+		Hours:      scheduleHours(s),
+
+		MakeupCreditID: s.MakeupCreditID,
+	}
+}
+
+// scheduleListSpec 排课列表允许的排序与筛选字段
+var scheduleListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"start_time": "start_time",
+		"end_time":   "end_time",
+		"status":     "status",
+		"created_at": "created_at",
+	},
+	DefaultSort: "start_time DESC",
+	Filters: map[string]string{
+		"student_id": "student_id",
+		"course_id":  "course_id",
+		"status":     "status",
+	},
+	DateRange: "start_time",
+	Preloads:  []string{"Student", "Course"},
 }
 
 // GetAll 获取所有排课
 // @Summary 获取所有排课
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
 // @Tags 排课管理
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {array} models.Schedule
+// @Param start_date query string false "开始日期 (yyyy-MM-dd)"
+// @Param end_date query string false "结束日期 (yyyy-MM-dd)"
+// @Param student_id query int false "学生ID"
+// @Param course_id query int false "课程ID"
+// @Param status query string false "状态"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, start_time, end_time, status, created_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Success 200 {array} ScheduleResponse
+// @Failure 400 {object} map[string]string
 // @Router /schedules [get]
 func (h *ScheduleHandler) GetAll(c *gin.Context) {
-	var schedules []models.Schedule
-	if err := h.DB.Preload("Student").Preload("Course").Order("start_time DESC").Find(&schedules).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, schedules)
+	respondList(c, h.DB, scheduleListSpec, newScheduleResponse)
 }
 
 // Search 搜索排课
 // @Summary 搜索排课
+// @Description 与 GET /schedules 使用相同的筛选、排序与分页参数
 // @Tags 排课管理
 // @Security BearerAuth
 // @Produce json
@@ -44,86 +202,94 @@ func (h *ScheduleHandler) GetAll(c *gin.Context) {
 // @Param student_id query int false "学生ID"
 // @Param course_id query int false "课程ID"
 // @Param status query string false "状态"
-// @Success 200 {array} models.Schedule
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Success 200 {array} ScheduleResponse
+// @Failure 400 {object} map[string]string
 // @Router /schedules/search [get]
 func (h *ScheduleHandler) Search(c *gin.Context) {
-	var schedules []models.Schedule
-
-	query := h.DB.Preload("Student").Preload("Course")
-
-	// 时间范围筛选
-	if startDate := c.Query("start_date"); startDate != "" {
-		if t, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("start_time >= ?", t)
-		}
-	}
-	if endDate := c.Query("end_date"); endDate != "" {
-		if t, err := time.Parse("2006-01-02", endDate); err == nil {
-			// 结束日期加一天，包含当天
-			query = query.Where("start_time < ?", t.Add(24*time.Hour))
-		}
-	}
-
-	// 学生筛选
-	if studentID := c.Query("student_id"); studentID != "" {
-		query = query.Where("student_id = ?", studentID)
-	}
-
-	// 科目筛选
-	if courseID := c.Query("course_id"); courseID != "" {
-		query = query.Where("course_id = ?", courseID)
-	}
-
-	// 状态筛选
-	if status := c.Query("status"); status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	// 按时间倒序
-	query = query.Order("start_time DESC")
-
-	if err := query.Find(&schedules).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, schedules)
+	respondList(c, h.DB, scheduleListSpec, newScheduleResponse)
 }
 
 // Create 创建排课
 // @Summary 创建排课
+// @Description 指定 makeup_credit_id 时作为补课安排并核销该补课权益
 // @Tags 排课管理
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param schedule body models.Schedule true "排课信息"
-// @Success 201 {object} models.Schedule
+// @Param schedule body CreateScheduleRequest true "排课信息"
+// @Success 201 {object} ScheduleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /schedules [post]
 func (h *ScheduleHandler) Create(c *gin.Context) {
-	var schedule models.Schedule
-	if err := c.ShouldBindJSON(&schedule); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
-	if err := h.DB.Create(&schedule).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	schedule := req.toModel()
+	if !h.applyCancellation(c, nil, &schedule) {
+		return
+	}
+	if schedule.MakeupCreditID != nil && !checkMakeupCredit(c, h.DB, schedule) {
+		return
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&schedule).Error; err != nil {
+			return err
+		}
+		if err := syncScheduleDeduction(tx, h.Billing, nil, &schedule); err != nil {
+			return err
+		}
+		return syncMakeupCredit(tx, h.Makeup, nil, &schedule)
+	})
+	if err != nil {
+		respondWriteError(c, err)
 		return
 	}
 	h.DB.Preload("Student").Preload("Course").First(&schedule, schedule.ID)
-	c.JSON(http.StatusCreated, schedule)
+	respondVersioned(c, http.StatusCreated, schedule.Version, newScheduleResponse(schedule))
+}
+
+// Get 获取排课详情
+// @Summary 获取排课详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 排课管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "排课ID"
+// @Success 200 {object} ScheduleResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [get]
+func (h *ScheduleHandler) Get(c *gin.Context) {
+	var schedule models.Schedule
+	if err := h.DB.Preload("Student").Preload("Course").First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, schedule.Version, newScheduleResponse(schedule))
 }
 
 // Update 更新排课
 // @Summary 更新排课
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412。状态变更须符合状态流转规则，否则返回 409；已计入未作废账单的排课改变计费时同样返回 409，需先作废账单
 // @Tags 排课管理
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "排课ID"
-// @Param schedule body models.Schedule true "排课信息"
-// @Success 200 {object} models.Schedule
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param schedule body UpdateScheduleRequest true "排课信息"
+// @Success 200 {object} ScheduleResponse
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /schedules/{id} [put]
+// @Router /schedules/{id} [patch]
 func (h *ScheduleHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var schedule models.Schedule
@@ -131,40 +297,122 @@ func (h *ScheduleHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
 		return
 	}
-	if err := c.ShouldBindJSON(&schedule); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !checkIfMatch(c, schedule.Version) {
 		return
 	}
-	if err := h.DB.Save(&schedule).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	before := schedule
+	var req UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
-	h.DB.Preload("Student").Preload("Course").First(&schedule, schedule.ID)
-	c.JSON(http.StatusOK, schedule)
+	req.apply(&schedule)
+	if !schedule.EndTime.After(schedule.StartTime) {
+		c.JSON(http.StatusBadRequest, fieldError("end_time", "必须晚于 start_time"))
+		return
+	}
+	if req.Status != nil && *req.Status != schedule.Status {
+		if !scheduleTransitionAllowed(schedule.Status, *req.Status) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("排课不能从 %s 变更为 %s", schedule.Status, *req.Status)})
+			return
+		}
+		h.enterStatus(&schedule, *req.Status, time.Now())
+	}
+	h.saveAndRespond(c, before, schedule)
 }
 
 // Delete 删除排课
 // @Summary 删除排课
+// @Description 已计入未作废账单的排课返回 409，需先作废账单
 // @Tags 排课管理
 // @Security BearerAuth
 // @Param id path int true "排课ID"
+// @Param If-Match header string true "当前版本的 ETag"
 // @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /schedules/{id} [delete]
 func (h *ScheduleHandler) Delete(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.DB.Delete(&models.Schedule{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var schedule models.Schedule
+	if err := h.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
 		return
 	}
+	if !checkIfMatch(c, schedule.Version) {
+		return
+	}
+	var attachments []models.Attachment
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkScheduleInvoiced(tx, h.Billing, &schedule, nil); err != nil {
+			return err
+		}
+		var err error
+		if attachments, err = deleteScheduleAttachments(tx, schedule.ID); err != nil {
+			return err
+		}
+		if err := syncLessonRecord(tx, &schedule, nil); err != nil {
+			return err
+		}
+		if err := detachHomework(tx, schedule.ID); err != nil {
+			return err
+		}
+		if err := deleteVersioned(tx, &schedule, schedule.Version); err != nil {
+			return err
+		}
+		if err := syncScheduleDeduction(tx, h.Billing, &schedule, nil); err != nil {
+			return err
+		}
+		return syncMakeupCredit(tx, h.Makeup, &schedule, nil)
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	removeAttachmentObjects(h.Storage, attachments)
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
+// saveAndRespond 整理考勤与取消信息后保存排课变更，并写出最新的排课信息
+func (h *ScheduleHandler) saveAndRespond(c *gin.Context, before, schedule models.Schedule) {
+	normalizeAttendance(&schedule)
+	if !h.applyCancellation(c, &before, &schedule) {
+		return
+	}
+	if err := h.saveSchedule(&before, &schedule); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Student").Preload("Course").First(&schedule, schedule.ID)
+	respondVersioned(c, http.StatusOK, schedule.Version, newScheduleResponse(schedule))
+}
+
+// saveSchedule 保存排课变更，并在同一事务中同步课时包扣减、课堂记录与补课权益
+func (h *ScheduleHandler) saveSchedule(before, schedule *models.Schedule) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkScheduleInvoiced(tx, h.Billing, before, schedule); err != nil {
+			return err
+		}
+		if err := saveVersioned(tx, schedule, &schedule.Version); err != nil {
+			return err
+		}
+		if err := syncScheduleDeduction(tx, h.Billing, before, schedule); err != nil {
+			return err
+		}
+		if err := syncLessonRecord(tx, before, schedule); err != nil {
+			return err
+		}
+		return syncMakeupCredit(tx, h.Makeup, before, schedule)
+	})
+}
+
 // GetTodaySchedules 获取今日排课
 // @Summary 获取今日排课
 // @Tags 排课管理
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} map[string][]models.Schedule
+// @Success 200 {object} map[string][]ScheduleResponse
 // @Router /schedules/today [get]
 func (h *ScheduleHandler) GetTodaySchedules(c *gin.Context) {
 	var schedules []models.Schedule
@@ -182,7 +430,11 @@ func (h *ScheduleHandler) GetTodaySchedules(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": schedules})
+	result := make([]ScheduleResponse, 0, len(schedules))
+	for _, s := range schedules {
+		result = append(result, newScheduleResponse(s))
+	}
+	respondWithETag(c, gin.H{"data": result})
 }
 
 // App 专用接口 - 返回简化的今日课程数据
@@ -191,8 +443,13 @@ type DashboardSchedule struct {
 	StudentName string `json:"student_name"`
 	TimeSlot    string `json:"time_slot"`
 	Subject     string `json:"subject"`
-	Status      string `json:"status"` // pending, ongoing, completed
-	Date        string `json:"date"`   // yyyy-MM-dd 格式
+	Status      string `json:"status"`      // scheduled, in_progress, completed, cancelled，与排课状态一致
+	Attendance  string `json:"attendance"`  // present, late, absent, excused，未记录为空
+	Date        string `json:"date"`        // yyyy-MM-dd 格式
+	LowBalance  bool   `json:"low_balance"` // 该科目课时包余额不足
+
+	PendingHomework int `json:"pending_homework"` // 该科目未提交的作业数
+	OverdueHomework int `json:"overdue_homework"` // 其中已逾期的作业数
 }
 
 // GetDashboardToday App专用 - 获取今日课程
@@ -218,32 +475,13 @@ func (h *ScheduleHandler) GetDashboardToday(c *gin.Context) {
 		return
 	}
 
-	result := make([]DashboardSchedule, 0, len(schedules))
-	for _, s := range schedules {
-		// 转换到本地时区
-		localStart := s.StartTime.In(time.Local)
-		localEnd := s.EndTime.In(time.Local)
-
-		status := "pending"
-		if now.After(localEnd) {
-			status = "completed"
-		} else if now.After(localStart) && now.Before(localEnd) {
-			status = "ongoing"
-		}
-
-		timeSlot := localStart.Format("15:04") + "-" + localEnd.Format("15:04")
-
-		result = append(result, DashboardSchedule{
-			ID:          s.ID,
-			StudentName: s.Student.Name,
-			TimeSlot:    timeSlot,
-			Subject:     s.Course.Name,
-			Status:      status,
-			Date:        localStart.Format("2006-01-02"),
-		})
+	result, err := h.toDashboard(schedules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	respondWithETag(c, result)
 }
 
 // GetDashboardByDate App专用 - 按日期查询课程
@@ -282,20 +520,32 @@ func (h *ScheduleHandler) GetDashboardByDate(c *gin.Context) {
 		return
 	}
 
-	now := time.Now()
+	result, err := h.toDashboard(schedules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondWithETag(c, result)
+}
+
+// toDashboard 将排课转换为 App 看板数据
+func (h *ScheduleHandler) toDashboard(schedules []models.Schedule) ([]DashboardSchedule, error) {
+	lowBalance, err := lowBalanceCourses(h.DB, h.Billing.LowBalanceThreshold)
+	if err != nil {
+		return nil, err
+	}
+	pendingHomework, overdueHomework, err := pendingHomeworkCounts(h.DB, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	result := make([]DashboardSchedule, 0, len(schedules))
 	for _, s := range schedules {
 		// 转换到本地时区
 		localStart := s.StartTime.In(time.Local)
 		localEnd := s.EndTime.In(time.Local)
 
-		status := "pending"
-		if now.After(localEnd) {
-			status = "completed"
-		} else if now.After(localStart) && now.Before(localEnd) {
-			status = "ongoing"
-		}
-
 		timeSlot := localStart.Format("15:04") + "-" + localEnd.Format("15:04")
 
 		result = append(result, DashboardSchedule{
@@ -303,10 +553,72 @@ func (h *ScheduleHandler) GetDashboardByDate(c *gin.Context) {
 			StudentName: s.Student.Name,
 			TimeSlot:    timeSlot,
 			Subject:     s.Course.Name,
-			Status:      status,
+			Status:      s.Status,
+			Attendance:  s.Attendance,
 			Date:        localStart.Format("2006-01-02"),
+			LowBalance:  lowBalance[[2]uint{s.StudentID, s.CourseID}],
+
+			PendingHomework: pendingHomework[[2]uint{s.StudentID, s.CourseID}],
+			OverdueHomework: overdueHomework[[2]uint{s.StudentID, s.CourseID}],
 		})
 	}
+	return result, nil
+}
+
+// scheduleHours 排课计费时长（小时），已签到签退时按实际时间计算，保留两位小数
+func scheduleHours(s models.Schedule) float64 {
+	if s.CheckInAt != nil && s.CheckOutAt != nil && s.CheckOutAt.After(*s.CheckInAt) {
+		return roundAmount(s.CheckOutAt.Sub(*s.CheckInAt).Hours())
+	}
+	return roundAmount(s.EndTime.Sub(s.StartTime).Hours())
+}
+
+// applyCancellation 维护取消信息：进入取消状态时以服务器时间记录取消时间，并按政策判定是否迟取消，
+// 非取消状态清空取消信息；before 为 nil 表示新建。缺少取消方时已写出 400 响应
+func (h *ScheduleHandler) applyCancellation(c *gin.Context, before, s *models.Schedule) bool {
+	if s.Status != ScheduleCancelled {
+		s.CancelledBy = ""
+		s.CancelReason = ""
+		s.CancelledAt = nil
+		s.LateCancel = false
+		return true
+	}
+	if s.CancelledBy == "" {
+		c.JSON(http.StatusBadRequest, fieldError("cancelled_by", "取消课程时不能为空"))
+		return false
+	}
+	if before == nil || before.Status != ScheduleCancelled || s.CancelledAt == nil {
+		now := time.Now()
+		s.CancelledAt = &now
+	}
+	s.LateCancel = isLateCancel(*s, h.Billing)
+	return true
+}
+
+// isLateCancel 判断取消是否按政策计费：学生或家长在开课前不足通知时长取消；
+// 登记为缺勤的视为迟取消，请假的不计费
+func isLateCancel(s models.Schedule, billing config.BillingConfig) bool {
+	switch s.Attendance {
+	case AttendanceAbsent:
+		return true
+	case AttendanceExcused:
+		return false
+	}
+	if s.CancelledAt == nil || s.CancelledBy == "tutor" || billing.CancelNoticeHours <= 0 {
+		return false
+	}
+	notice := time.Duration(billing.CancelNoticeHours * float64(time.Hour))
+	return s.StartTime.Sub(*s.CancelledAt) < notice
+}
 
-	c.JSON(http.StatusOK, result)
+// billableFraction 排课的计费比例：已完成为 1，迟取消按政策比例，其余为 0
+func billableFraction(s models.Schedule, billing config.BillingConfig) float64 {
+	switch {
+	case s.Status == ScheduleCompleted:
+		return 1
+	case s.Status == ScheduleCancelled && s.LateCancel:
+		return billing.LateCancelChargeRate
+	default:
+		return 0
+	}
 }
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 排课状态，排课接口与 App 看板使用同一套状态
+const (
+	ScheduleScheduled  = "scheduled"
+	ScheduleInProgress = "in_progress"
+	ScheduleCompleted  = "completed"
+	ScheduleCancelled  = "cancelled"
+)
+
+// scheduleTransitions 允许的排课状态流转
+// 已完成的课程可取消（事后登记缺勤）或重新打开；已取消的课程需先恢复为已排课
+var scheduleTransitions = map[string][]string{
+	ScheduleScheduled:  {ScheduleInProgress, ScheduleCompleted, ScheduleCancelled},
+	ScheduleInProgress: {ScheduleScheduled, ScheduleCompleted, ScheduleCancelled},
+	ScheduleCompleted:  {ScheduleScheduled, ScheduleCancelled},
+	ScheduleCancelled:  {ScheduleScheduled},
+}
+
+// ScheduleTransitionRequest 排课状态变更请求
+type ScheduleTransitionRequest struct {
+	Status       string `json:"status" binding:"required,oneof=scheduled in_progress completed cancelled"`
+	CancelledBy  string `json:"cancelled_by" binding:"omitempty,oneof=student parent tutor"` // 取消时必填
+	CancelReason string `json:"cancel_reason" binding:"max=500"`
+}
+
+// Transition 变更排课状态
+// @Summary 变更排课状态
+// @Description 允许的流转：scheduled → in_progress/completed/cancelled，in_progress → scheduled/completed/cancelled，completed → scheduled/cancelled，cancelled → scheduled。进入 in_progress 时记录签到，进入 completed 时补记签退，恢复为 scheduled 时清空考勤；已计入未作废账单的课程改变计费时返回 409，需先作废账单
+// @Tags 排课管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "排课ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param request body ScheduleTransitionRequest true "目标状态"
+// @Success 200 {object} ScheduleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /schedules/{id}/transition [post]
+func (h *ScheduleHandler) Transition(c *gin.Context) {
+	var schedule models.Schedule
+	if err := h.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "排课不存在"})
+		return
+	}
+	if !checkIfMatch(c, schedule.Version) {
+		return
+	}
+	var req ScheduleTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	if !scheduleTransitionAllowed(schedule.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("排课不能从 %s 变更为 %s", schedule.Status, req.Status)})
+		return
+	}
+
+	before := schedule
+	h.enterStatus(&schedule, req.Status, time.Now())
+	if req.Status == ScheduleCancelled {
+		schedule.CancelledBy = req.CancelledBy
+		schedule.CancelReason = req.CancelReason
+	}
+	h.saveAndRespond(c, before, schedule)
+}
+
+// enterStatus 切换排课状态并维护对应的考勤信息
+func (h *ScheduleHandler) enterStatus(s *models.Schedule, to string, now time.Time) {
+	switch to {
+	case ScheduleScheduled:
+		s.Attendance = ""
+		s.CheckInAt = nil
+		s.CheckOutAt = nil
+	case ScheduleInProgress:
+		s.CheckOutAt = nil
+		if s.CheckInAt == nil {
+			s.CheckInAt = &now
+		}
+		if s.Attendance == "" {
+			s.Attendance = arrivalAttendance(*s, h.Attendance)
+		}
+	case ScheduleCompleted:
+		if s.CheckInAt != nil && s.CheckOutAt == nil && now.After(*s.CheckInAt) {
+			s.CheckOutAt = &now
+		}
+	}
+	s.Status = to
+}
+
+// RunAutoComplete 定期将已过下课时间的课程标记为已完成，ctx 取消后退出
+func (h *ScheduleHandler) RunAutoComplete(ctx context.Context) {
+	if h.Schedule.AutoCompleteAfterMinutes <= 0 {
+		return
+	}
+	interval := time.Duration(h.Schedule.JobIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := h.autoComplete(time.Now())
+		if err != nil {
+			utils.Error("Failed to auto-complete schedules", zap.Int("completed", count), zap.Error(err))
+		} else if count > 0 {
+			utils.Info("Schedules auto-completed", zap.Int("count", count))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// autoComplete 完成下课时间早于 now 减去延迟的已排课/上课中课程，返回完成数量
+// 逐条保存并同步课时包扣减；已被其他请求修改的记录留到下一轮处理，
+// 单条保存失败时记录日志后继续处理其余课程，最后返回合并的错误
+func (h *ScheduleHandler) autoComplete(now time.Time) (int, error) {
+	cutoff := now.Add(-time.Duration(h.Schedule.AutoCompleteAfterMinutes) * time.Minute)
+	var schedules []models.Schedule
+	err := h.DB.Where("status IN ? AND end_time < ?", []string{ScheduleScheduled, ScheduleInProgress}, cutoff).
+		Order("end_time ASC").
+		Find(&schedules).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var errs []error
+	for _, schedule := range schedules {
+		before := schedule
+		// 未签退的课程按计划下课时间补记签退
+		h.enterStatus(&schedule, ScheduleCompleted, schedule.EndTime)
+		if err := h.saveSchedule(&before, &schedule); err != nil {
+			if errors.Is(err, errVersionConflict) {
+				continue
+			}
+			utils.Error("Failed to auto-complete schedule", zap.Uint("schedule_id", schedule.ID), zap.Error(err))
+			errs = append(errs, fmt.Errorf("schedule %d: %w", schedule.ID, err))
+			continue
+		}
+		count++
+	}
+	return count, errors.Join(errs...)
+}
+
+func scheduleTransitionAllowed(from, to string) bool {
+	for _, next := range scheduleTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
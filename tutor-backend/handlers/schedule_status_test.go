@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+)
+
+func TestScheduleTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{ScheduleScheduled, ScheduleInProgress, true},
+		{ScheduleScheduled, ScheduleCompleted, true},
+		{ScheduleScheduled, ScheduleCancelled, true},
+		{ScheduleInProgress, ScheduleScheduled, true},
+		{ScheduleInProgress, ScheduleCompleted, true},
+		{ScheduleCompleted, ScheduleScheduled, true},
+		{ScheduleCompleted, ScheduleCancelled, true},
+		{ScheduleCompleted, ScheduleInProgress, false},
+		{ScheduleCancelled, ScheduleScheduled, true},
+		{ScheduleCancelled, ScheduleCompleted, false},
+		{ScheduleCancelled, ScheduleInProgress, false},
+		{ScheduleScheduled, ScheduleScheduled, false},
+		{"unknown", ScheduleScheduled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := scheduleTransitionAllowed(tt.from, tt.to); got != tt.want {
+				t.Errorf("scheduleTransitionAllowed(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnterStatus(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	end := start.Add(time.Hour)
+	checkIn := start.Add(5 * time.Minute)
+	h := &ScheduleHandler{Attendance: config.AttendanceConfig{LateGraceMinutes: 10}}
+
+	tests := []struct {
+		name           string
+		schedule       models.Schedule
+		to             string
+		now            time.Time
+		wantAttendance string
+		wantCheckIn    *time.Time
+		wantCheckOut   *time.Time
+	}{
+		{"开始上课记录签到", models.Schedule{}, ScheduleInProgress, checkIn, AttendancePresent, &checkIn, nil},
+		{"已签到不覆盖", models.Schedule{CheckInAt: &start, Attendance: AttendanceLate}, ScheduleInProgress, checkIn, AttendanceLate, &start, nil},
+		{"完成时补记签退", models.Schedule{CheckInAt: &checkIn, Attendance: AttendancePresent}, ScheduleCompleted, end, AttendancePresent, &checkIn, &end},
+		{"未签到完成不补签退", models.Schedule{}, ScheduleCompleted, end, "", nil, nil},
+		{"恢复为已排课清空考勤", models.Schedule{CheckInAt: &checkIn, CheckOutAt: &end, Attendance: AttendancePresent}, ScheduleScheduled, end, "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.schedule
+			s.StartTime, s.EndTime = start, end
+			h.enterStatus(&s, tt.to, tt.now)
+			if s.Status != tt.to {
+				t.Errorf("status = %q, want %q", s.Status, tt.to)
+			}
+			if s.Attendance != tt.wantAttendance {
+				t.Errorf("attendance = %q, want %q", s.Attendance, tt.wantAttendance)
+			}
+			if !equalTimePtr(s.CheckInAt, tt.wantCheckIn) {
+				t.Errorf("check_in_at = %v, want %v", s.CheckInAt, tt.wantCheckIn)
+			}
+			if !equalTimePtr(s.CheckOutAt, tt.wantCheckOut) {
+				t.Errorf("check_out_at = %v, want %v", s.CheckOutAt, tt.wantCheckOut)
+			}
+		})
+	}
+}
+
+func TestAutoCompleteContinuesAfterError(t *testing.T) {
+	db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Schedule{},
+		&models.LessonPackage{}, &models.PackageDeduction{}, &models.LessonRecord{}, &models.MakeupCredit{}, &models.Invoice{}, &models.InvoiceItem{})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		start := now.Add(time.Duration(-5+i) * time.Hour)
+		s := models.Schedule{StudentID: 1, CourseID: 1, StartTime: start, EndTime: start.Add(time.Hour), Status: ScheduleScheduled}
+		if err := db.Omit("Student", "Course").Create(&s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec("CREATE TRIGGER fail_update BEFORE UPDATE ON schedules WHEN NEW.id = 2 BEGIN SELECT RAISE(ABORT, 'boom'); END").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	h := &ScheduleHandler{DB: db, Schedule: config.ScheduleConfig{AutoCompleteAfterMinutes: 30}}
+	count, err := h.autoComplete(now)
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	if err == nil || !strings.Contains(err.Error(), "schedule 2") {
+		t.Errorf("err = %v, want error mentioning schedule 2", err)
+	}
+
+	var statuses []string
+	db.Model(&models.Schedule{}).Order("id ASC").Pluck("status", &statuses)
+	want := []string{ScheduleCompleted, ScheduleScheduled, ScheduleCompleted}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBillingPolicy(t *testing.T) {
+	billing := config.BillingConfig{CancelNoticeHours: 24, LateCancelChargeRate: 0.5}
+	start := time.Date(2024, 3, 5, 18, 0, 0, 0, time.Local)
+	before := func(d time.Duration) *time.Time {
+		at := start.Add(-d)
+		return &at
+	}
+	cancelled := func(by string, at *time.Time, attendance string) models.Schedule {
+		return models.Schedule{StartTime: start, Status: ScheduleCancelled, CancelledBy: by, CancelledAt: at, Attendance: attendance}
+	}
+
+	tests := []struct {
+		name         string
+		schedule     models.Schedule
+		billing      config.BillingConfig
+		wantLate     bool
+		wantFraction float64
+	}{
+		{"已完成全额计费", models.Schedule{StartTime: start, Status: ScheduleCompleted}, billing, false, 1},
+		{"已排课不计费", models.Schedule{StartTime: start, Status: ScheduleScheduled}, billing, false, 0},
+		{"提前取消不计费", cancelled("parent", before(48*time.Hour), ""), billing, false, 0},
+		{"恰好提前通知时长不计费", cancelled("student", before(24*time.Hour), ""), billing, false, 0},
+		{"不足通知时长按比例计费", cancelled("student", before(2*time.Hour), ""), billing, true, 0.5},
+		{"老师取消不计费", cancelled("tutor", before(time.Hour), ""), billing, false, 0},
+		{"缺勤视为迟取消", cancelled("", nil, AttendanceAbsent), billing, true, 0.5},
+		{"请假不计费", cancelled("parent", before(time.Hour), AttendanceExcused), billing, false, 0},
+		{"未启用迟取消政策", cancelled("parent", before(time.Hour), ""), config.BillingConfig{LateCancelChargeRate: 1}, false, 0},
+		{"全额收取迟取消", cancelled("parent", before(time.Hour), ""), config.BillingConfig{CancelNoticeHours: 24, LateCancelChargeRate: 1}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.schedule
+			if s.Status == ScheduleCancelled {
+				s.LateCancel = isLateCancel(s, tt.billing)
+				if s.LateCancel != tt.wantLate {
+					t.Errorf("isLateCancel = %v, want %v", s.LateCancel, tt.wantLate)
+				}
+			}
+			if got := billableFraction(s, tt.billing); got != tt.wantFraction {
+				t.Errorf("billableFraction = %v, want %v", got, tt.wantFraction)
+			}
+		})
+	}
+}
+
+func TestScheduleCancelledAtSetByServer(t *testing.T) {
+	billing := config.BillingConfig{CancelNoticeHours: 24, LateCancelChargeRate: 1}
+	start := time.Now().Add(4 * time.Hour)
+	earlier := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name     string
+		status   string
+		body     string
+		wantLate bool
+		wantAt   bool
+	}{
+		{"忽略客户端提交的取消时间", ScheduleScheduled, `{"status":"cancelled","cancelled_by":"student","cancelled_at":"2026-10-01T00:00:00Z"}`, true, true},
+		{"已取消时保留原取消时间", ScheduleCancelled, `{"cancel_reason":"生病"}`, false, true},
+		{"恢复为已排课时清空", ScheduleCancelled, `{"status":"scheduled"}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Schedule{},
+				&models.LessonPackage{}, &models.PackageDeduction{}, &models.LessonRecord{}, &models.MakeupCredit{}, &models.Invoice{}, &models.InvoiceItem{})
+			db.Create(&models.Student{Name: "张三"})
+			db.Create(&models.Course{Name: "数学"})
+			s := models.Schedule{Version: 1, StudentID: 1, CourseID: 1, StartTime: start, EndTime: start.Add(time.Hour), Status: tt.status}
+			if tt.status == ScheduleCancelled {
+				s.CancelledBy, s.CancelledAt = "student", &earlier
+			}
+			if err := db.Omit("Student", "Course").Create(&s).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewScheduleHandler(db, billing, config.MakeupConfig{}, config.AttendanceConfig{}, config.ScheduleConfig{}, nil)
+			r := gin.New()
+			r.PATCH("/schedules/:id", h.Update)
+			req := httptest.NewRequest(http.MethodPatch, "/schedules/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", versionETag(1))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("PATCH = %d: %s", w.Code, w.Body.String())
+			}
+
+			var got models.Schedule
+			db.First(&got, 1)
+			if got.LateCancel != tt.wantLate {
+				t.Errorf("late_cancel = %v, want %v", got.LateCancel, tt.wantLate)
+			}
+			if (got.CancelledAt != nil) != tt.wantAt {
+				t.Fatalf("cancelled_at = %v, want set %v", got.CancelledAt, tt.wantAt)
+			}
+			switch {
+			case got.CancelledAt == nil:
+			case tt.status == ScheduleCancelled && !got.CancelledAt.Equal(earlier):
+				t.Errorf("cancelled_at = %v, want %v", got.CancelledAt, earlier)
+			case tt.status != ScheduleCancelled && time.Since(*got.CancelledAt) > time.Minute:
+				t.Errorf("cancelled_at = %v, want now", got.CancelledAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tutor-management/search"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type SearchHandler struct {
+	DB    *gorm.DB
+	Index search.Index
+}
+
+func NewSearchHandler(db *gorm.DB, index search.Index) *SearchHandler {
+	return &SearchHandler{DB: db, Index: index}
+}
+
+// Search 全文检索
+// @Summary 全文检索学生、课程与考试成绩
+// @Description 检索学生姓名/备注、课程名称/描述、考试名称/评语，支持学生姓名拼音与首字母（如 zs、zhangsan），结果按实体类型分组
+// @Tags 检索
+// @Security BearerAuth
+// @Produce json
+// @Param q query string true "关键词，多个关键词以空格分隔"
+// @Param types query string false "实体类型，逗号分隔: student, course, exam_result"
+// @Param limit query int false "最多返回条数，默认 50"
+// @Success 200 {array} search.Group
+// @Failure 400 {object} map[string]string
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q parameter is required"})
+		return
+	}
+
+	var types []string
+	if raw := c.Query("types"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			switch t {
+			case search.TypeStudent, search.TypeCourse, search.TypeExamResult:
+				types = append(types, t)
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的实体类型: " + t})
+				return
+			}
+		}
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 limit"})
+			return
+		}
+		if n < maxPageSize {
+			limit = n
+		} else {
+			limit = maxPageSize
+		}
+	}
+
+	groups, err := search.Run(h.DB, h.Index, q, types, limit)
+	if err != nil {
+		utils.Error("Search failed", zap.String("q", q), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type StatementHandler struct {
+	DB      *gorm.DB
+	Billing config.BillingConfig
+}
+
+func NewStatementHandler(db *gorm.DB, billing config.BillingConfig) *StatementHandler {
+	return &StatementHandler{DB: db, Billing: billing}
+}
+
+// StatementLesson 月结单中的一次课
+type StatementLesson struct {
+	Schedule models.Schedule
+	Hours    float64
+	Charge   float64 // 计入账单的金额
+	Deducted float64 // 从课时包扣减的数量
+	Unit     string  // 扣减的课时包计量单位
+}
+
+// Statement 学生月结单数据
+type Statement struct {
+	Student     models.Student
+	Month       time.Time
+	GeneratedAt time.Time
+	Lessons     []StatementLesson
+	Hours       float64 // 已完成课时
+	Charges     float64 // 本月课程费用
+	Payments    []models.Payment
+	PaidTotal   float64
+	Outstanding float64 // 截至生成时的未结金额
+	Balances    []BalanceSummary
+	Exams       []models.ExamResult // 各科目最近一次考试
+}
+
+// hasActivity 本月是否有课程或收款
+func (s Statement) hasActivity() bool {
+	return len(s.Lessons) > 0 || len(s.Payments) > 0
+}
+
+// GetStudentStatement 下载学生月结单
+// @Summary 下载学生月结单 PDF
+// @Description 包含当月课程、课时、费用、收款、课时包余额与各科最近一次考试成绩
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce application/pdf
+// @Param id path int true "学生ID"
+// @Param month query string false "月份 (yyyy-MM)，默认当月"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /students/{id}/statement.pdf [get]
+func (h *StatementHandler) GetStudentStatement(c *gin.Context) {
+	month, ok := parseStatementMonth(c)
+	if !ok {
+		return
+	}
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+
+	statement, err := h.buildStatement(student, month)
+	if err != nil {
+		utils.Error("Failed to build statement", zap.Uint("student_id", student.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := renderStatementPDF(statement)
+	if err != nil {
+		utils.Error("Failed to render statement", zap.Uint("student_id", student.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成月结单失败: " + err.Error()})
+		return
+	}
+
+	filename := statementFilename(student, month)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%d-%s.pdf"; filename*=UTF-8''%s`,
+		student.ID, month.Format("2006-01"), url.PathEscape(filename)))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// GetAllStatements 批量生成月结单
+// @Summary 批量生成全部学生的月结单（ZIP）
+// @Description 为当月有课程或收款的每个学生生成一份 PDF，打包为 ZIP 下载
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce application/zip
+// @Param month query string false "月份 (yyyy-MM)，默认当月"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Router /statements.zip [get]
+func (h *StatementHandler) GetAllStatements(c *gin.Context) {
+	month, ok := parseStatementMonth(c)
+	if !ok {
+		return
+	}
+	var students []models.Student
+	if err := h.DB.Order("id ASC").Find(&students).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	count := 0
+	for _, student := range students {
+		statement, err := h.buildStatement(student, month)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !statement.hasActivity() {
+			continue
+		}
+		data, err := renderStatementPDF(statement)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成月结单失败: " + err.Error()})
+			return
+		}
+		// 以学生ID为前缀，避免同名学生的文件冲突
+		w, err := archive.Create(fmt.Sprintf("%d-%s", student.ID, statementFilename(student, month)))
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		count++
+	}
+	if err := archive.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	utils.Info("Statements generated", zap.String("month", month.Format("2006-01")), zap.Int("count", count))
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statements-%s.zip"`, month.Format("2006-01")))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// buildStatement 汇总学生某月的月结单数据
+func (h *StatementHandler) buildStatement(student models.Student, month time.Time) (Statement, error) {
+	start := month
+	end := month.AddDate(0, 1, 0)
+	statement := Statement{Student: student, Month: month, GeneratedAt: time.Now()}
+
+	var schedules []models.Schedule
+	err := h.DB.Preload("Course").
+		Where("student_id = ? AND start_time >= ? AND start_time < ?", student.ID, start, end).
+		Order("start_time ASC").
+		Find(&schedules).Error
+	if err != nil {
+		return statement, err
+	}
+
+	ids := make([]uint, 0, len(schedules))
+	for _, s := range schedules {
+		ids = append(ids, s.ID)
+	}
+	charges := make(map[uint]float64)
+	deducted := make(map[uint]models.PackageDeduction)
+	units := make(map[uint]string)
+	if len(ids) > 0 {
+		var items []models.InvoiceItem
+		err := h.DB.Joins("JOIN invoices ON invoices.id = invoice_items.invoice_id").
+			Where("invoices.status <> ? AND invoice_items.schedule_id IN ?", InvoiceVoid, ids).
+			Find(&items).Error
+		if err != nil {
+			return statement, err
+		}
+		for _, item := range items {
+			charges[item.ScheduleID] += item.Amount
+		}
+
+		var deductions []models.PackageDeduction
+		if err := h.DB.Where("reversed_at IS NULL AND schedule_id IN ?", ids).Find(&deductions).Error; err != nil {
+			return statement, err
+		}
+		packageIDs := make([]uint, 0, len(deductions))
+		for _, d := range deductions {
+			deducted[d.ScheduleID] = d
+			packageIDs = append(packageIDs, d.PackageID)
+		}
+		if len(packageIDs) > 0 {
+			var packages []models.LessonPackage
+			if err := h.DB.Where("id IN ?", packageIDs).Find(&packages).Error; err != nil {
+				return statement, err
+			}
+			for _, p := range packages {
+				units[p.ID] = p.Unit
+			}
+		}
+	}
+
+	for _, s := range schedules {
+		lesson := StatementLesson{Schedule: s, Hours: scheduleHours(s), Charge: roundAmount(charges[s.ID])}
+		if d, ok := deducted[s.ID]; ok {
+			lesson.Deducted = d.Amount
+			lesson.Unit = units[d.PackageID]
+		}
+		if s.Status == ScheduleCompleted {
+			statement.Hours += lesson.Hours
+		}
+		statement.Charges += lesson.Charge
+		statement.Lessons = append(statement.Lessons, lesson)
+	}
+	statement.Hours = roundAmount(statement.Hours)
+	statement.Charges = roundAmount(statement.Charges)
+
+	err = h.DB.Where("student_id = ? AND paid_at >= ? AND paid_at < ?", student.ID, start, end).
+		Order("paid_at ASC").
+		Find(&statement.Payments).Error
+	if err != nil {
+		return statement, err
+	}
+	for _, p := range statement.Payments {
+		statement.PaidTotal += p.Amount
+	}
+	statement.PaidTotal = roundAmount(statement.PaidTotal)
+
+	invoiced, err := sumByStudent(h.DB.Model(&models.Invoice{}).
+		Where("student_id = ? AND status IN ?", student.ID, []string{InvoiceIssued, InvoicePaid}))
+	if err != nil {
+		return statement, err
+	}
+	paid, err := sumByStudent(h.DB.Model(&models.Payment{}).Where("student_id = ?", student.ID))
+	if err != nil {
+		return statement, err
+	}
+	statement.Outstanding = roundAmount(invoiced[student.ID] - paid[student.ID])
+
+	packages := &PackageHandler{DB: h.DB, Billing: h.Billing}
+	if statement.Balances, err = packages.balanceSummaries(&student.ID); err != nil {
+		return statement, err
+	}
+
+	statement.Exams, err = latestExams(h.DB, student.ID, end)
+	return statement, err
+}
+
+// latestExams 返回学生各科目在 before 之前最近一次的考试成绩
+func latestExams(db *gorm.DB, studentID uint, before time.Time) ([]models.ExamResult, error) {
+	var results []models.ExamResult
+	err := db.Preload("Course").
+		Where("student_id = ? AND exam_date < ?", studentID, before).
+		Order("exam_date DESC, id DESC").
+		Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool)
+	latest := make([]models.ExamResult, 0)
+	for _, r := range results {
+		if seen[r.CourseID] {
+			continue
+		}
+		seen[r.CourseID] = true
+		latest = append(latest, r)
+	}
+	return latest, nil
+}
+
+// parseStatementMonth 解析 month 参数（yyyy-MM），默认当月；失败时已写出响应
+func parseStatementMonth(c *gin.Context) (time.Time, bool) {
+	raw := c.Query("month")
+	if raw == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local), true
+	}
+	month, err := time.ParseInLocation("2006-01", raw, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month format, use yyyy-MM"})
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// statementFilename 月结单文件名，如 张三-2024-02.pdf
+func statementFilename(student models.Student, month time.Time) string {
+	name := strings.NewReplacer("/", "_", `\`, "_").Replace(student.Name)
+	return fmt.Sprintf("%s-%s.pdf", name, month.Format("2006-01"))
+}
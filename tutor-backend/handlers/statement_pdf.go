@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// 月结单中使用的中文标签
+var (
+	scheduleStatusLabels = map[string]string{
+		ScheduleScheduled:  "已排课",
+		ScheduleInProgress: "上课中",
+		ScheduleCompleted:  "已完成",
+		ScheduleCancelled:  "已取消",
+	}
+	paymentMethodLabels = map[string]string{
+		"cash":   "现金",
+		"wechat": "微信",
+		"alipay": "支付宝",
+	}
+	packageUnitLabels = map[string]string{
+		PackageUnitHour:   "小时",
+		PackageUnitLesson: "节",
+	}
+)
+
+// pdfColumn 表格列定义
+type pdfColumn struct {
+	title string
+	width float64
+	align string
+}
+
+// renderStatementPDF 将月结单渲染为 A4 PDF，使用与图表相同的中文字体
+func renderStatementPDF(s Statement) ([]byte, error) {
+	if len(chineseFont) == 0 {
+		return nil, errors.New("中文字体未加载")
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("noto", "", chineseFont)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("noto", "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 5, fmt.Sprintf("第 %d 页 / 共 {nb} 页", pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("noto", "", 18)
+	pdf.CellFormat(0, 10, fmt.Sprintf("%s 月结单", s.Month.Format("2006年01月")), "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont("noto", "", 10)
+	pdf.CellFormat(90, 6, "学生："+s.Student.Name, "", 0, "L", false, 0, "")
+	pdf.CellFormat(90, 6, "年级："+s.Student.Grade, "", 1, "L", false, 0, "")
+	pdf.CellFormat(90, 6, "家长电话："+s.Student.ParentPhone, "", 0, "L", false, 0, "")
+	pdf.CellFormat(90, 6, "生成时间："+s.GeneratedAt.Format("2006-01-02 15:04"), "", 1, "L", false, 0, "")
+
+	// 本月课程
+	pdfSection(pdf, "本月课程")
+	lessonColumns := []pdfColumn{
+		{"日期", 26, "C"}, {"时间", 26, "C"}, {"科目", 38, "L"}, {"状态", 18, "C"},
+		{"课时", 18, "R"}, {"课时包扣减", 28, "R"}, {"费用", 26, "R"},
+	}
+	pdfTableHeader(pdf, lessonColumns)
+	for _, l := range s.Lessons {
+		deducted := ""
+		if l.Deducted > 0 {
+			deducted = formatAmount(l.Deducted) + packageUnitLabels[l.Unit]
+		}
+		charge := ""
+		if l.Charge > 0 {
+			charge = formatMoney(l.Charge)
+		}
+		pdfTableRow(pdf, lessonColumns, []string{
+			l.Schedule.StartTime.Format("2006-01-02"),
+			l.Schedule.StartTime.Format("15:04") + "-" + l.Schedule.EndTime.Format("15:04"),
+			l.Schedule.Course.Name,
+			scheduleStatusLabels[l.Schedule.Status],
+			formatAmount(l.Hours),
+			deducted,
+			charge,
+		})
+	}
+	if len(s.Lessons) == 0 {
+		pdfEmptyRow(pdf, "本月无课程")
+	}
+	pdfSummary(pdf, fmt.Sprintf("已完成课时 %s 小时，课程费用 %s", formatAmount(s.Hours), formatMoney(s.Charges)))
+
+	// 本月收款
+	pdfSection(pdf, "本月收款")
+	paymentColumns := []pdfColumn{
+		{"日期", 36, "C"}, {"方式", 28, "C"}, {"金额", 36, "R"}, {"单号 / 备注", 80, "L"},
+	}
+	pdfTableHeader(pdf, paymentColumns)
+	for _, p := range s.Payments {
+		note := p.Reference
+		if p.Notes != "" {
+			if note != "" {
+				note += " / "
+			}
+			note += p.Notes
+		}
+		pdfTableRow(pdf, paymentColumns, []string{
+			p.PaidAt.Format("2006-01-02"),
+			paymentMethodLabels[p.Method],
+			formatMoney(p.Amount),
+			note,
+		})
+	}
+	if len(s.Payments) == 0 {
+		pdfEmptyRow(pdf, "本月无收款")
+	}
+	pdfSummary(pdf, fmt.Sprintf("本月收款合计 %s，当前未结金额 %s", formatMoney(s.PaidTotal), formatMoney(s.Outstanding)))
+
+	// 课时包余额
+	pdfSection(pdf, "课时包余额")
+	balanceColumns := []pdfColumn{
+		{"科目", 70, "L"}, {"剩余", 50, "R"}, {"状态", 60, "C"},
+	}
+	pdfTableHeader(pdf, balanceColumns)
+	for _, b := range s.Balances {
+		status := "正常"
+		if b.LowBalance {
+			status = "余额不足，请及时续费"
+		}
+		pdfTableRow(pdf, balanceColumns, []string{
+			b.CourseName,
+			formatAmount(b.Balance) + packageUnitLabels[b.Unit],
+			status,
+		})
+	}
+	if len(s.Balances) == 0 {
+		pdfEmptyRow(pdf, "暂无有效课时包")
+	}
+
+	// 最近考试成绩
+	pdfSection(pdf, "最近考试成绩")
+	examColumns := []pdfColumn{
+		{"科目", 40, "L"}, {"考试", 70, "L"}, {"日期", 34, "C"}, {"成绩", 36, "R"},
+	}
+	pdfTableHeader(pdf, examColumns)
+	for _, e := range s.Exams {
+		course := ""
+		if e.Course != nil {
+			course = e.Course.Name
+		}
+		pdfTableRow(pdf, examColumns, []string{
+			course,
+			e.ExamName,
+			e.ExamDate.Format("2006-01-02"),
+			formatAmount(e.Score) + " / " + formatAmount(e.FullScore),
+		})
+	}
+	if len(s.Exams) == 0 {
+		pdfEmptyRow(pdf, "暂无考试记录")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func pdfSection(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(5)
+	pdf.SetFont("noto", "", 12)
+	pdf.CellFormat(0, 8, title, "B", 1, "L", false, 0, "")
+	pdf.Ln(1)
+}
+
+func pdfTableHeader(pdf *fpdf.Fpdf, columns []pdfColumn) {
+	pdf.SetFont("noto", "", 9)
+	pdf.SetFillColor(240, 240, 240)
+	for _, col := range columns {
+		pdf.CellFormat(col.width, 7, col.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func pdfTableRow(pdf *fpdf.Fpdf, columns []pdfColumn, values []string) {
+	pdf.SetFont("noto", "", 9)
+	for i, col := range columns {
+		pdf.CellFormat(col.width, 6, values[i], "1", 0, col.align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func pdfEmptyRow(pdf *fpdf.Fpdf, text string) {
+	pdf.SetFont("noto", "", 9)
+	pdf.SetTextColor(128, 128, 128)
+	pdf.CellFormat(0, 6, text, "1", 1, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+func pdfSummary(pdf *fpdf.Fpdf, text string) {
+	pdf.SetFont("noto", "", 10)
+	pdf.CellFormat(0, 7, text, "", 1, "R", false, 0, "")
+}
+
+// formatAmount 去掉多余的小数位，如 1.50 -> 1.5
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(roundAmount(v), 'f', -1, 64)
+}
+
+// formatMoney 格式化金额，如 ¥375.00
+func formatMoney(v float64) string {
+	return fmt.Sprintf("¥%.2f", v)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestGetStudentStatement(t *testing.T) {
+	// 测试环境没有 fonts 目录时用 Go 字体代替，仍能覆盖完整的渲染流程
+	if len(chineseFont) == 0 {
+		chineseFont = goregular.TTF
+		t.Cleanup(func() { chineseFont = nil })
+	}
+
+	db := newTestDB(t, &models.Student{}, &models.Course{}, &models.Schedule{}, &models.TuitionRate{},
+		&models.LessonPackage{}, &models.PackageDeduction{}, &models.Invoice{}, &models.InvoiceItem{},
+		&models.Payment{}, &models.ExamResult{})
+	db.Create(&models.Student{Name: "张三", Grade: "初二", ParentPhone: "13800000000"})
+	db.Create(&models.Course{Name: "数学"})
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	for i, status := range []string{ScheduleCompleted, ScheduleCompleted, ScheduleCancelled} {
+		start := month.AddDate(0, 0, 7*i).Add(18 * time.Hour)
+		s := models.Schedule{Version: 1, StudentID: 1, CourseID: 1, StartTime: start, EndTime: start.Add(2 * time.Hour), Status: status}
+		if status == ScheduleCancelled {
+			s.CancelledBy, s.CancelledAt, s.LateCancel = "parent", &start, true
+		}
+		if err := db.Omit("Student", "Course").Create(&s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Omit("Student").Create(&models.Payment{Version: 1, StudentID: 1, Amount: 300, Method: "wechat", PaidAt: month.AddDate(0, 0, 10)})
+	db.Omit("Student", "Course").Create(&models.ExamResult{StudentID: 1, CourseID: 1, ExamType: "midterm", ExamName: "期中考试", Score: 128, FullScore: 150, ExamDate: month.AddDate(0, 0, 20)})
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"有课程的月份", "/students/1/statement.pdf?month=2024-03", http.StatusOK},
+		{"没有课程的月份", "/students/1/statement.pdf?month=2024-01", http.StatusOK},
+		{"学生不存在", "/students/9/statement.pdf?month=2024-03", http.StatusNotFound},
+		{"月份格式错误", "/students/1/statement.pdf?month=2024/03", http.StatusBadRequest},
+	}
+	r := gin.New()
+	r.GET("/students/:id/statement.pdf", NewStatementHandler(db, config.BillingConfig{LateCancelChargeRate: 1}).GetStudentStatement)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("GET = %d, want %d: %.200s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+				t.Errorf("body is not a PDF: %.20q", w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestRenderStatementPDFWithoutFont(t *testing.T) {
+	saved := chineseFont
+	chineseFont = nil
+	t.Cleanup(func() { chineseFont = saved })
+	if _, err := renderStatementPDF(Statement{Month: time.Now()}); err == nil {
+		t.Error("render without font succeeded, want error")
+	}
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"tutor-management/models"
+	"tutor-management/storage"
 	"tutor-management/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,29 +14,118 @@ import (
 )
 
 type StudentHandler struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Storage storage.Storage // 删除学生时一并删除其附件文件
 }
 
-func NewStudentHandler(db *gorm.DB) *StudentHandler {
-	return &StudentHandler{DB: db}
+func NewStudentHandler(db *gorm.DB, store storage.Storage) *StudentHandler {
+	return &StudentHandler{DB: db, Storage: store}
+}
+
+// CreateStudentRequest 创建学生请求
+type CreateStudentRequest struct {
+	Name        string `json:"name" binding:"required,notblank,max=50"`
+	ParentPhone string `json:"parent_phone" binding:"omitempty,cnmobile"`
+	Grade       string `json:"grade" binding:"max=20"`
+	Notes       string `json:"notes" binding:"max=2000"`
+}
+
+// UpdateStudentRequest 更新学生请求，未提供的字段保持不变
+type UpdateStudentRequest struct {
+	Name        *string `json:"name" binding:"omitempty,notblank,max=50"`
+	ParentPhone *string `json:"parent_phone" binding:"omitempty,cnmobile"`
+	Grade       *string `json:"grade" binding:"omitempty,max=20"`
+	Notes       *string `json:"notes" binding:"omitempty,max=2000"`
+}
+
+// StudentResponse 学生信息
+type StudentResponse struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     uint      `json:"version"`
+	Name        string    `json:"name"`
+	ParentPhone string    `json:"parent_phone"`
+	Grade       string    `json:"grade"`
+	Notes       string    `json:"notes"`
+}
+
+func (r CreateStudentRequest) toModel() models.Student {
+	return models.Student{
+		Version:     1,
+		Name:        r.Name,
+		ParentPhone: r.ParentPhone,
+		Grade:       r.Grade,
+		Notes:       r.Notes,
+	}
+}
+
+func (r UpdateStudentRequest) apply(s *models.Student) {
+	if r.Name != nil {
+		s.Name = *r.Name
+	}
+	if r.ParentPhone != nil {
+		s.ParentPhone = *r.ParentPhone
+	}
+	if r.Grade != nil {
+		s.Grade = *r.Grade
+	}
+	if r.Notes != nil {
+		s.Notes = *r.Notes
+	}
+}
+
+func newStudentResponse(s models.Student) StudentResponse {
+	return StudentResponse{
+		ID:          s.ID,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+		Version:     s.Version,
+		Name:        s.Name,
+		ParentPhone: s.ParentPhone,
+		Grade:       s.Grade,
+		Notes:       s.Notes,
+	}
+}
+
+// studentListSpec 学生列表允许的排序与筛选字段
+var studentListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"grade":      "grade",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "id ASC",
+	Filters:     map[string]string{"grade": "grade"},
+	LikeFilters: map[string]string{"name": "name", "parent_phone": "parent_phone"},
 }
 
 // GetAll 获取所有学生
 // @Summary 获取所有学生
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
 // @Tags 学生管理
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {array} models.Student
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Param cursor query int false "游标（上一页最后一条的ID）"
+// @Param sort query string false "排序字段: id, name, grade, created_at, updated_at（前缀 - 表示倒序）"
+// @Param order query string false "排序方向: asc, desc"
+// @Param name query string false "姓名（模糊匹配，% 与 _ 按字面匹配）"
+// @Param parent_phone query string false "家长电话（模糊匹配，% 与 _ 按字面匹配）"
+// @Param grade query string false "年级"
+// @Success 200 {array} StudentResponse
+// @Failure 400 {object} map[string]string
 // @Router /students [get]
 func (h *StudentHandler) GetAll(c *gin.Context) {
-	var students []models.Student
-	if err := h.DB.Find(&students).Error; err != nil {
+	count, err := respondList(c, h.DB, studentListSpec, newStudentResponse)
+	if err != nil {
 		utils.Error("Failed to fetch students", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	utils.Debug("Fetched all students", zap.Int("count", len(students)))
-	c.JSON(http.StatusOK, students)
+	utils.Info("Fetched students", zap.Int("count", count))
 }
 
 // Create 创建学生
@@ -43,17 +134,18 @@ func (h *StudentHandler) GetAll(c *gin.Context) {
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param student body models.Student true "学生信息"
-// @Success 201 {object} models.Student
+// @Param student body CreateStudentRequest true "学生信息"
+// @Success 201 {object} StudentResponse
 // @Failure 400 {object} map[string]string
 // @Router /students [post]
 func (h *StudentHandler) Create(c *gin.Context) {
-	var student models.Student
-	if err := c.ShouldBindJSON(&student); err != nil {
+	var req CreateStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.Warn("Invalid student data", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
+	student := req.toModel()
 	if err := h.DB.Create(&student).Error; err != nil {
 		utils.Error("Failed to create student", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -63,20 +155,46 @@ func (h *StudentHandler) Create(c *gin.Context) {
 		zap.Uint("student_id", student.ID),
 		zap.String("name", student.Name),
 	)
-	c.JSON(http.StatusCreated, student)
+	respondVersioned(c, http.StatusCreated, student.Version, newStudentResponse(student))
+}
+
+// Get 获取学生详情
+// @Summary 获取学生详情
+// @Description 响应携带 ETag，If-None-Match 命中时返回 304
+// @Tags 学生管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "学生ID"
+// @Success 200 {object} StudentResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /students/{id} [get]
+func (h *StudentHandler) Get(c *gin.Context) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, student.Version, newStudentResponse(student))
 }
 
 // Update 更新学生
 // @Summary 更新学生信息
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412
 // @Tags 学生管理
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "学生ID"
-// @Param student body models.Student true "学生信息"
-// @Success 200 {object} models.Student
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param student body UpdateStudentRequest true "学生信息"
+// @Success 200 {object} StudentResponse
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /students/{id} [put]
+// @Router /students/{id} [patch]
 func (h *StudentHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var student models.Student
@@ -85,37 +203,67 @@ func (h *StudentHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
 		return
 	}
-	if err := c.ShouldBindJSON(&student); err != nil {
+	if !checkIfMatch(c, student.Version) {
+		return
+	}
+	var req UpdateStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.Warn("Invalid update data", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, validationError(err))
 		return
 	}
-	if err := h.DB.Save(&student).Error; err != nil {
+	req.apply(&student)
+	if err := saveVersioned(h.DB, &student, &student.Version); err != nil {
 		utils.Error("Failed to update student", zap.String("id", id), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondWriteError(c, err)
 		return
 	}
 	utils.Info("Student updated",
 		zap.Uint("student_id", student.ID),
 		zap.String("name", student.Name),
 	)
-	c.JSON(http.StatusOK, student)
+	respondVersioned(c, http.StatusOK, student.Version, newStudentResponse(student))
 }
 
 // Delete 删除学生
 // @Summary 删除学生
+// @Description 学生的附件与学习目标一并删除
 // @Tags 学生管理
 // @Security BearerAuth
 // @Param id path int true "学生ID"
+// @Param If-Match header string true "当前版本的 ETag"
 // @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /students/{id} [delete]
 func (h *StudentHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.DB.Delete(&models.Student{}, id).Error; err != nil {
+	var student models.Student
+	if err := h.DB.First(&student, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+	if !checkIfMatch(c, student.Version) {
+		return
+	}
+	var attachments []models.Attachment
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if attachments, err = deleteOwnerAttachments(tx, AttachmentOwnerStudent, []uint{student.ID}); err != nil {
+			return err
+		}
+		if err := tx.Where("student_id = ?", student.ID).Delete(&models.Goal{}).Error; err != nil {
+			return err
+		}
+		return deleteVersioned(tx, &student, student.Version)
+	})
+	if err != nil {
 		utils.Error("Failed to delete student", zap.String("id", id), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondWriteError(c, err)
 		return
 	}
+	removeAttachmentObjects(h.Storage, attachments)
 	utils.Info("Student deleted", zap.String("id", id))
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
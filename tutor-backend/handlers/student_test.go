@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStudentGetAllLikeFilter(t *testing.T) {
+	db := newTestDB(t, &models.Student{})
+	for _, name := range []string{"张三", "张_三", "张%三", "张!三", "李四"} {
+		db.Create(&models.Student{Name: name})
+	}
+	r := gin.New()
+	r.GET("/students", NewStudentHandler(db, nil).GetAll)
+
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"张", []string{"张三", "张_三", "张%三", "张!三"}},
+		{"_", []string{"张_三"}},
+		{"%", []string{"张%三"}},
+		{"!", []string{"张!三"}},
+		{"张_", []string{"张_三"}},
+		{"!_", nil},
+		{"王", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/students?name="+url.QueryEscape(tt.keyword), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body.String())
+			}
+			var got []StudentResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d students, want %v", len(got), tt.want)
+			}
+			for i, s := range got {
+				if s.Name != tt.want[i] {
+					t.Errorf("students[%d] = %q, want %q", i, s.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStudentGetAllQueryError(t *testing.T) {
+	db := newTestDB(t)
+	r := gin.New()
+	r.GET("/students", NewStudentHandler(db, nil).GetAll)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/students", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("GET = %d, want 500", w.Code)
+	}
+}
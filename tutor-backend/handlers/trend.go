@@ -1,141 +1,492 @@
 package handlers
 
 import (
-	"log"
+	"fmt"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/vicanso/go-charts/v2"
+	"gorm.io/gorm"
 )
 
-func init() {
-	// 加载中文字体
-	fontPath := filepath.Join("fonts", "NotoSansSC-Regular.ttf")
-	fontData, err := os.ReadFile(fontPath)
-	if err != nil {
-		log.Printf("警告: 无法加载字体文件 %s: %v", fontPath, err)
+// maxCompareStudents 对比图最多包含的学生数
+const maxCompareStudents = 10
+
+// TrendHandler 成绩趋势分析处理器
+type TrendHandler struct {
+	DB     *gorm.DB
+	Cache  *ChartCache
+	Config config.ChartConfig
+}
+
+// NewTrendHandler 创建趋势分析处理器
+func NewTrendHandler(db *gorm.DB, cache *ChartCache, cfg config.ChartConfig) *TrendHandler {
+	return &TrendHandler{DB: db, Cache: cache, Config: cfg}
+}
+
+// examPoint 横轴上的一次考试及对应数值
+type examPoint struct {
+	Name  string
+	Date  time.Time
+	Value float64
+}
+
+// namedPoints 一条待对齐的序列
+type namedPoints struct {
+	Name   string
+	Points []examPoint
+}
+
+// GetTrendChart 生成成绩变化折线图
+// @Summary 成绩趋势折线图
+// @Description 按科目绘制历次考试的得分率，不同科目按考试名称与日期对齐到同一横轴；设有学习目标的科目另绘制目标得分率线，
+// @Description 指定 exam_type 时只绘制该类型的目标与不限考试类型的目标
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Produce png,image/svg+xml,json
+// @Param student_id path int true "学生ID"
+// @Param course_id query int false "科目ID，不传则绘制全部科目"
+// @Param exam_type query string false "考试类型: midterm, final, quiz，不传则包含全部考试"
+// @Param format query string false "输出格式: png（默认）, svg, json（图表数据）"
+// @Param width query int false "宽度 200-2000，默认 800"
+// @Param height query int false "高度 150-1500，默认 500"
+// @Param theme query string false "主题: light（默认）, dark"
+// @Param expires query int false "签名地址的过期时间（Unix 秒），与 sig 一起使用时无需 token"
+// @Param sig query string false "签名，由 /analysis/signed-url 生成"
+// @Success 200 {object} ChartData "format=json 时"
+// @Success 304
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /analysis/{student_id}/trend.png [get]
+func (h *TrendHandler) GetTrendChart(c *gin.Context) {
+	h.serveChart(c, []uint{studentIDParam(c)}, func() (ChartData, bool) {
+		student, results, ok := h.loadStudentResults(c)
+		if !ok {
+			return ChartData{}, false
+		}
+
+		var series []namedPoints
+		for _, group := range groupByCourse(results) {
+			series = append(series, namedPoints{Name: courseName(group[0]), Points: percentagePoints(group)})
+		}
+		labels, chartSeries := alignExamSeries(series)
+		goals, err := studentGoals(h.DB, student.ID, c.Query("course_id"), c.Query("exam_type"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return ChartData{}, false
+		}
+		chartSeries = append(chartSeries, goalSeries(goals, len(labels))...)
+		return ChartData{
+			Type:      ChartLine,
+			Title:     student.Name + " 阶段成绩进步趋势",
+			Labels:    labels,
+			Series:    chartSeries,
+			Min:       floatPtr(0),
+			Max:       floatPtr(100),
+			MarkAvg:   true,
+			ShowLabel: true,
+		}, true
+	})
+}
+
+// GetRadarChart 生成多科目雷达图
+// @Summary 多科目雷达图
+// @Description 各科目最近一次考试与历次平均的得分率，至少需要 3 个科目的成绩
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Produce png,image/svg+xml,json
+// @Param student_id path int true "学生ID"
+// @Param exam_type query string false "考试类型: midterm, final, quiz，不传则包含全部考试"
+// @Param format query string false "输出格式: png（默认）, svg, json（图表数据）"
+// @Param width query int false "宽度 200-2000，默认 800"
+// @Param height query int false "高度 150-1500，默认 500"
+// @Param theme query string false "主题: light（默认）, dark"
+// @Param expires query int false "签名地址的过期时间（Unix 秒），与 sig 一起使用时无需 token"
+// @Param sig query string false "签名，由 /analysis/signed-url 生成"
+// @Success 200 {object} ChartData "format=json 时"
+// @Success 304
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /analysis/{student_id}/radar.png [get]
+func (h *TrendHandler) GetRadarChart(c *gin.Context) {
+	h.serveChart(c, []uint{studentIDParam(c)}, func() (ChartData, bool) {
+		student, results, ok := h.loadStudentResults(c)
+		if !ok {
+			return ChartData{}, false
+		}
+		groups := groupByCourse(results)
+		if len(groups) < 3 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "雷达图至少需要 3 个科目的成绩"})
+			return ChartData{}, false
+		}
+
+		labels := make([]string, len(groups))
+		latest := ChartSeries{Name: "最近一次", Values: make([]*float64, len(groups))}
+		mean := ChartSeries{Name: "历次平均", Values: make([]*float64, len(groups))}
+		for i, group := range groups {
+			labels[i] = courseName(group[0])
+			stats := scoreStats(percentageValues(group))
+			latest.Values[i] = floatPtr(stats.Latest)
+			mean.Values[i] = floatPtr(stats.Mean)
+		}
+		return ChartData{
+			Type:   ChartRadar,
+			Title:  student.Name + " 各科成绩",
+			Labels: labels,
+			Series: []ChartSeries{latest, mean},
+			Max:    floatPtr(100),
+		}, true
+	})
+}
+
+// GetDeltaChart 生成成绩变化柱状图
+// @Summary 考试间成绩变化柱状图
+// @Description 每次考试相对同科目上一次考试的得分率变化（百分点）
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Produce png,image/svg+xml,json
+// @Param student_id path int true "学生ID"
+// @Param course_id query int false "科目ID，不传则绘制全部科目"
+// @Param exam_type query string false "考试类型: midterm, final, quiz，不传则包含全部考试"
+// @Param format query string false "输出格式: png（默认）, svg, json（图表数据）"
+// @Param width query int false "宽度 200-2000，默认 800"
+// @Param height query int false "高度 150-1500，默认 500"
+// @Param theme query string false "主题: light（默认）, dark"
+// @Param expires query int false "签名地址的过期时间（Unix 秒），与 sig 一起使用时无需 token"
+// @Param sig query string false "签名，由 /analysis/signed-url 生成"
+// @Success 200 {object} ChartData "format=json 时"
+// @Success 304
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /analysis/{student_id}/delta.png [get]
+func (h *TrendHandler) GetDeltaChart(c *gin.Context) {
+	h.serveChart(c, []uint{studentIDParam(c)}, func() (ChartData, bool) {
+		student, results, ok := h.loadStudentResults(c)
+		if !ok {
+			return ChartData{}, false
+		}
+
+		var series []namedPoints
+		for _, group := range groupByCourse(results) {
+			points := percentagePoints(group)
+			if len(points) < 2 {
+				continue
+			}
+			deltas := make([]examPoint, 0, len(points)-1)
+			for i := 1; i < len(points); i++ {
+				delta := points[i]
+				delta.Value = math.Round((points[i].Value-points[i-1].Value)*100) / 100
+				deltas = append(deltas, delta)
+			}
+			series = append(series, namedPoints{Name: courseName(group[0]), Points: deltas})
+		}
+		if len(series) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要同一科目的两次考试成绩"})
+			return ChartData{}, false
+		}
+		labels, chartSeries := alignExamSeries(series)
+		return ChartData{
+			Type:      ChartBar,
+			Title:     student.Name + " 成绩变化（较上次）",
+			Labels:    labels,
+			Series:    chartSeries,
+			ShowLabel: true,
+		}, true
+	})
+}
+
+// GetComparisonChart 生成多学生成绩对比图
+// @Summary 多学生成绩对比折线图
+// @Description 同一科目下多个学生历次考试的得分率，按考试名称与日期对齐
+// @Tags 成绩分析
+// @Security BearerAuth
+// @Produce png,image/svg+xml,json
+// @Param student_ids query string true "学生ID，逗号分隔，2-10 个"
+// @Param course_id query int true "科目ID"
+// @Param format query string false "输出格式: png（默认）, svg, json（图表数据）"
+// @Param width query int false "宽度 200-2000，默认 800"
+// @Param height query int false "高度 150-1500，默认 500"
+// @Param theme query string false "主题: light（默认）, dark"
+// @Param expires query int false "签名地址的过期时间（Unix 秒），与 sig 一起使用时无需 token"
+// @Param sig query string false "签名，由 /analysis/signed-url 生成"
+// @Success 200 {object} ChartData "format=json 时"
+// @Success 304
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /analysis/compare.png [get]
+func (h *TrendHandler) GetComparisonChart(c *gin.Context) {
+	var ids []uint
+	seen := map[uint]bool{}
+	for _, raw := range strings.Split(c.Query("student_ids"), ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, fieldError("student_ids", "必须是逗号分隔的学生ID"))
+			return
+		}
+		if !seen[uint(id)] {
+			seen[uint(id)] = true
+			ids = append(ids, uint(id))
+		}
+	}
+	if len(ids) < 2 || len(ids) > maxCompareStudents {
+		c.JSON(http.StatusBadRequest, fieldError("student_ids", "需要 2-10 个学生"))
 		return
 	}
+	h.serveChart(c, ids, func() (ChartData, bool) {
+		var course models.Course
+		if err := h.DB.First(&course, c.Query("course_id")).Error; err != nil {
+			c.JSON(http.StatusBadRequest, fieldError("course_id", "科目不存在"))
+			return ChartData{}, false
+		}
 
-	err = charts.InstallFont("noto", fontData)
+		var students []models.Student
+		if err := h.DB.Where("id IN ?", ids).Find(&students).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return ChartData{}, false
+		}
+		if len(students) != len(ids) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+			return ChartData{}, false
+		}
+		var results []models.ExamResult
+		err := h.DB.Where("student_id IN ? AND course_id = ?", ids, course.ID).
+			Order("exam_date ASC, id ASC").
+			Find(&results).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return ChartData{}, false
+		}
+		byStudent := map[uint][]models.ExamResult{}
+		for _, r := range results {
+			byStudent[r.StudentID] = append(byStudent[r.StudentID], r)
+		}
+
+		// 序列顺序与请求中的学生顺序一致
+		names := make(map[uint]string, len(students))
+		for _, s := range students {
+			names[s.ID] = s.Name
+		}
+		var series []namedPoints
+		for _, id := range ids {
+			if len(byStudent[id]) > 0 {
+				series = append(series, namedPoints{Name: names[id], Points: percentagePoints(byStudent[id])})
+			}
+		}
+		if len(series) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "暂无成绩数据"})
+			return ChartData{}, false
+		}
+		labels, chartSeries := alignExamSeries(series)
+		return ChartData{
+			Type:   ChartLine,
+			Title:  course.Name + " 成绩对比",
+			Labels: labels,
+			Series: chartSeries,
+			Min:    floatPtr(0),
+			Max:    floatPtr(100),
+		}, true
+	})
+}
+
+// serveChart 输出图表，渲染结果按请求参数与相关学生的成绩版本缓存
+// build 负责读取数据，失败时已写入响应并返回 false
+func (h *TrendHandler) serveChart(c *gin.Context, studentIDs []uint, build func() (ChartData, bool)) {
+	options, err := parseChartOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stamp, err := h.resultsStamp(studentIDs)
 	if err != nil {
-		log.Printf("警告: 安装字体失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	key := c.Request.URL.Path + "?" + chartQuery(c.Request.URL.Query()) + "#" + stamp
+	c.Header("Cache-Control", "private, max-age=300")
+	if contentType, body, ok := h.Cache.Get(key); ok {
+		c.Header("X-Cache", "HIT")
+		respondDataWithETag(c, contentType, body)
 		return
 	}
 
-	// 获取字体并设置为默认
-	font, err := charts.GetFont("noto")
+	data, ok := build()
+	if !ok {
+		return
+	}
+	contentType, body, err := encodeChart(data, options)
 	if err != nil {
-		log.Printf("警告: 获取字体失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成图表失败: " + err.Error()})
 		return
 	}
-	charts.SetDefaultFont(font)
-	log.Println("中文字体加载成功")
+	h.Cache.Put(key, studentIDs, contentType, body)
+	c.Header("X-Cache", "MISS")
+	respondDataWithETag(c, contentType, body)
 }
 
-// TrendHandler 成绩趋势分析处理器
-type TrendHandler struct{}
+// resultsStamp 学生图表数据的版本标识：成绩、学生及相关科目的条数与最近修改时间，
+// 任一成绩增删改或学生、科目改名、删除后都会变化
+func (h *TrendHandler) resultsStamp(studentIDs []uint) (string, error) {
+	resultCourses := h.DB.Model(&models.ExamResult{}).Select("course_id").Where("student_id IN ?", studentIDs)
+	goalCourses := h.DB.Model(&models.Goal{}).Select("course_id").Where("student_id IN ?", studentIDs)
+	scopes := []func() *gorm.DB{
+		func() *gorm.DB { return h.DB.Model(&models.ExamResult{}).Where("student_id IN ?", studentIDs) },
+		func() *gorm.DB { return h.DB.Model(&models.Student{}).Where("id IN ?", studentIDs) },
+		func() *gorm.DB {
+			return h.DB.Model(&models.Course{}).Where("id IN (?) OR id IN (?)", resultCourses, goalCourses)
+		},
+	}
+	parts := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		var count int64
+		if err := scope().Count(&count).Error; err != nil {
+			return "", err
+		}
+		var latest struct{ UpdatedAt time.Time }
+		if count > 0 {
+			if err := scope().Select("updated_at").Order("updated_at DESC").Limit(1).Find(&latest).Error; err != nil {
+				return "", err
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d-%d", count, latest.UpdatedAt.UnixNano()))
+	}
+	return strings.Join(parts, "."), nil
+}
 
-// NewTrendHandler 创建趋势分析处理器
-func NewTrendHandler() *TrendHandler {
-	return &TrendHandler{}
+// studentIDParam 解析路径中的学生ID，无效时为 0
+func studentIDParam(c *gin.Context) uint {
+	id, _ := strconv.ParseUint(c.Param("student_id"), 10, 64)
+	return uint(id)
 }
 
-// GetTrendChart 生成成绩变化折线图
-// GET /api/analysis/:student_id/trend.png?subject=math
-func (h *TrendHandler) GetTrendChart(c *gin.Context) {
-	// studentID := c.Param("student_id") // 可用于从数据库查询真实数据
-	subject := c.Query("subject")
-
-	// X 轴 - 考试名称
-	xAxisData := []string{"摸底考", "第一次月考", "期中考", "第三次月考", "期末模拟", "期末考"}
-
-	// 构建数据
-	var values [][]float64
-	var legendLabels []string
-
-	if subject != "" {
-		// 指定科目时，只展示该科目的数据
-		switch subject {
-		case "math":
-			legendLabels = []string{"数学"}
-			values = [][]float64{{65, 72, 70, 85, 88, 92}}
-		case "english":
-			legendLabels = []string{"英语"}
-			values = [][]float64{{80, 82, 85, 88, 90, 91}}
-		default:
-			legendLabels = []string{"数学"}
-			values = [][]float64{{65, 72, 70, 85, 88, 92}}
-		}
-	} else {
-		// 未指定科目时，展示数学和英语两条线对比
-		legendLabels = []string{"数学", "英语"}
-		values = [][]float64{
-			{65, 72, 70, 85, 88, 92}, // 数学
-			{80, 82, 85, 88, 90, 91}, // 英语
-		}
-	}
-
-	// 创建折线图
-	p, err := charts.LineRender(
-		values,
-		charts.TitleTextOptionFunc("阶段成绩进步趋势"),
-		charts.ThemeOptionFunc(charts.ThemeLight),
-		charts.XAxisDataOptionFunc(xAxisData),
-		charts.LegendOptionFunc(charts.LegendOption{
-			Show: charts.TrueFlag(),
-			Data: legendLabels,
-		}),
-		// 设置图表尺寸
-		charts.WidthOptionFunc(800),
-		charts.HeightOptionFunc(500),
-		// 设置背景色为白色
-		charts.BackgroundColorOptionFunc(charts.Color{R: 255, G: 255, B: 255, A: 255}),
-		// Y 轴配置
-		charts.YAxisOptionFunc(charts.YAxisOption{
-			Min: floatPtr(0),
-			Max: floatPtr(100),
-		}),
-		// 设置内边距
-		charts.PaddingOptionFunc(charts.Box{
-			Top:    50,
-			Right:  40,
-			Bottom: 30,
-			Left:   50,
-		}),
-		// 自定义渲染选项
-		func(opt *charts.ChartOption) {
-			// 设置平滑曲线和显示标签
-			for i := range opt.SeriesList {
-				opt.SeriesList[i].Label.Show = true
-				opt.SeriesList[i].Label.FontSize = 11
-
-				// 添加标记线 - 及格线和优秀线
-				opt.SeriesList[i].MarkLine = charts.NewMarkLine(
-					charts.SeriesMarkDataTypeAverage,
-				)
+// loadStudentResults 读取路径中学生的成绩（可按 course_id、exam_type 筛选），按考试日期升序；失败时已写入响应
+func (h *TrendHandler) loadStudentResults(c *gin.Context) (models.Student, []models.ExamResult, bool) {
+	var student models.Student
+	if err := h.DB.First(&student, c.Param("student_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return student, nil, false
+	}
+	query := h.DB.Preload("Course").Where("student_id = ?", student.ID)
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if examType := c.Query("exam_type"); examType != "" {
+		query = query.Where("exam_type = ?", examType)
+	}
+	var results []models.ExamResult
+	if err := query.Order("exam_date ASC, id ASC").Find(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return student, nil, false
+	}
+	if len(results) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "暂无成绩数据"})
+		return student, nil, false
+	}
+	return student, results, true
+}
+
+// alignExamSeries 将多条序列按考试（名称 + 日期）对齐到同一横轴，缺少的点为空
+// 同名考试出现多次时在标签后附加日期以便区分
+func alignExamSeries(series []namedPoints) ([]string, []ChartSeries) {
+	type axisKey struct {
+		name string
+		date string
+	}
+	index := map[axisKey]int{}
+	var axis []examPoint
+	for _, s := range series {
+		for _, p := range s.Points {
+			key := axisKey{p.Name, p.Date.Format("2006-01-02")}
+			if _, ok := index[key]; !ok {
+				index[key] = -1
+				axis = append(axis, p)
 			}
-		},
-	)
+		}
+	}
+	sort.SliceStable(axis, func(i, j int) bool { return axis[i].Date.Before(axis[j].Date) })
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成图表失败: " + err.Error()})
-		return
+	nameCount := map[string]int{}
+	for _, p := range axis {
+		nameCount[p.Name]++
+	}
+	labels := make([]string, len(axis))
+	for i, p := range axis {
+		index[axisKey{p.Name, p.Date.Format("2006-01-02")}] = i
+		labels[i] = p.Name
+		if nameCount[p.Name] > 1 {
+			labels[i] += " " + p.Date.Format("01-02")
+		}
 	}
 
-	// 渲染为 PNG
-	buf, err := p.Bytes()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "渲染图片失败: " + err.Error()})
-		return
+	result := make([]ChartSeries, len(series))
+	for i, s := range series {
+		result[i] = ChartSeries{Name: s.Name, Values: make([]*float64, len(axis))}
+		for _, p := range s.Points {
+			result[i].Values[index[axisKey{p.Name, p.Date.Format("2006-01-02")}]] = floatPtr(p.Value)
+		}
+	}
+	return labels, result
+}
+
+// goalSeries 每个目标一条水平的目标得分率线；名称相同的目标在名称后附加截止日期
+func goalSeries(goals []models.Goal, points int) []ChartSeries {
+	names := make([]string, len(goals))
+	nameCount := map[string]int{}
+	for i, g := range goals {
+		names[i] = strconv.FormatUint(uint64(g.CourseID), 10)
+		if g.Course != nil {
+			names[i] = g.Course.Name
+		}
+		names[i] += examTypeLabels[g.ExamType] + "目标"
+		nameCount[names[i]]++
 	}
+	result := make([]ChartSeries, len(goals))
+	for i, g := range goals {
+		if nameCount[names[i]] > 1 {
+			names[i] += " " + g.Deadline.In(time.Local).Format("01-02")
+		}
+		result[i] = ChartSeries{Name: names[i], Values: make([]*float64, points), Goal: true}
+		target := goalPercentage(g)
+		for j := range result[i].Values {
+			result[i].Values[j] = floatPtr(target)
+		}
+	}
+	return result
+}
 
-	// 返回 PNG 图片
-	c.Header("Content-Type", "image/png")
-	c.Header("Cache-Control", "max-age=3600")
-	c.Data(http.StatusOK, "image/png", buf)
+func percentagePoints(results []models.ExamResult) []examPoint {
+	points := make([]examPoint, len(results))
+	for i, r := range results {
+		points[i] = examPoint{Name: r.ExamName, Date: r.ExamDate, Value: scorePercentage(r)}
+	}
+	return points
+}
+
+func percentageValues(results []models.ExamResult) []float64 {
+	values := make([]float64, len(results))
+	for i, r := range results {
+		values[i] = scorePercentage(r)
+	}
+	return values
+}
+
+func courseName(r models.ExamResult) string {
+	if r.Course != nil {
+		return r.Course.Name
+	}
+	return strconv.FormatUint(uint64(r.CourseID), 10)
 }
 
 // floatPtr 返回 float64 指针
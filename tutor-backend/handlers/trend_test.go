@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"tutor-management/config"
+	"tutor-management/models"
+
+	"gorm.io/gorm"
+)
+
+func TestResultsStampChanges(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		change func(db *gorm.DB) error
+	}{
+		{"新增成绩", func(db *gorm.DB) error {
+			return db.Omit("Student", "Course").Create(&models.ExamResult{StudentID: 1, CourseID: 1, Score: 80, FullScore: 100, ExamDate: time.Now()}).Error
+		}},
+		{"学生改名", func(db *gorm.DB) error {
+			return db.Model(&models.Student{ID: 1}).Updates(map[string]any{"name": "张三丰", "updated_at": later}).Error
+		}},
+		{"成绩科目改名", func(db *gorm.DB) error {
+			return db.Model(&models.Course{ID: 1}).Updates(map[string]any{"name": "高等数学", "updated_at": later}).Error
+		}},
+		{"目标科目改名", func(db *gorm.DB) error {
+			return db.Model(&models.Course{ID: 2}).Updates(map[string]any{"name": "英语口语", "updated_at": later}).Error
+		}},
+		{"删除科目", func(db *gorm.DB) error { return db.Delete(&models.Course{}, 1).Error }},
+		{"删除学生", func(db *gorm.DB) error { return db.Delete(&models.Student{}, 1).Error }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Student{}, &models.Course{}, &models.ExamResult{}, &models.Goal{})
+			db.Create(&models.Student{Name: "张三"})
+			db.Create(&[]models.Course{{Name: "数学"}, {Name: "英语"}, {Name: "物理"}})
+			db.Omit("Student", "Course").Create(&models.ExamResult{StudentID: 1, CourseID: 1, Score: 90, FullScore: 100, ExamDate: time.Now()})
+			db.Omit("Student", "Course").Create(&models.Goal{StudentID: 1, CourseID: 2, TargetScore: 90, FullScore: 100, Deadline: time.Now()})
+			h := NewTrendHandler(db, nil, config.ChartConfig{})
+
+			before, err := h.resultsStamp([]uint{1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.change(db); err != nil {
+				t.Fatal(err)
+			}
+			after, err := h.resultsStamp([]uint{1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before == after {
+				t.Errorf("stamp unchanged: %s", after)
+			}
+		})
+	}
+}
+
+func TestResultsStampIgnoresUnrelatedCourses(t *testing.T) {
+	db := newTestDB(t, &models.Student{}, &models.Course{}, &models.ExamResult{}, &models.Goal{})
+	db.Create(&models.Student{Name: "张三"})
+	db.Create(&[]models.Course{{Name: "数学"}, {Name: "物理"}})
+	db.Omit("Student", "Course").Create(&models.ExamResult{StudentID: 1, CourseID: 1, Score: 90, FullScore: 100, ExamDate: time.Now()})
+	h := NewTrendHandler(db, nil, config.ChartConfig{})
+
+	before, _ := h.resultsStamp([]uint{1})
+	db.Model(&models.Course{ID: 2}).Updates(map[string]any{"name": "化学", "updated_at": time.Now().Add(time.Hour)})
+	after, _ := h.resultsStamp([]uint{1})
+	if before != after {
+		t.Errorf("stamp changed after renaming an unrelated course: %s -> %s", before, after)
+	}
+}
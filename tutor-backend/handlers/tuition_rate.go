@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"tutor-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RateHandler struct {
+	DB *gorm.DB
+}
+
+func NewRateHandler(db *gorm.DB) *RateHandler {
+	return &RateHandler{DB: db}
+}
+
+// CreateRateRequest 创建课时单价请求
+type CreateRateRequest struct {
+	CourseID   uint    `json:"course_id" binding:"required"`
+	Grade      string  `json:"grade" binding:"max=20"`
+	StudentID  uint    `json:"student_id"`
+	HourlyRate float64 `json:"hourly_rate" binding:"gte=0"`
+	Notes      string  `json:"notes" binding:"max=500"`
+}
+
+// UpdateRateRequest 更新课时单价请求，未提供的字段保持不变
+type UpdateRateRequest struct {
+	Grade      *string  `json:"grade" binding:"omitempty,max=20"`
+	StudentID  *uint    `json:"student_id"`
+	HourlyRate *float64 `json:"hourly_rate" binding:"omitempty,gte=0"`
+	Notes      *string  `json:"notes" binding:"omitempty,max=500"`
+}
+
+// RateResponse 课时单价信息
+type RateResponse struct {
+	ID         uint            `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Version    uint            `json:"version"`
+	CourseID   uint            `json:"course_id"`
+	Course     *CourseResponse `json:"course,omitempty"`
+	Grade      string          `json:"grade"`
+	StudentID  uint            `json:"student_id"`
+	HourlyRate float64         `json:"hourly_rate"`
+	Notes      string          `json:"notes"`
+}
+
+func (r CreateRateRequest) toModel() models.TuitionRate {
+	return models.TuitionRate{
+		Version:    1,
+		CourseID:   r.CourseID,
+		Grade:      r.Grade,
+		StudentID:  r.StudentID,
+		HourlyRate: r.HourlyRate,
+		Notes:      r.Notes,
+	}
+}
+
+func (r UpdateRateRequest) apply(rate *models.TuitionRate) {
+	if r.Grade != nil {
+		rate.Grade = *r.Grade
+	}
+	if r.StudentID != nil {
+		rate.StudentID = *r.StudentID
+	}
+	if r.HourlyRate != nil {
+		rate.HourlyRate = *r.HourlyRate
+	}
+	if r.Notes != nil {
+		rate.Notes = *r.Notes
+	}
+}
+
+func newRateResponse(r models.TuitionRate) RateResponse {
+	resp := RateResponse{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Version:    r.Version,
+		CourseID:   r.CourseID,
+		Grade:      r.Grade,
+		StudentID:  r.StudentID,
+		HourlyRate: r.HourlyRate,
+		Notes:      r.Notes,
+	}
+	if r.Course != nil {
+		course := newCourseResponse(*r.Course)
+		resp.Course = &course
+	}
+	return resp
+}
+
+// rateListSpec 课时单价列表允许的排序与筛选字段
+var rateListSpec = ListSpec{
+	SortFields: map[string]string{
+		"id":          "id",
+		"course_id":   "course_id",
+		"grade":       "grade",
+		"hourly_rate": "hourly_rate",
+	},
+	DefaultSort: "course_id ASC, student_id ASC, grade ASC",
+	Filters: map[string]string{
+		"course_id":  "course_id",
+		"grade":      "grade",
+		"student_id": "student_id",
+	},
+	Preloads: []string{"Course"},
+}
+
+// GetAll 获取课时单价列表
+// @Summary 获取课时单价列表
+// @Description 携带 page/page_size（或 current/pageSize）或 cursor 时返回分页结构，否则返回完整数组
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce json
+// @Param course_id query int false "课程ID"
+// @Param grade query string false "年级"
+// @Param student_id query int false "学生ID（0 表示通用价格）"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页条数"
+// @Success 200 {array} RateResponse
+// @Failure 400 {object} map[string]string
+// @Router /rates [get]
+func (h *RateHandler) GetAll(c *gin.Context) {
+	respondList(c, h.DB, rateListSpec, newRateResponse)
+}
+
+// Get 获取课时单价详情
+// @Summary 获取课时单价详情
+// @Tags 收费管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "单价ID"
+// @Success 200 {object} RateResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /rates/{id} [get]
+func (h *RateHandler) Get(c *gin.Context) {
+	var rate models.TuitionRate
+	if err := h.DB.Preload("Course").First(&rate, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "单价不存在"})
+		return
+	}
+	respondVersioned(c, http.StatusOK, rate.Version, newRateResponse(rate))
+}
+
+// Create 创建课时单价
+// @Summary 创建课时单价
+// @Description 指定 student_id 时为学生专属价格（此时不能指定年级）；否则按课程 + 年级定价，年级为空表示该课程的默认价格
+// @Tags 收费管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param rate body CreateRateRequest true "单价信息"
+// @Success 201 {object} RateResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /rates [post]
+func (h *RateHandler) Create(c *gin.Context) {
+	var req CreateRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	rate := req.toModel()
+	if !h.checkRate(c, rate) {
+		return
+	}
+	if err := h.DB.Create(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.DB.Preload("Course").First(&rate, rate.ID)
+	respondVersioned(c, http.StatusCreated, rate.Version, newRateResponse(rate))
+}
+
+// Update 更新课时单价
+// @Summary 更新课时单价
+// @Description 仅更新请求中出现的字段，PUT 与 PATCH 行为一致；需携带 If-Match，版本不一致返回 412。已生成的账单不受影响
+// @Tags 收费管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "单价ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Param rate body UpdateRateRequest true "单价信息"
+// @Success 200 {object} RateResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /rates/{id} [put]
+// @Router /rates/{id} [patch]
+func (h *RateHandler) Update(c *gin.Context) {
+	var rate models.TuitionRate
+	if err := h.DB.First(&rate, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "单价不存在"})
+		return
+	}
+	if !checkIfMatch(c, rate.Version) {
+		return
+	}
+	var req UpdateRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationError(err))
+		return
+	}
+	req.apply(&rate)
+	if !h.checkRate(c, rate) {
+		return
+	}
+	if err := saveVersioned(h.DB, &rate, &rate.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	h.DB.Preload("Course").First(&rate, rate.ID)
+	respondVersioned(c, http.StatusOK, rate.Version, newRateResponse(rate))
+}
+
+// Delete 删除课时单价
+// @Summary 删除课时单价
+// @Tags 收费管理
+// @Security BearerAuth
+// @Param id path int true "单价ID"
+// @Param If-Match header string true "当前版本的 ETag"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /rates/{id} [delete]
+func (h *RateHandler) Delete(c *gin.Context) {
+	var rate models.TuitionRate
+	if err := h.DB.First(&rate, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "单价不存在"})
+		return
+	}
+	if !checkIfMatch(c, rate.Version) {
+		return
+	}
+	if err := deleteVersioned(h.DB, &rate, rate.Version); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// checkRate 校验学生专属价格与年级互斥，且同一定价维度只能有一条记录；失败时已写出响应
+func (h *RateHandler) checkRate(c *gin.Context, rate models.TuitionRate) bool {
+	if rate.StudentID != 0 && rate.Grade != "" {
+		c.JSON(http.StatusBadRequest, fieldError("grade", "学生专属价格不能指定年级"))
+		return false
+	}
+	var count int64
+	h.DB.Model(&models.TuitionRate{}).
+		Where("course_id = ? AND grade = ? AND student_id = ? AND id <> ?", rate.CourseID, rate.Grade, rate.StudentID, rate.ID).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该课程已存在相同范围的单价"})
+		return false
+	}
+	return true
+}
+
+// resolveRate 查找学生某课程适用的单价：学生专属价格 > 课程 + 年级价格 > 课程默认价格
+func resolveRate(db *gorm.DB, student models.Student, courseID uint) (*models.TuitionRate, error) {
+	var rate models.TuitionRate
+	err := db.Where("course_id = ?", courseID).
+		Where("student_id = ? OR (student_id = 0 AND (grade = ? OR grade = ''))", student.ID, student.Grade).
+		Order("student_id DESC").
+		Order("grade DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+// 中国大陆手机号，允许 +86 / 86 前缀
+var cnMobilePattern = regexp.MustCompile(`^(\+?86)?1[3-9]\d{9}$`)
+
+// dateLayoutHint 将 Go 时间格式转换为常见写法，如 2006-01-02 -> yyyy-MM-dd
+var dateLayoutHint = strings.NewReplacer("2006", "yyyy", "01", "MM", "02", "dd", "15", "HH", "04", "mm", "05", "ss")
+
+// RegisterValidators 注册自定义校验规则，并让错误信息使用 JSON 字段名
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("binding validator is not go-playground/validator")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("notblank", validators.NotBlank); err != nil {
+		return err
+	}
+	// 空字符串视为未填写，便于更新时清空号码
+	return v.RegisterValidation("cnmobile", func(fl validator.FieldLevel) bool {
+		phone := fl.Field().String()
+		return phone == "" || cnMobilePattern.MatchString(phone)
+	})
+}
+
+// validationError 将绑定错误转换为响应体，校验失败时按字段给出错误信息
+func validationError(err error) gin.H {
+	fields, ok := validationFields(err)
+	if !ok {
+		return gin.H{"error": err.Error()}
+	}
+	return gin.H{"error": "参数校验失败", "fields": fields}
+}
+
+// validationFields 将校验错误转换为 字段 → 中文错误信息，非校验错误时返回 false
+func validationFields(err error) (map[string]string, bool) {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil, false
+	}
+	fields := make(map[string]string, len(errs))
+	for _, e := range errs {
+		fields[e.Field()] = validationMessage(e)
+	}
+	return fields, true
+}
+
+// fieldError 构造单个字段的校验失败响应，格式与 validationError 一致
+func fieldError(field, message string) gin.H {
+	return gin.H{"error": "参数校验失败", "fields": map[string]string{field: message}}
+}
+
+// validationMessage 返回单个字段的中文错误信息
+func validationMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required", "notblank":
+		return "不能为空"
+	case "max":
+		if e.Kind() == reflect.String {
+			return fmt.Sprintf("长度不能超过 %s", e.Param())
+		}
+		return fmt.Sprintf("不能大于 %s", e.Param())
+	case "min":
+		if e.Kind() == reflect.String {
+			return fmt.Sprintf("长度不能少于 %s", e.Param())
+		}
+		return fmt.Sprintf("不能小于 %s", e.Param())
+	case "gt":
+		return fmt.Sprintf("必须大于 %s", e.Param())
+	case "gte":
+		return fmt.Sprintf("不能小于 %s", e.Param())
+	case "lte":
+		return fmt.Sprintf("不能大于 %s", e.Param())
+	case "oneof":
+		return fmt.Sprintf("必须是以下值之一: %s", e.Param())
+	case "datetime":
+		return fmt.Sprintf("格式应为 %s", dateLayoutHint.Replace(e.Param()))
+	case "cnmobile":
+		return "手机号格式不正确"
+	case "gtfield":
+		return fmt.Sprintf("必须晚于 %s", toSnakeCase(e.Param()))
+	case "ltefield":
+		return fmt.Sprintf("不能大于 %s", toSnakeCase(e.Param()))
+	default:
+		return fmt.Sprintf("校验失败: %s", e.Tag())
+	}
+}
+
+// toSnakeCase 将 Go 字段名转为下划线形式，如 FullScore -> full_score
+func toSnakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -14,6 +14,8 @@ import (
 	"tutor-management/handlers"
 	"tutor-management/middleware"
 	"tutor-management/models"
+	"tutor-management/search"
+	"tutor-management/storage"
 	"tutor-management/utils"
 
 	"github.com/gin-gonic/gin"
@@ -81,12 +83,35 @@ func main() {
 	utils.Info("Database connected successfully")
 
 	// 自动迁移
-	db.AutoMigrate(&models.Student{}, &models.Course{}, &models.Schedule{}, &models.ExamResult{}, &models.User{})
+	db.AutoMigrate(&models.Student{}, &models.Course{}, &models.Schedule{}, &models.ExamResult{}, &models.User{},
+		&models.LessonPackage{}, &models.PackageDeduction{},
+		&models.TuitionRate{}, &models.Invoice{}, &models.InvoiceItem{}, &models.Payment{},
+		&models.MakeupCredit{}, &models.LessonRecord{}, &models.Homework{}, &models.Attachment{}, &models.Goal{})
+
+	// 初始化全文索引
+	searchIndex, err := search.NewIndex(cfg.Database.Type)
+	if err != nil {
+		utils.Fatal("初始化全文索引失败", zap.Error(err))
+	}
+	if err := search.Register(db, searchIndex); err != nil {
+		utils.Fatal("初始化全文索引失败", zap.Error(err))
+	}
+
+	// 初始化附件存储
+	fileStorage, err := storage.New(cfg.Storage)
+	if err != nil {
+		utils.Fatal("初始化附件存储失败", zap.Error(err))
+	}
 
 	// 初始化安全中间件
 	middleware.InitBlacklist()
 	middleware.InitRateLimiters()
 
+	// 注册参数校验规则
+	if err := handlers.RegisterValidators(); err != nil {
+		utils.Fatal("注册校验规则失败", zap.Error(err))
+	}
+
 	// 设置Gin模式
 	if !isDev {
 		gin.SetMode(gin.ReleaseMode)
@@ -112,13 +137,30 @@ func main() {
 	r.Use(middleware.CORSMiddleware(allowedOrigins))
 
 	// 初始化 handlers
+	chartCache := handlers.NewChartCache(cfg.Chart)
 	authHandler := handlers.NewAuthHandler(db)
-	studentHandler := handlers.NewStudentHandler(db)
-	courseHandler := handlers.NewCourseHandler(db)
-	scheduleHandler := handlers.NewScheduleHandler(db)
-	examResultHandler := handlers.NewExamResultHandler(db)
-	trendHandler := handlers.NewTrendHandler()
+	studentHandler := handlers.NewStudentHandler(db, fileStorage)
+	courseHandler := handlers.NewCourseHandler(db, chartCache)
+	scheduleHandler := handlers.NewScheduleHandler(db, cfg.Billing, cfg.Makeup, cfg.Attendance, cfg.Schedule, fileStorage)
+	examResultHandler := handlers.NewExamResultHandler(db, chartCache, fileStorage)
+	trendHandler := handlers.NewTrendHandler(db, chartCache, cfg.Chart)
+	importHandler := handlers.NewImportHandler(db, chartCache)
+	exportHandler := handlers.NewExportHandler(db)
+	backupHandler := handlers.NewBackupHandler(db, chartCache)
+	analysisHandler := handlers.NewAnalysisHandler(db, cfg.Analysis)
 	healthHandler := handlers.NewHealthHandler(db)
+	searchHandler := handlers.NewSearchHandler(db, searchIndex)
+	packageHandler := handlers.NewPackageHandler(db, cfg.Billing)
+	rateHandler := handlers.NewRateHandler(db)
+	invoiceHandler := handlers.NewInvoiceHandler(db, cfg.Billing)
+	paymentHandler := handlers.NewPaymentHandler(db)
+	statementHandler := handlers.NewStatementHandler(db, cfg.Billing)
+	reportHandler := handlers.NewReportHandler(db)
+	makeupHandler := handlers.NewMakeupHandler(db, cfg.Makeup)
+	lessonRecordHandler := handlers.NewLessonRecordHandler(db, fileStorage)
+	homeworkHandler := handlers.NewHomeworkHandler(db)
+	goalHandler := handlers.NewGoalHandler(db, chartCache)
+	attachmentHandler := handlers.NewAttachmentHandler(db, fileStorage, cfg.Storage)
 
 	// 初始化管理员账号
 	authHandler.InitAdmin()
@@ -136,8 +178,15 @@ func main() {
 		api.POST("/login", authHandler.Login)
 		api.POST("/logout", authHandler.Logout)
 
-		// 成绩趋势分析图表 - 无需认证（图片资源）
-		api.GET("/analysis/:student_id/trend.png", trendHandler.GetTrendChart)
+		// 成绩趋势分析图表 - 需要 token 或签名地址（供 <img> 使用）
+		charts := api.Group("/analysis")
+		charts.Use(handlers.ChartAuthMiddleware(cfg.Chart))
+		{
+			charts.GET("/:student_id/trend.png", trendHandler.GetTrendChart)
+			charts.GET("/:student_id/radar.png", trendHandler.GetRadarChart)
+			charts.GET("/:student_id/delta.png", trendHandler.GetDeltaChart)
+			charts.GET("/compare.png", trendHandler.GetComparisonChart)
+		}
 
 		// 需要认证的接口
 		protected := api.Group("")
@@ -149,29 +198,133 @@ func main() {
 			// App 专用接口 - 需要认证
 			protected.GET("/dashboard/today", scheduleHandler.GetDashboardToday)
 			protected.GET("/dashboard/date", scheduleHandler.GetDashboardByDate)
+			protected.GET("/dashboard/homework", homeworkHandler.GetDashboard)
+			protected.GET("/dashboard/score-alerts", analysisHandler.GetAlerts)
+			protected.GET("/dashboard/low-balances", packageHandler.GetLowBalances)
 
 			protected.GET("/students", studentHandler.GetAll)
 			protected.POST("/students", studentHandler.Create)
+			protected.GET("/students/:id", studentHandler.Get)
 			protected.PUT("/students/:id", studentHandler.Update)
+			protected.PATCH("/students/:id", studentHandler.Update)
 			protected.DELETE("/students/:id", studentHandler.Delete)
+			protected.GET("/students/:id/balances", packageHandler.GetStudentBalances)
 
 			protected.GET("/courses", courseHandler.GetAll)
 			protected.POST("/courses", courseHandler.Create)
+			protected.GET("/courses/:id", courseHandler.Get)
 			protected.PUT("/courses/:id", courseHandler.Update)
+			protected.PATCH("/courses/:id", courseHandler.Update)
 			protected.DELETE("/courses/:id", courseHandler.Delete)
 
 			protected.GET("/schedules", scheduleHandler.GetAll)
 			protected.GET("/schedules/search", scheduleHandler.Search)
 			protected.POST("/schedules", scheduleHandler.Create)
+			protected.GET("/schedules/:id", scheduleHandler.Get)
 			protected.PUT("/schedules/:id", scheduleHandler.Update)
+			protected.PATCH("/schedules/:id", scheduleHandler.Update)
 			protected.DELETE("/schedules/:id", scheduleHandler.Delete)
+			protected.POST("/schedules/:id/transition", scheduleHandler.Transition)
+			protected.POST("/schedules/:id/check-in", scheduleHandler.CheckIn)
+			protected.POST("/schedules/:id/check-out", scheduleHandler.CheckOut)
+			protected.POST("/schedules/:id/absence", scheduleHandler.MarkAbsence)
 			protected.GET("/schedules/today", scheduleHandler.GetTodaySchedules)
 
 			protected.GET("/exam-results", examResultHandler.GetAll)
 			protected.POST("/exam-results", examResultHandler.Create)
+			protected.GET("/exam-results/:id", examResultHandler.Get)
 			protected.PUT("/exam-results/:id", examResultHandler.Update)
+			protected.PATCH("/exam-results/:id", examResultHandler.Update)
 			protected.DELETE("/exam-results/:id", examResultHandler.Delete)
 			protected.GET("/exam-results/student/:student_id", examResultHandler.GetByStudent)
+
+			// 批量导入
+			protected.POST("/import/students", importHandler.ImportStudents)
+			protected.POST("/import/exam-results", importHandler.ImportExamResults)
+
+			// 数据导出
+			protected.GET("/export/students", exportHandler.ExportStudents)
+			protected.GET("/export/courses", exportHandler.ExportCourses)
+			protected.GET("/export/schedules", exportHandler.ExportSchedules)
+			protected.GET("/export/exam-results", exportHandler.ExportExamResults)
+
+			// 全量备份与恢复
+			protected.GET("/backup/export", backupHandler.Export)
+			protected.POST("/backup/import", backupHandler.Import)
+			protected.POST("/analysis/signed-url", trendHandler.SignChartURL)
+			protected.GET("/analysis/:student_id/summary", analysisHandler.GetSummary)
+			protected.GET("/analysis/:student_id/forecast", analysisHandler.GetForecast)
+
+			protected.GET("/packages", packageHandler.GetAll)
+			protected.POST("/packages", packageHandler.Create)
+			protected.GET("/packages/:id", packageHandler.Get)
+			protected.PUT("/packages/:id", packageHandler.Update)
+			protected.PATCH("/packages/:id", packageHandler.Update)
+			protected.DELETE("/packages/:id", packageHandler.Delete)
+			protected.GET("/packages/:id/deductions", packageHandler.GetDeductions)
+
+			protected.GET("/rates", rateHandler.GetAll)
+			protected.POST("/rates", rateHandler.Create)
+			protected.GET("/rates/:id", rateHandler.Get)
+			protected.PUT("/rates/:id", rateHandler.Update)
+			protected.PATCH("/rates/:id", rateHandler.Update)
+			protected.DELETE("/rates/:id", rateHandler.Delete)
+
+			protected.GET("/invoices", invoiceHandler.GetAll)
+			protected.POST("/invoices/generate", invoiceHandler.Generate)
+			protected.GET("/invoices/:id", invoiceHandler.Get)
+			protected.POST("/invoices/:id/transition", invoiceHandler.Transition)
+			protected.DELETE("/invoices/:id", invoiceHandler.Delete)
+
+			protected.GET("/payments", paymentHandler.GetAll)
+			protected.POST("/payments", paymentHandler.Create)
+			protected.DELETE("/payments/:id", paymentHandler.Delete)
+			protected.GET("/billing/outstanding", invoiceHandler.GetOutstanding)
+			protected.GET("/students/:id/statement.pdf", statementHandler.GetStudentStatement)
+			protected.GET("/statements.zip", statementHandler.GetAllStatements)
+
+			protected.GET("/reports/revenue", reportHandler.GetRevenue)
+			protected.GET("/reports/workload", reportHandler.GetWorkload)
+			protected.GET("/reports/cancellations", reportHandler.GetCancellations)
+
+			protected.GET("/makeups", makeupHandler.GetAll)
+			protected.PATCH("/makeups/:id", makeupHandler.Update)
+			protected.DELETE("/makeups/:id", makeupHandler.Delete)
+			protected.GET("/students/:id/makeups", makeupHandler.GetStudentMakeups)
+
+			protected.GET("/lesson-records", lessonRecordHandler.GetAll)
+			protected.POST("/lesson-records", lessonRecordHandler.Create)
+			protected.GET("/lesson-records/:id", lessonRecordHandler.Get)
+			protected.PUT("/lesson-records/:id", lessonRecordHandler.Update)
+			protected.PATCH("/lesson-records/:id", lessonRecordHandler.Update)
+			protected.DELETE("/lesson-records/:id", lessonRecordHandler.Delete)
+			protected.GET("/students/:id/timeline", lessonRecordHandler.GetStudentTimeline)
+
+			protected.GET("/homework", homeworkHandler.GetAll)
+			protected.GET("/homework/overdue", homeworkHandler.GetOverdue)
+			protected.POST("/homework", homeworkHandler.Create)
+			protected.GET("/homework/:id", homeworkHandler.Get)
+			protected.PUT("/homework/:id", homeworkHandler.Update)
+			protected.PATCH("/homework/:id", homeworkHandler.Update)
+			protected.DELETE("/homework/:id", homeworkHandler.Delete)
+			protected.POST("/homework/:id/transition", homeworkHandler.Transition)
+
+			protected.GET("/goals", goalHandler.GetAll)
+			protected.POST("/goals", goalHandler.Create)
+			protected.GET("/goals/:id", goalHandler.Get)
+			protected.PUT("/goals/:id", goalHandler.Update)
+			protected.PATCH("/goals/:id", goalHandler.Update)
+			protected.DELETE("/goals/:id", goalHandler.Delete)
+
+			protected.GET("/attachments", attachmentHandler.GetAll)
+			protected.POST("/attachments", attachmentHandler.Upload)
+			protected.GET("/attachments/:id", attachmentHandler.Get)
+			protected.PATCH("/attachments/:id", attachmentHandler.Update)
+			protected.DELETE("/attachments/:id", attachmentHandler.Delete)
+			protected.GET("/attachments/:id/download", attachmentHandler.Download)
+			protected.GET("/attachments/:id/thumbnail", attachmentHandler.Thumbnail)
+
+			protected.GET("/search", searchHandler.Search)
 		}
 	}
 
@@ -192,12 +345,19 @@ func main() {
 		}
 	}()
 
+	// 后台任务：自动完成已下课的课程，失效过期的补课权益
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go scheduleHandler.RunAutoComplete(jobCtx)
+	go makeupHandler.RunExpiry(jobCtx)
+
 	// 优雅关闭
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	utils.Info("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -16,6 +16,11 @@ func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				// 响应已部分写出时主动中断连接，交由 net/http 处理，不再追加错误响应
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
 				// 获取堆栈信息
 				stack := string(debug.Stack())
 
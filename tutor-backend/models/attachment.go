@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Attachment 附件，关联到学生、成绩或课堂记录
+// 文件本身保存在存储后端，数据库只记录元数据
+type Attachment struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Version      uint      `json:"version" gorm:"not null;default:1"`                    // 乐观锁版本号
+	OwnerType    string    `json:"owner_type" gorm:"size:20;index:idx_attachment_owner"` // student, exam_result, lesson_record
+	OwnerID      uint      `json:"owner_id" gorm:"index:idx_attachment_owner"`
+	FileName     string    `json:"file_name" gorm:"size:255"` // 上传时的原始文件名
+	ContentType  string    `json:"content_type" gorm:"size:100"`
+	Size         int64     `json:"size"`
+	Checksum     string    `json:"checksum" gorm:"size:64"` // 文件内容的 SHA-256
+	StorageKey   string    `json:"-" gorm:"size:255"`
+	ThumbnailKey string    `json:"-" gorm:"size:255"` // 图片缩略图，无法生成时为空
+	Description  string    `json:"description" gorm:"size:500"`
+}
@@ -6,6 +6,7 @@ type Course struct {
 	ID          uint      `json:"id" gorm:"primarykey"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	Version     uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 }
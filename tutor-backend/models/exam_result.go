@@ -8,6 +8,7 @@ type ExamResult struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Version   uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
 	StudentID uint      `json:"student_id"`
 	Student   *Student  `json:"student,omitempty" gorm:"foreignKey:StudentID"`
 	CourseID  uint      `json:"course_id"`
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Goal 学习目标：学生某科目在截止日期前要达到的分数，如期末数学 110/150
+type Goal struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	StudentID   uint      `json:"student_id" gorm:"index"`
+	Student     *Student  `json:"student,omitempty" gorm:"foreignKey:StudentID"`
+	CourseID    uint      `json:"course_id" gorm:"index"`
+	Course      *Course   `json:"course,omitempty" gorm:"foreignKey:CourseID"`
+	TargetScore float64   `json:"target_score"`
+	FullScore   float64   `json:"full_score"` // 目标分数对应的满分，按得分率与不同满分的考试比较
+	ExamType    string    `json:"exam_type"`  // 只统计该类型的考试，为空表示全部考试
+	Deadline    time.Time `json:"deadline"`
+	Description string    `json:"description"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Homework 作业，按学生和科目布置
+type Homework struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Version     uint       `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	StudentID   uint       `json:"student_id" gorm:"index"`
+	Student     *Student   `json:"student,omitempty" gorm:"foreignKey:StudentID"`
+	CourseID    uint       `json:"course_id" gorm:"index"`
+	Course      *Course    `json:"course,omitempty" gorm:"foreignKey:CourseID"`
+	ScheduleID  *uint      `json:"schedule_id" gorm:"index"` // 布置作业的课程，可为空
+	Title       string     `json:"title"`
+	Description string     `json:"description" gorm:"type:text"`
+	AssignedAt  time.Time  `json:"assigned_at"`
+	DueDate     time.Time  `json:"due_date" gorm:"index"`
+	Status      string     `json:"status" gorm:"index"` // assigned 已布置, submitted 已提交, checked 已批改
+	SubmittedAt *time.Time `json:"submitted_at"`
+	CheckedAt   *time.Time `json:"checked_at"`
+	Score       *float64   `json:"score"` // 批改得分（百分制），为空表示未评分
+	Feedback    string     `json:"feedback" gorm:"type:text"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Invoice 学费账单，由一段时间内已完成的排课生成
+type Invoice struct {
+	ID          uint          `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Version     uint          `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	Number      string        `json:"number" gorm:"size:32;index"`
+	StudentID   uint          `json:"student_id" gorm:"index"`
+	Student     *Student      `json:"student,omitempty" gorm:"foreignKey:StudentID"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	Status      string        `json:"status"` // draft, issued, paid, void
+	Amount      float64       `json:"amount"` // 应收金额
+	Paid        float64       `json:"paid"`   // 已收金额，由关联的收款记录汇总
+	IssuedAt    *time.Time    `json:"issued_at"`
+	PaidAt      *time.Time    `json:"paid_at"`
+	VoidedAt    *time.Time    `json:"voided_at"`
+	Notes       string        `json:"notes"`
+	Items       []InvoiceItem `json:"items,omitempty" gorm:"foreignKey:InvoiceID"`
+}
+
+// InvoiceItem 账单明细，每条对应一次已完成的排课
+type InvoiceItem struct {
+	ID          uint    `json:"id" gorm:"primarykey"`
+	InvoiceID   uint    `json:"invoice_id" gorm:"index"`
+	ScheduleID  uint    `json:"schedule_id" gorm:"index"`
+	CourseID    uint    `json:"course_id"`
+	Description string  `json:"description"`
+	Hours       float64 `json:"hours"`
+	Rate        float64 `json:"rate"`
+	Amount      float64 `json:"amount"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// LessonPackage 预付课时包
+type LessonPackage struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Version     uint       `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	StudentID   uint       `json:"student_id" gorm:"index"`
+	Student     *Student   `json:"student,omitempty" gorm:"foreignKey:StudentID"`
+	CourseID    uint       `json:"course_id" gorm:"index"`
+	Course      *Course    `json:"course,omitempty" gorm:"foreignKey:CourseID"`
+	Unit        string     `json:"unit"`     // hour 按课时, lesson 按节数
+	Quantity    float64    `json:"quantity"` // 购买数量
+	Used        float64    `json:"used"`     // 已消耗数量
+	Price       float64    `json:"price"`    // 实付金额
+	PurchasedAt time.Time  `json:"purchased_at"`
+	ExpiresAt   *time.Time `json:"expires_at"` // 为空表示不过期
+	Notes       string     `json:"notes"`
+}
+
+// Balance 剩余数量，可能为负（超额上课）
+func (p LessonPackage) Balance() float64 {
+	return p.Quantity - p.Used
+}
+
+// PackageDeduction 排课完成时从课时包扣减的记录
+type PackageDeduction struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time  `json:"created_at"`
+	PackageID  uint       `json:"package_id" gorm:"index"`
+	ScheduleID uint       `json:"schedule_id" gorm:"index"`
+	Amount     float64    `json:"amount"`
+	ReversedAt *time.Time `json:"reversed_at"` // 排课撤销完成状态时冲回
+}
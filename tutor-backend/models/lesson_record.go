@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LessonRecord 课堂记录，每次排课最多一条
+type LessonRecord struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Version       uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	ScheduleID    uint      `json:"schedule_id" gorm:"uniqueIndex"`
+	Schedule      *Schedule `json:"schedule,omitempty" gorm:"foreignKey:ScheduleID"`
+	StudentID     uint      `json:"student_id" gorm:"index"` // 与排课保持一致，便于按学生查询
+	CourseID      uint      `json:"course_id" gorm:"index"`
+	Topics        string    `json:"topics" gorm:"type:text"`   // 本节课讲授内容
+	Homework      string    `json:"homework" gorm:"type:text"` // 布置的作业
+	TutorNotes    string    `json:"tutor_notes" gorm:"type:text"`
+	Rating        int       `json:"rating"`         // 课堂表现评分 1-5，0 表示未评分
+	ParentVisible bool      `json:"parent_visible"` // 是否对家长可见
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// MakeupCredit 补课权益，未计费的取消课程生成，安排补课时核销
+type MakeupCredit struct {
+	ID                 uint       `json:"id" gorm:"primarykey"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	Version            uint       `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	StudentID          uint       `json:"student_id" gorm:"index"`
+	Student            *Student   `json:"student,omitempty" gorm:"foreignKey:StudentID"`
+	CourseID           uint       `json:"course_id" gorm:"index"`
+	Course             *Course    `json:"course,omitempty" gorm:"foreignKey:CourseID"`
+	SourceScheduleID   uint       `json:"source_schedule_id" gorm:"uniqueIndex"` // 被取消的排课
+	Hours              float64    `json:"hours"`                                 // 被取消课程的时长
+	Status             string     `json:"status"`                                // pending 待补课, redeemed 已补课, expired 已失效
+	ExpiresAt          *time.Time `json:"expires_at"`                            // 为空表示不过期
+	RedeemedScheduleID *uint      `json:"redeemed_schedule_id"`
+	RedeemedAt         *time.Time `json:"redeemed_at"`
+	Notes              string     `json:"notes"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Payment 手工登记的收款记录
+type Payment struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	StudentID uint      `json:"student_id" gorm:"index"`
+	Student   *Student  `json:"student,omitempty" gorm:"foreignKey:StudentID"`
+	InvoiceID *uint     `json:"invoice_id" gorm:"index"` // 为空表示预收款
+	Amount    float64   `json:"amount"`
+	Method    string    `json:"method"` // cash 现金, wechat 微信, alipay 支付宝
+	PaidAt    time.Time `json:"paid_at"`
+	Reference string    `json:"reference"` // 交易单号等
+	Notes     string    `json:"notes"`
+}
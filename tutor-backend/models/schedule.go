@@ -8,6 +8,7 @@ type Schedule struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Version   uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
 	StudentID uint      `json:"student_id"`
 	Student   Student   `json:"student" gorm:"foreignKey:StudentID"`
 	CourseID  uint      `json:"course_id"`
@@ -15,4 +16,17 @@ type Schedule struct {
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
 	Status    string    `json:"status"` // "scheduled", "completed", "cancelled"
+
+	// 取消信息，仅在 Status 为 cancelled 时有值
+	CancelledBy  string     `json:"cancelled_by"` // student 学生, parent 家长, tutor 老师
+	CancelReason string     `json:"cancel_reason"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	LateCancel   bool       `json:"late_cancel"` // 按取消政策需要计费的迟取消
+
+	// 考勤信息，签到/签退记录实际上课时间，计费时长以实际时间为准
+	Attendance string     `json:"attendance"` // present 出勤, late 迟到, absent 缺勤, excused 请假；空表示未记录
+	CheckInAt  *time.Time `json:"check_in_at"`
+	CheckOutAt *time.Time `json:"check_out_at"`
+
+	MakeupCreditID *uint `json:"makeup_credit_id" gorm:"index"` // 作为补课时使用的补课权益
 }
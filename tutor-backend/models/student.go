@@ -6,6 +6,7 @@ type Student struct {
 	ID          uint      `json:"id" gorm:"primarykey"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	Version     uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
 	Name        string    `json:"name"`
 	ParentPhone string    `json:"parent_phone"`
 	Grade       string    `json:"grade"` // e.g., "初二"
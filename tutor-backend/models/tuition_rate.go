@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// TuitionRate 课时单价
+// StudentID 非零时为学生专属价格；否则按课程 + 年级定价，Grade 为空表示该课程所有年级
+type TuitionRate struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Version    uint      `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+	CourseID   uint      `json:"course_id" gorm:"index"`
+	Course     *Course   `json:"course,omitempty" gorm:"foreignKey:CourseID"`
+	Grade      string    `json:"grade"`
+	StudentID  uint      `json:"student_id" gorm:"index"` // 0 表示通用价格
+	HourlyRate float64   `json:"hourly_rate"`             // 每小时价格（元）
+	Notes      string    `json:"notes"`
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// SearchDocument MySQL 索引表
+type SearchDocument struct {
+	ID         uint   `gorm:"primarykey"`
+	EntityType string `gorm:"size:32;uniqueIndex:idx_search_entity"`
+	EntityID   uint   `gorm:"uniqueIndex:idx_search_entity"`
+	Title      string `gorm:"size:255"`
+	Content    string `gorm:"type:text"`
+	Pinyin     string `gorm:"size:255"`
+}
+
+// mysqlIndex 基于 MySQL FULLTEXT（ngram 分词）的索引
+// ngram 默认按两个字切分，单字关键词退化为 LIKE 匹配
+type mysqlIndex struct{}
+
+func (m *mysqlIndex) Setup(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SearchDocument{}); err != nil {
+		return err
+	}
+	if db.Migrator().HasIndex(&SearchDocument{}, "ft_search_documents") {
+		return nil
+	}
+	return db.Exec("ALTER TABLE search_documents ADD FULLTEXT INDEX ft_search_documents (title, content, pinyin) WITH PARSER ngram").Error
+}
+
+func (m *mysqlIndex) Upsert(db *gorm.DB, doc Document) error {
+	return db.Exec(`INSERT INTO search_documents (entity_type, entity_id, title, content, pinyin)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE title = VALUES(title), content = VALUES(content), pinyin = VALUES(pinyin)`,
+		doc.EntityType, doc.EntityID, doc.Title, doc.Content, doc.Pinyin,
+	).Error
+}
+
+func (m *mysqlIndex) Delete(db *gorm.DB, entityType string, id uint) error {
+	return db.Where("entity_type = ? AND entity_id = ?", entityType, id).Delete(&SearchDocument{}).Error
+}
+
+func (m *mysqlIndex) Prune(db *gorm.DB, entityType, table string) error {
+	return db.Exec("DELETE FROM search_documents WHERE entity_type = ? AND entity_id NOT IN (SELECT id FROM "+table+")", entityType).Error
+}
+
+func (m *mysqlIndex) Search(db *gorm.DB, terms []string, types []string, limit int) ([]Hit, error) {
+	fulltext := make([]string, 0, len(terms))
+	query := db.Table("search_documents")
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < 2 {
+			like := "%" + escapeLike(term) + "%"
+			query = query.Where("(title LIKE ? ESCAPE '!' OR content LIKE ? ESCAPE '!' OR pinyin LIKE ? ESCAPE '!')", like, like, like)
+			continue
+		}
+		fulltext = append(fulltext, mysqlBooleanTerm(term))
+	}
+
+	if len(fulltext) > 0 {
+		expr := strings.Join(fulltext, " ")
+		query = query.
+			Select("entity_type, entity_id, title, content AS snippet, MATCH(title, content, pinyin) AGAINST (? IN BOOLEAN MODE) AS score", expr).
+			Where("MATCH(title, content, pinyin) AGAINST (? IN BOOLEAN MODE)", expr)
+	} else {
+		query = query.Select("entity_type, entity_id, title, content AS snippet, 1 AS score")
+	}
+	if len(types) > 0 {
+		query = query.Where("entity_type IN ?", types)
+	}
+
+	hits := make([]Hit, 0)
+	err := query.
+		Order("score DESC").
+		Limit(limit).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// escapeLike 转义 LIKE 通配符，关键词中的 % 与 _ 按字面匹配（配合 ESCAPE '!'）
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// mysqlBooleanTerm 构造布尔模式下的必选关键词，拼音/字母关键词使用前缀匹配
+func mysqlBooleanTerm(term string) string {
+	if isASCIIWord(term) {
+		return "+" + term + "*"
+	}
+	return `+"` + strings.ReplaceAll(term, `"`, "") + `"`
+}
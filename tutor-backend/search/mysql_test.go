@@ -0,0 +1,55 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 单字关键词只走 LIKE 匹配，SQLite 同样支持 ESCAPE，可直接验证
+func TestMySQLSingleCharTermEscapesWildcards(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&SearchDocument{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&[]SearchDocument{
+		{EntityType: "student", EntityID: 1, Title: "张三"},
+		{EntityType: "student", EntityID: 2, Title: "折扣 50%"},
+		{EntityType: "student", EntityID: 3, Title: "a_b"},
+		{EntityType: "student", EntityID: 4, Title: "李四", Content: "重点!"},
+	})
+
+	tests := []struct {
+		term string
+		want []uint
+	}{
+		{"%", []uint{2}},
+		{"_", []uint{3}},
+		{"!", []uint{4}},
+		{"张", []uint{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			hits, err := (&mysqlIndex{}).Search(db, []string{tt.term}, nil, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hits) != len(tt.want) {
+				t.Fatalf("hits = %+v, want entities %v", hits, tt.want)
+			}
+			for i, h := range hits {
+				if h.EntityID != tt.want[i] {
+					t.Errorf("hits[%d] = %d, want %d", i, h.EntityID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"gorm.io/gorm"
+)
+
+// 可检索的实体类型
+const (
+	TypeStudent    = "student"
+	TypeCourse     = "course"
+	TypeExamResult = "exam_result"
+)
+
+// Document 索引中的一条文档
+type Document struct {
+	EntityType string
+	EntityID   uint
+	Title      string
+	Content    string
+	Pinyin     string // 标题的全拼与首字母，用于拼音检索
+}
+
+// Hit 检索命中结果
+type Hit struct {
+	EntityType string  `json:"entity_type"`
+	EntityID   uint    `json:"entity_id"`
+	Title      string  `json:"title"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+// Group 按实体类型分组的检索结果
+type Group struct {
+	EntityType string  `json:"entity_type"`
+	Total      int     `json:"total"`
+	Hits       []Hit   `json:"hits"`
+	TopScore   float64 `json:"top_score"`
+}
+
+// Index 全文索引后端
+type Index interface {
+	// Setup 创建索引表（幂等）
+	Setup(db *gorm.DB) error
+	// Upsert 写入或覆盖一条文档
+	Upsert(db *gorm.DB, doc Document) error
+	// Delete 删除一条文档
+	Delete(db *gorm.DB, entityType string, id uint) error
+	// Prune 删除源表中已不存在的文档
+	Prune(db *gorm.DB, entityType, table string) error
+	// Search 执行检索，返回按相关度降序的命中
+	Search(db *gorm.DB, terms []string, types []string, limit int) ([]Hit, error)
+}
+
+// NewIndex 根据数据库类型创建索引后端
+func NewIndex(dbType string) (Index, error) {
+	switch dbType {
+	case "sqlite":
+		return &sqliteIndex{}, nil
+	case "mysql":
+		return &mysqlIndex{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型: %s", dbType)
+	}
+}
+
+// Run 执行检索并按实体类型分组，组之间按最高得分排序
+func Run(db *gorm.DB, index Index, query string, types []string, limit int) ([]Group, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []Group{}, nil
+	}
+
+	hits, err := index.Search(db, terms, types, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*Group)
+	order := make([]string, 0)
+	for _, hit := range hits {
+		// 标题完整包含关键词时额外加权
+		if strings.Contains(strings.ToLower(hit.Title), strings.ToLower(query)) {
+			hit.Score += 1
+		}
+		hit.Snippet = snippet(hit.Snippet, terms)
+
+		g, ok := groups[hit.EntityType]
+		if !ok {
+			g = &Group{EntityType: hit.EntityType, Hits: []Hit{}}
+			groups[hit.EntityType] = g
+			order = append(order, hit.EntityType)
+		}
+		g.Hits = append(g.Hits, hit)
+		g.Total++
+	}
+
+	result := make([]Group, 0, len(order))
+	for _, t := range order {
+		g := groups[t]
+		sort.SliceStable(g.Hits, func(i, j int) bool { return g.Hits[i].Score > g.Hits[j].Score })
+		g.TopScore = g.Hits[0].Score
+		result = append(result, *g)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].TopScore > result[j].TopScore })
+	return result, nil
+}
+
+// PinyinKeys 生成中文文本的拼音检索键：全拼与首字母，如 "张三" -> "zhangsan zs"
+func PinyinKeys(text string) string {
+	args := pinyin.NewArgs()
+	full := pinyin.LazyPinyin(text, args)
+	if len(full) == 0 {
+		return ""
+	}
+	initials := make([]byte, 0, len(full))
+	for _, p := range full {
+		initials = append(initials, p[0])
+	}
+	return strings.Join(full, "") + " " + string(initials)
+}
+
+// hasHan 判断文本是否包含汉字
+func hasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isASCIIWord 判断关键词是否为纯字母数字（可能是拼音或首字母）
+func isASCIIWord(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// snippet 截取命中关键词附近的文本片段
+func snippet(text string, terms []string) string {
+	const radius = 30
+	runes := []rune(text)
+	if len(runes) <= radius*2 {
+		return text
+	}
+
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		idx := strings.Index(lower, strings.ToLower(term))
+		if idx < 0 {
+			continue
+		}
+		pos := len([]rune(text[:idx]))
+		start := pos - radius
+		if start < 0 {
+			start = 0
+		}
+		end := pos + len([]rune(term)) + radius
+		if end > len(runes) {
+			end = len(runes)
+		}
+		s := string(runes[start:end])
+		if start > 0 {
+			s = "…" + s
+		}
+		if end < len(runes) {
+			s += "…"
+		}
+		return s
+	}
+	return string(runes[:radius*2]) + "…"
+}
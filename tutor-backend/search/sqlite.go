@@ -0,0 +1,96 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// sqliteIndex 基于 SQLite FTS5 的索引
+// unicode61 分词器会把连续汉字当作一个词，因此写入与查询时都按单字切分
+type sqliteIndex struct{}
+
+func (s *sqliteIndex) Setup(db *gorm.DB) error {
+	return db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		entity_type UNINDEXED,
+		entity_id UNINDEXED,
+		title,
+		content,
+		pinyin,
+		raw_title UNINDEXED,
+		raw_content UNINDEXED,
+		tokenize = 'unicode61'
+	)`).Error
+}
+
+func (s *sqliteIndex) Upsert(db *gorm.DB, doc Document) error {
+	if err := s.Delete(db, doc.EntityType, doc.EntityID); err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO search_index (entity_type, entity_id, title, content, pinyin, raw_title, raw_content)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		doc.EntityType, doc.EntityID,
+		segmentHan(doc.Title), segmentHan(doc.Content), doc.Pinyin,
+		doc.Title, doc.Content,
+	).Error
+}
+
+func (s *sqliteIndex) Delete(db *gorm.DB, entityType string, id uint) error {
+	return db.Exec("DELETE FROM search_index WHERE entity_type = ? AND entity_id = ?", entityType, id).Error
+}
+
+func (s *sqliteIndex) Prune(db *gorm.DB, entityType, table string) error {
+	return db.Exec("DELETE FROM search_index WHERE entity_type = ? AND entity_id NOT IN (SELECT id FROM "+table+")", entityType).Error
+}
+
+func (s *sqliteIndex) Search(db *gorm.DB, terms []string, types []string, limit int) ([]Hit, error) {
+	sql := `SELECT entity_type, entity_id, raw_title AS title, raw_content AS snippet,
+		-bm25(search_index, 0, 0, 10.0, 1.0, 5.0) AS score
+		FROM search_index WHERE search_index MATCH ?`
+	args := []interface{}{sqliteMatchExpr(terms)}
+	if len(types) > 0 {
+		sql += " AND entity_type IN ?"
+		args = append(args, types)
+	}
+	sql += " ORDER BY score DESC LIMIT ?"
+	args = append(args, limit)
+
+	hits := make([]Hit, 0)
+	if err := db.Raw(sql, args...).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// sqliteMatchExpr 构造 FTS5 查询表达式，各关键词之间为 AND 关系
+// 汉字按单字组成短语，拼音/字母关键词使用前缀匹配
+func sqliteMatchExpr(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted := `"` + strings.ReplaceAll(segmentHan(term), `"`, `""`) + `"`
+		if isASCIIWord(term) {
+			quoted += "*"
+		}
+		parts = append(parts, quoted)
+	}
+	return strings.Join(parts, " ")
+}
+
+// segmentHan 在每个汉字两侧插入空格，使其成为独立的词
+func segmentHan(text string) string {
+	if !hasHan(text) {
+		return text
+	}
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			b.WriteRune(' ')
+			b.WriteRune(r)
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
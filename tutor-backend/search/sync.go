@@ -0,0 +1,221 @@
+package search
+
+import (
+	"reflect"
+
+	"tutor-management/models"
+	"tutor-management/utils"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// source 一种可检索实体的数据来源
+type source struct {
+	entityType string
+	table      string
+	load       func(db *gorm.DB, id uint) (Document, error)
+	loadAll    func(db *gorm.DB) ([]Document, error)
+}
+
+var sources = []source{
+	{
+		entityType: TypeStudent,
+		table:      "students",
+		load: func(db *gorm.DB, id uint) (Document, error) {
+			var s models.Student
+			err := db.First(&s, id).Error
+			return studentDocument(s), err
+		},
+		loadAll: func(db *gorm.DB) ([]Document, error) {
+			var list []models.Student
+			err := db.Find(&list).Error
+			docs := make([]Document, 0, len(list))
+			for _, s := range list {
+				docs = append(docs, studentDocument(s))
+			}
+			return docs, err
+		},
+	},
+	{
+		entityType: TypeCourse,
+		table:      "courses",
+		load: func(db *gorm.DB, id uint) (Document, error) {
+			var c models.Course
+			err := db.First(&c, id).Error
+			return courseDocument(c), err
+		},
+		loadAll: func(db *gorm.DB) ([]Document, error) {
+			var list []models.Course
+			err := db.Find(&list).Error
+			docs := make([]Document, 0, len(list))
+			for _, c := range list {
+				docs = append(docs, courseDocument(c))
+			}
+			return docs, err
+		},
+	},
+	{
+		entityType: TypeExamResult,
+		table:      "exam_results",
+		load: func(db *gorm.DB, id uint) (Document, error) {
+			var r models.ExamResult
+			err := db.First(&r, id).Error
+			return examResultDocument(r), err
+		},
+		loadAll: func(db *gorm.DB) ([]Document, error) {
+			var list []models.ExamResult
+			err := db.Find(&list).Error
+			docs := make([]Document, 0, len(list))
+			for _, r := range list {
+				docs = append(docs, examResultDocument(r))
+			}
+			return docs, err
+		},
+	},
+}
+
+func studentDocument(s models.Student) Document {
+	return Document{
+		EntityType: TypeStudent,
+		EntityID:   s.ID,
+		Title:      s.Name,
+		Content:    s.Notes,
+		Pinyin:     PinyinKeys(s.Name),
+	}
+}
+
+func courseDocument(c models.Course) Document {
+	return Document{
+		EntityType: TypeCourse,
+		EntityID:   c.ID,
+		Title:      c.Name,
+		Content:    c.Description,
+		Pinyin:     PinyinKeys(c.Name),
+	}
+}
+
+func examResultDocument(r models.ExamResult) Document {
+	return Document{
+		EntityType: TypeExamResult,
+		EntityID:   r.ID,
+		Title:      r.ExamName,
+		Content:    r.Comment,
+	}
+}
+
+// Register 创建索引、重建全部文档，并注册 GORM 回调在增删改后同步索引
+func Register(db *gorm.DB, index Index) error {
+	if err := index.Setup(db); err != nil {
+		return err
+	}
+	if err := Rebuild(db, index); err != nil {
+		return err
+	}
+
+	sync := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil {
+			return
+		}
+		src, ok := sourceFor(tx.Statement.Schema.Table)
+		if !ok {
+			return
+		}
+		conn := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+		for _, id := range statementIDs(tx) {
+			doc, err := src.load(conn, id)
+			if err == nil {
+				err = index.Upsert(conn, doc)
+			}
+			if err != nil {
+				utils.Warn("Failed to update search index",
+					zap.String("entity_type", src.entityType),
+					zap.Uint("entity_id", id),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+
+	prune := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil {
+			return
+		}
+		src, ok := sourceFor(tx.Statement.Schema.Table)
+		if !ok {
+			return
+		}
+		conn := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+		if err := index.Prune(conn, src.entityType, src.table); err != nil {
+			utils.Warn("Failed to prune search index",
+				zap.String("entity_type", src.entityType),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if err := db.Callback().Create().After("gorm:create").Register("search:sync_create", sync); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("search:sync_update", sync); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("search:prune", prune)
+}
+
+// Rebuild 重建所有实体的索引
+func Rebuild(db *gorm.DB, index Index) error {
+	for _, src := range sources {
+		docs, err := src.loadAll(db)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if err := index.Upsert(db, doc); err != nil {
+				return err
+			}
+		}
+		if err := index.Prune(db, src.entityType, src.table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sourceFor(table string) (source, bool) {
+	for _, src := range sources {
+		if src.table == table {
+			return src, true
+		}
+	}
+	return source{}, false
+}
+
+// statementIDs 读取本次写入记录的主键，兼容单条与批量写入
+func statementIDs(tx *gorm.DB) []uint {
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	if field == nil || !rv.IsValid() {
+		return nil
+	}
+
+	values := []reflect.Value{rv}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		values = values[:0]
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, reflect.Indirect(rv.Index(i)))
+		}
+	}
+
+	ids := make([]uint, 0, len(values))
+	for _, v := range values {
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		value, zero := field.ValueOf(tx.Statement.Context, v)
+		if id, ok := value.(uint); ok && !zero {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
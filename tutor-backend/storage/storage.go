@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"tutor-management/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 附件存储后端
+type Storage interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 读取对象，调用方负责关闭；对象不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// New 根据配置创建存储后端
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Type {
+	case "local":
+		return newLocalStorage(cfg.LocalPath)
+	case "s3":
+		return newS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Type)
+	}
+}